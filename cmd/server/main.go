@@ -5,15 +5,26 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/batch"
+	"github.com/zjoart/go-paystack-wallet/internal/escrow"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/paymentrequest"
 	"github.com/zjoart/go-paystack-wallet/internal/routes"
+	"github.com/zjoart/go-paystack-wallet/internal/schedule"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
 	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
 	"github.com/zjoart/go-paystack-wallet/pkg/config"
 	"github.com/zjoart/go-paystack-wallet/pkg/database"
 	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/fx"
 	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
 )
 
 func main() {
@@ -22,14 +33,36 @@ func main() {
 	database.Connect(cfg.DBUrl)
 
 	redisClient := events.NewRedisClient(cfg)
+	paystackClient := paystack.NewClient(cfg.PaystackSecret, cfg.PaystackBaseURL)
 	walletRepo := wallet.NewRepository(database.DB)
 
+	rates, err := fx.LoadFile(cfg.FXRatesFile)
+	if err != nil {
+		logger.Fatal("Could not load exchange rates", logger.Fields{"file": cfg.FXRatesFile, "error": err.Error()})
+	}
+	deliveryRepo := webhook.NewRepository(database.DB)
+
 	// start background worker
-	worker := wallet.NewWebhookWorker(cfg, walletRepo, redisClient)
+	worker := wallet.NewWebhookWorker(cfg, walletRepo, deliveryRepo, redisClient, paystackClient)
 	worker.Start()
 
+	reconciler := wallet.NewDepositReconciler(cfg, walletRepo, paystackClient)
+	reconciler.Start()
+
+	scheduler := schedule.NewRunner(cfg, schedule.NewRepository(database.DB), walletRepo, fee.NewRepository(database.DB), user.NewRepository(database.DB), notification.NewRepository(database.DB))
+	scheduler.Start()
+
+	batcher := batch.NewRunner(cfg, batch.NewRepository(database.DB), walletRepo)
+	batcher.Start()
+
+	expirer := paymentrequest.NewExpirer(cfg, paymentrequest.NewRepository(database.DB))
+	expirer.Start()
+
+	releaser := escrow.NewReleaser(cfg, escrow.NewRepository(database.DB))
+	releaser.Start()
+
 	r := mux.NewRouter()
-	handler := routes.RegisterRoutes(r, cfg, redisClient, walletRepo)
+	handler := routes.RegisterRoutes(r, cfg, redisClient, paystackClient, walletRepo, rates)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -46,11 +79,31 @@ func main() {
 	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	server.Shutdown(ctx)
+
+	// stop taking new events and let in-flight ones finish, unacked entries are reclaimed on the next start
+	if err := worker.Stop(ctx); err != nil {
+		logger.Error("Webhook worker did not drain in time", logger.Fields{"error": err.Error()})
+	}
+	if err := reconciler.Stop(ctx); err != nil {
+		logger.Error("Deposit reconciler did not stop in time", logger.Fields{"error": err.Error()})
+	}
+	if err := scheduler.Stop(ctx); err != nil {
+		logger.Error("Schedule runner did not stop in time", logger.Fields{"error": err.Error()})
+	}
+	if err := batcher.Stop(ctx); err != nil {
+		logger.Error("Batch runner did not stop in time", logger.Fields{"error": err.Error()})
+	}
+	if err := expirer.Stop(ctx); err != nil {
+		logger.Error("Payment request expirer did not stop in time", logger.Fields{"error": err.Error()})
+	}
+	if err := releaser.Stop(ctx); err != nil {
+		logger.Error("Escrow releaser did not stop in time", logger.Fields{"error": err.Error()})
+	}
 	logger.Info("Server gracefully shut down")
 }
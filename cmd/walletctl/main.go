@@ -0,0 +1,212 @@
+// walletctl is an operator CLI for tasks that have no public endpoint, run it with the server's .env.
+//
+//	walletctl dlq list [-limit N] [-cursor ID]
+//	walletctl dlq replay (-all | ID...)
+//	walletctl dlq purge (-all | ID...)
+//	walletctl webhook list [-reference REF] [-limit N]
+//	walletctl webhook replay ID...
+//	walletctl admin grant|revoke EMAIL
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	osuser "os/user"
+	"strings"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/database"
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+)
+
+const usage = `usage:
+  walletctl dlq list [-limit N] [-cursor ID]
+  walletctl dlq replay (-all | ID...)
+  walletctl dlq purge (-all | ID...)
+  walletctl webhook list [-reference REF] [-limit N]
+  walletctl webhook replay ID...
+  walletctl admin grant|revoke EMAIL`
+
+func main() {
+	if len(os.Args) < 3 {
+		fail(usage)
+	}
+
+	cfg := config.LoadConfig()
+
+	var err error
+	switch os.Args[1] {
+	case "dlq":
+		err = runDLQ(cfg, os.Args[2], os.Args[3:])
+	case "webhook":
+		err = runWebhook(cfg, os.Args[2], os.Args[3:])
+	case "admin":
+		err = runAdmin(cfg, os.Args[2], os.Args[3:])
+	default:
+		fail(usage)
+	}
+
+	if err != nil {
+		fail(err.Error())
+	}
+}
+
+func runDLQ(cfg config.Config, command string, args []string) error {
+	ctx := context.Background()
+	redisClient := events.NewRedisClient(cfg)
+
+	switch command {
+	case "list":
+		fs := flag.NewFlagSet("dlq list", flag.ExitOnError)
+		limit := fs.Int64("limit", 20, "entries to show")
+		cursor := fs.String("cursor", "", "show entries after this ID")
+		fs.Parse(args)
+
+		entries, next, err := redisClient.ListDLQ(ctx, *cursor, *limit)
+		if err != nil {
+			return err
+		}
+		count, _ := redisClient.CountDLQ(ctx)
+
+		for _, e := range entries {
+			fmt.Printf("%s\tattempts=%d\tfailed_at=%s\treason=%q\n\t%s\n", e.ID, e.Attempts, e.FailedAt.Format(time.RFC3339), e.Reason, e.Payload)
+		}
+		fmt.Printf("%d of %d entries", len(entries), count)
+		if next != "" {
+			fmt.Printf(", next page: -cursor %s", next)
+		}
+		fmt.Println()
+		return nil
+
+	case "replay", "purge":
+		fs := flag.NewFlagSet("dlq "+command, flag.ExitOnError)
+		all := fs.Bool("all", false, "act on every entry")
+		fs.Parse(args)
+
+		ids := fs.Args()
+		if *all == (len(ids) > 0) {
+			return fmt.Errorf("provide either -all or entry IDs")
+		}
+
+		action, verb := audit.ActionDLQReplayed, "replayed"
+		var done []string
+		var err error
+		if command == "purge" {
+			action, verb = audit.ActionDLQPurged, "purged"
+			done, err = redisClient.PurgeDLQ(ctx, ids...)
+		} else {
+			done, err = redisClient.ReplayDLQ(ctx, ids...)
+		}
+
+		if len(done) > 0 {
+			if auditErr := recordAudit(cfg, action, done); auditErr != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to record audit log: %v\n", auditErr)
+			}
+		}
+		fmt.Printf("%s %d entries\n", verb, len(done))
+		return err
+
+	default:
+		return fmt.Errorf("unknown dlq command %q\n%s", command, usage)
+	}
+}
+
+func runWebhook(cfg config.Config, command string, args []string) error {
+	database.Connect(cfg.DBUrl)
+	deliveries := webhook.NewRepository(database.DB)
+
+	switch command {
+	case "list":
+		fs := flag.NewFlagSet("webhook list", flag.ExitOnError)
+		reference := fs.String("reference", "", "only deliveries for this transaction reference")
+		limit := fs.Int("limit", 20, "deliveries to show")
+		fs.Parse(args)
+
+		list, err := deliveries.List(*reference, *limit, 0)
+		if err != nil {
+			return err
+		}
+		for _, d := range list {
+			fmt.Printf("%s\t%s\t%s\t%s\tsigned=%t\treplays=%d\t%s\n", d.ID, d.CreatedAt.Format(time.RFC3339), d.Event, d.Status, d.SignatureValid, d.ReplayCount, d.Reference)
+		}
+		return nil
+
+	case "replay":
+		if len(args) == 0 {
+			return fmt.Errorf("provide delivery IDs")
+		}
+
+		redisClient := events.NewRedisClient(cfg)
+		var done []string
+		for _, id := range args {
+			if _, err := webhook.Replay(context.Background(), deliveries, redisClient, id); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				continue
+			}
+			done = append(done, id)
+		}
+
+		if len(done) > 0 {
+			if err := recordAudit(cfg, audit.ActionWebhookReplayed, done); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to record audit log: %v\n", err)
+			}
+		}
+		fmt.Printf("replayed %d of %d deliveries\n", len(done), len(args))
+		if len(done) < len(args) {
+			return fmt.Errorf("some deliveries were not replayed")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown webhook command %q\n%s", command, usage)
+	}
+}
+
+func runAdmin(cfg config.Config, command string, args []string) error {
+	if (command != "grant" && command != "revoke") || len(args) != 1 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	database.Connect(cfg.DBUrl)
+	userRepo := user.NewRepository(database.DB)
+
+	usr, err := userRepo.FindByEmail(args[0])
+	if err != nil {
+		return fmt.Errorf("user %s not found, they need to sign in once first", args[0])
+	}
+
+	if err := userRepo.SetAdmin(usr.ID.String(), command == "grant"); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is_admin=%t\n", usr.Email, command == "grant")
+	return nil
+}
+
+func recordAudit(cfg config.Config, action audit.Action, ids []string) error {
+	if database.DB == nil {
+		database.Connect(cfg.DBUrl)
+	}
+
+	actor := "walletctl"
+	if u, err := osuser.Current(); err == nil {
+		actor += ":" + u.Username
+	}
+
+	return audit.NewRepository(database.DB).Record(&audit.Log{
+		Action:  action,
+		Actor:   actor,
+		Details: fmt.Sprintf("%d entries: %s", len(ids), strings.Join(ids, ",")),
+	})
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
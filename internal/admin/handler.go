@@ -0,0 +1,620 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/escrow"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	RedisClient *events.RedisClient
+	Audit       audit.Repository
+	Deliveries  webhook.Repository
+	Wallets     wallet.Repository
+	Escrows     escrow.Repository
+	Fees        fee.Repository
+	Users       user.Repository
+}
+
+func NewHandler(redisClient *events.RedisClient, auditRepo audit.Repository, deliveries webhook.Repository, wallets wallet.Repository, escrows escrow.Repository, fees fee.Repository, users user.Repository) *Handler {
+	return &Handler{RedisClient: redisClient, Audit: auditRepo, Deliveries: deliveries, Wallets: wallets, Escrows: escrows, Fees: fees, Users: users}
+}
+
+func (h *Handler) ListDLQ(w http.ResponseWriter, r *http.Request) {
+	limit, _, _ := utils.GetPaginationDetails(r)
+	cursor := r.URL.Query().Get("cursor")
+
+	entries, next, err := h.RedisClient.ListDLQ(r.Context(), cursor, int64(limit))
+	if err != nil {
+		logger.Error("Admin: Failed to list DLQ", logger.Fields{"error": err.Error()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to list DLQ", nil)
+		return
+	}
+
+	count, _ := h.RedisClient.CountDLQ(r.Context())
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "DLQ entries", map[string]interface{}{
+		"entries": entries,
+		"meta": map[string]interface{}{
+			"total_items": count,
+			"limit":       limit,
+			"next_cursor": next,
+		},
+	})
+}
+
+type DLQActionRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+func (h *Handler) ReplayDLQ(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDLQAction(w, r)
+	if !ok {
+		return
+	}
+
+	replayed, err := h.RedisClient.ReplayDLQ(r.Context(), req.IDs...)
+	if len(replayed) > 0 {
+		h.recordAudit(r, audit.ActionDLQReplayed, replayed)
+	}
+	if err != nil {
+		logger.Error("Admin: Failed to replay DLQ", logger.Fields{"error": err.Error(), "replayed": len(replayed)})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to replay DLQ", map[string]interface{}{"replayed": replayed})
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "DLQ entries replayed", map[string]interface{}{"replayed": replayed})
+}
+
+func (h *Handler) PurgeDLQ(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeDLQAction(w, r)
+	if !ok {
+		return
+	}
+
+	purged, err := h.RedisClient.PurgeDLQ(r.Context(), req.IDs...)
+	if len(purged) > 0 {
+		h.recordAudit(r, audit.ActionDLQPurged, purged)
+	}
+	if err != nil {
+		logger.Error("Admin: Failed to purge DLQ", logger.Fields{"error": err.Error(), "purged": len(purged)})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to purge DLQ", map[string]interface{}{"purged": purged})
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "DLQ entries purged", map[string]interface{}{"purged": purged})
+}
+
+// decodeDLQAction requires either explicit ids or all, so an empty body can never act on the whole queue
+func decodeDLQAction(w http.ResponseWriter, r *http.Request) (DLQActionRequest, bool) {
+	var req DLQActionRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return req, false
+	}
+
+	if req.All == (len(req.IDs) > 0) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Provide either ids or all=true", nil)
+		return req, false
+	}
+	return req, true
+}
+
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, offset, page := utils.GetPaginationDetails(r)
+	reference := r.URL.Query().Get("reference")
+
+	deliveries, err := h.Deliveries.List(reference, limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch webhook deliveries", nil)
+		return
+	}
+
+	count, _ := h.Deliveries.Count(reference)
+	totalPages := int(math.Ceil(float64(count) / float64(limit)))
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Webhook deliveries", map[string]interface{}{
+		"deliveries": deliveries,
+		"meta": map[string]interface{}{
+			"total_items":  count,
+			"total_pages":  totalPages,
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.Deliveries.GetByID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Webhook delivery not found", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Webhook delivery", delivery)
+}
+
+func (h *Handler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	delivery, err := webhook.Replay(r.Context(), h.Deliveries, h.RedisClient, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Webhook delivery not found", nil)
+		case errors.Is(err, webhook.ErrUnsignedDelivery):
+			utils.BuildErrorResponse(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		default:
+			logger.Error("Admin: Failed to replay webhook delivery", logger.Fields{"error": err.Error(), "delivery_id": id})
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to replay webhook delivery", map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	h.recordAudit(r, audit.ActionWebhookReplayed, []string{delivery.ID.String()})
+	utils.BuildSuccessResponse(w, http.StatusAccepted, "Webhook delivery queued for replay", map[string]interface{}{
+		"id":        delivery.ID,
+		"event":     delivery.Event,
+		"reference": delivery.Reference,
+	})
+}
+
+type ReverseTransferRequest struct {
+	// Amount of zero reverses whatever is left of the transfer
+	Amount int64 `json:"amount"`
+	// Force takes the money back even if it leaves the recipient's balance negative
+	Force  bool   `json:"force"`
+	Reason string `json:"reason"`
+}
+
+// ReverseTransfer sends a transfer back on an operator's say, e.g. after a fraud report
+func (h *Handler) ReverseTransfer(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	reference := wallet.TransferReference(mux.Vars(r)["reference"])
+
+	var req ReverseTransferRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Reason == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "reason is required", nil)
+		return
+	}
+	if req.Amount < 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount", nil)
+		return
+	}
+
+	if _, err := h.Wallets.GetTransactionByReference(reference + "-debit"); err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Transfer not found", nil)
+		return
+	}
+
+	legs, err := h.Wallets.ReverseTransfer(reference, req.Amount, req.Force, req.Reason)
+	if err != nil {
+		wallet.WriteReversalError(w, err, reference)
+		return
+	}
+
+	entry := audit.Log{
+		UserID:   &usr.ID,
+		WalletID: &legs[0].WalletID,
+		Action:   audit.ActionTransferReversed,
+		Actor:    "admin:" + usr.Email,
+		Details:  fmt.Sprintf("reversed %d of %s (force=%t): %s", legs[0].Amount, reference, req.Force, req.Reason),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Transfer reversed", legs)
+}
+
+// ListEscrows lists every user's escrows, ?status=DISPUTED gives the ones waiting on a resolution
+func (h *Handler) ListEscrows(w http.ResponseWriter, r *http.Request) {
+	limit, offset, page := utils.GetPaginationDetails(r)
+
+	escrows, err := h.Escrows.List("", escrow.Status(r.URL.Query().Get("status")), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch escrows", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Escrows", map[string]interface{}{
+		"escrows": escrows,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+type ResolveEscrowRequest struct {
+	// Outcome is RELEASED to pay the recipient or REFUNDED to pay the sender back
+	Outcome    escrow.Status `json:"outcome"`
+	Resolution string        `json:"resolution"`
+}
+
+// ResolveEscrow settles a disputed escrow one way or the other
+func (h *Handler) ResolveEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	id := mux.Vars(r)["id"]
+
+	var req ResolveEscrowRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Outcome != escrow.StatusReleased && req.Outcome != escrow.StatusRefunded {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "outcome must be RELEASED or REFUNDED", nil)
+		return
+	}
+	if req.Resolution == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "resolution is required", nil)
+		return
+	}
+
+	esc, err := h.Escrows.Get(id)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Escrow not found", nil)
+		return
+	}
+	if esc.Status != escrow.StatusDisputed {
+		utils.BuildErrorResponse(w, http.StatusConflict, "Only a disputed escrow can be resolved", nil)
+		return
+	}
+
+	esc, err = h.Escrows.Move(id, req.Outcome, escrow.ByAdmin, req.Resolution, time.Now())
+	if err != nil {
+		escrow.WriteError(w, err, id)
+		return
+	}
+
+	entry := audit.Log{
+		UserID:   &usr.ID,
+		WalletID: &esc.SenderWalletID,
+		Action:   audit.ActionEscrowResolved,
+		Actor:    "admin:" + usr.Email,
+		Details:  fmt.Sprintf("escrow %s %s: %s", esc.ID, esc.Status, req.Resolution),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Escrow resolved", esc)
+}
+
+type ReviewDepositRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ApproveDeposit credits a deposit held for review once an operator has checked the payment against it
+func (h *Handler) ApproveDeposit(w http.ResponseWriter, r *http.Request) {
+	h.resolveDeposit(w, r, true)
+}
+
+// RejectDeposit fails a deposit held for review, nothing is credited
+func (h *Handler) RejectDeposit(w http.ResponseWriter, r *http.Request) {
+	h.resolveDeposit(w, r, false)
+}
+
+func (h *Handler) resolveDeposit(w http.ResponseWriter, r *http.Request, approve bool) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	reference := mux.Vars(r)["reference"]
+
+	var req ReviewDepositRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Reason == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "reason is required", nil)
+		return
+	}
+
+	deposit, err := h.Wallets.ResolveReview(reference, approve)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Deposit not found", nil)
+		return
+	case errors.Is(err, wallet.ErrNotInReview):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Deposit is not held for review", nil)
+		return
+	case err != nil:
+		logger.Error("Admin: Failed to resolve deposit review", logger.Fields{"error": err.Error(), "reference": reference})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to resolve deposit", nil)
+		return
+	}
+
+	action, message := audit.ActionDepositRejected, "Deposit rejected"
+	if approve {
+		action, message = audit.ActionDepositApproved, "Deposit approved"
+	}
+	entry := audit.Log{
+		UserID:   &usr.ID,
+		WalletID: &deposit.WalletID,
+		Action:   action,
+		Actor:    "admin:" + usr.Email,
+		Details:  fmt.Sprintf("deposit %s held for %q is %s: %s", deposit.Reference, deposit.ReviewReason, deposit.Status, req.Reason),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, message, deposit)
+}
+
+type ReleaseHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReleaseHold frees a hold on an operator's say, whoever placed it and whether or not it has expired
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	id := mux.Vars(r)["id"]
+
+	var req ReleaseHoldRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Reason == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "reason is required", nil)
+		return
+	}
+
+	hold, err := h.Wallets.GetHold(id)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Hold not found", nil)
+		return
+	}
+
+	released, err := h.Wallets.ReleaseHold(id, time.Now())
+	if err != nil {
+		wallet.WriteHoldError(w, err, hold)
+		return
+	}
+
+	entry := audit.Log{
+		UserID:   &usr.ID,
+		WalletID: &released.WalletID,
+		Action:   audit.ActionHoldReleased,
+		Actor:    "admin:" + usr.Email,
+		Details:  fmt.Sprintf("released hold %s of %d: %s", released.ID, released.Amount, req.Reason),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Hold released", released)
+}
+
+// ListFeeSchedules lists every fee schedule, ?category= and ?currency= narrow it down
+func (h *Handler) ListFeeSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.Fees.List(fee.Category(r.URL.Query().Get("category")), strings.ToUpper(r.URL.Query().Get("currency")))
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch fee schedules", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Fee schedules", map[string]interface{}{
+		"schedules": schedules,
+	})
+}
+
+func (h *Handler) GetFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.Fees.Get(mux.Vars(r)["id"])
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Fee schedule not found", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Fee schedule", schedule)
+}
+
+type FeeScheduleRequest struct {
+	Name     string       `json:"name"`
+	Category fee.Category `json:"category"`
+	Currency string       `json:"currency"`
+	Kind     fee.Kind     `json:"kind"`
+	Flat     int64        `json:"flat"`
+	Bps      int64        `json:"bps"`
+	Tiers    []fee.Tier   `json:"tiers"`
+	MinFee   int64        `json:"min_fee"`
+	MaxFee   int64        `json:"max_fee"`
+	APIKeyID *uuid.UUID   `json:"api_key_id"`
+	UserTier string       `json:"user_tier"`
+	// Active defaults to true
+	Active *bool `json:"active"`
+}
+
+// CreateFeeSchedule adds a schedule, it applies to new transactions straight away
+func (h *Handler) CreateFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := decodeFeeSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Fees.Create(schedule); err != nil {
+		logger.Error("Admin: Failed to create fee schedule", logger.Fields{"error": err.Error()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create fee schedule", nil)
+		return
+	}
+
+	h.recordFeeChange(r, "created", schedule)
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Fee schedule created", schedule)
+}
+
+// UpdateFeeSchedule replaces a schedule with the one sent, tiers included
+func (h *Handler) UpdateFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	existing, err := h.Fees.Get(mux.Vars(r)["id"])
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Fee schedule not found", nil)
+		return
+	}
+
+	schedule, ok := decodeFeeSchedule(w, r)
+	if !ok {
+		return
+	}
+	schedule.ID, schedule.CreatedAt = existing.ID, existing.CreatedAt
+
+	if err := h.Fees.Update(schedule); err != nil {
+		logger.Error("Admin: Failed to update fee schedule", logger.Fields{"error": err.Error(), "schedule_id": existing.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to update fee schedule", nil)
+		return
+	}
+
+	h.recordFeeChange(r, "updated", schedule)
+	utils.BuildSuccessResponse(w, http.StatusOK, "Fee schedule updated", schedule)
+}
+
+func (h *Handler) DeleteFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.Fees.Get(mux.Vars(r)["id"])
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Fee schedule not found", nil)
+		return
+	}
+
+	if err := h.Fees.Delete(schedule.ID.String()); err != nil {
+		logger.Error("Admin: Failed to delete fee schedule", logger.Fields{"error": err.Error(), "schedule_id": schedule.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to delete fee schedule", nil)
+		return
+	}
+
+	h.recordFeeChange(r, "deleted", schedule)
+	utils.BuildSuccessResponse(w, http.StatusOK, "Fee schedule deleted", nil)
+}
+
+func decodeFeeSchedule(w http.ResponseWriter, r *http.Request) (*fee.Schedule, bool) {
+	var req FeeScheduleRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return nil, false
+	}
+
+	schedule := fee.Schedule{
+		Name:     req.Name,
+		Category: req.Category,
+		Currency: strings.ToUpper(req.Currency),
+		Kind:     req.Kind,
+		Flat:     req.Flat,
+		Bps:      req.Bps,
+		Tiers:    req.Tiers,
+		MinFee:   req.MinFee,
+		MaxFee:   req.MaxFee,
+		APIKeyID: req.APIKeyID,
+		UserTier: strings.ToUpper(req.UserTier),
+		Active:   req.Active == nil || *req.Active,
+	}
+
+	if !slices.Contains(config.SupportedCurrencies, schedule.Currency) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Unsupported currency", map[string]interface{}{
+			"supported": config.SupportedCurrencies,
+		})
+		return nil, false
+	}
+	if err := schedule.Validate(); err != nil {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, err.Error(), nil)
+		return nil, false
+	}
+	return &schedule, true
+}
+
+func (h *Handler) recordFeeChange(r *http.Request, what string, schedule *fee.Schedule) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	entry := audit.Log{
+		UserID:  &usr.ID,
+		Action:  audit.ActionFeeScheduleChanged,
+		Actor:   "admin:" + usr.Email,
+		Details: fmt.Sprintf("fee schedule %s %s: %s %s %s", schedule.ID, what, schedule.Category, schedule.Currency, schedule.Name),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+	}
+}
+
+type SetUserTierRequest struct {
+	Tier string `json:"tier"`
+}
+
+// SetUserTier moves a user to another fee tier, schedules for that tier apply to their next transaction
+func (h *Handler) SetUserTier(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	id := mux.Vars(r)["id"]
+
+	var req SetUserTierRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+	tier := strings.ToUpper(strings.TrimSpace(req.Tier))
+	if tier == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "tier is required", nil)
+		return
+	}
+
+	if err := h.Users.SetTier(id, tier); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "User not found", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to set tier", nil)
+		}
+		return
+	}
+
+	target, err := uuid.Parse(id)
+	if err == nil {
+		entry := audit.Log{
+			UserID:  &target,
+			Action:  audit.ActionUserTierChanged,
+			Actor:   "admin:" + usr.Email,
+			Details: "tier set to " + tier,
+		}
+		if err := h.Audit.Record(&entry); err != nil {
+			logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(entry.Action)})
+		}
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Tier updated", map[string]string{"user_id": id, "tier": tier})
+}
+
+func (h *Handler) recordAudit(r *http.Request, action audit.Action, ids []string) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	entry := audit.Log{
+		UserID:  &usr.ID,
+		Action:  action,
+		Actor:   "admin:" + usr.Email,
+		Details: fmt.Sprintf("%d entries: %s", len(ids), strings.Join(ids, ",")),
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Admin: Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(action)})
+	}
+}
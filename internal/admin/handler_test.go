@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// reviewWallets holds deposits and settles the ones in review the way the wallet repository does
+type reviewWallets struct {
+	wallet.Repository
+
+	deposits map[string]*wallet.Transaction
+	credited map[uuid.UUID]int64
+}
+
+func (m *reviewWallets) ResolveReview(reference string, approve bool) (*wallet.Transaction, error) {
+	tx, ok := m.deposits[reference]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if tx.Status != wallet.TransactionReview {
+		return nil, wallet.ErrNotInReview
+	}
+	if approve {
+		m.credited[tx.WalletID] += tx.Amount - tx.Fee
+		tx.Status = wallet.TransactionSuccess
+	} else {
+		tx.Status = wallet.TransactionFailed
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+type memoryAudit struct {
+	entries []audit.Log
+}
+
+func (m *memoryAudit) Record(entry *audit.Log) error {
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func TestResolveDepositReview(t *testing.T) {
+	walletID := uuid.New()
+	wallets := &reviewWallets{
+		deposits: map[string]*wallet.Transaction{},
+		credited: map[uuid.UUID]int64{},
+	}
+	for _, reference := range []string{"dep-short", "dep-foreign"} {
+		wallets.deposits[reference] = &wallet.Transaction{
+			WalletID:     walletID,
+			Reference:    reference,
+			Category:     wallet.CategoryDeposit,
+			Amount:       50000,
+			Status:       wallet.TransactionReview,
+			ReviewReason: "amount 5000, expected 50000",
+		}
+	}
+	auditLog := &memoryAudit{}
+	h := NewHandler(nil, auditLog, nil, wallets, nil, nil, nil)
+	admin := user.User{ID: uuid.New(), Email: "ops@example.com", IsAdmin: true}
+
+	resolve := func(handler http.HandlerFunc, reference, reason string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		json.NewEncoder(&body).Encode(ReviewDepositRequest{Reason: reason})
+		req := httptest.NewRequest("POST", "/admin/deposits/"+reference, &body)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, admin))
+		req = mux.SetURLVars(req, map[string]string{"reference": reference})
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	rr := resolve(h.ApproveDeposit, "dep-short", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = resolve(h.ApproveDeposit, "dep-short", "Customer topped up the difference by bank transfer")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, wallet.TransactionSuccess, wallets.deposits["dep-short"].Status)
+	assert.Equal(t, int64(50000), wallets.credited[walletID])
+
+	rr = resolve(h.RejectDeposit, "dep-foreign", "Paid against another merchant's checkout")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, wallet.TransactionFailed, wallets.deposits["dep-foreign"].Status)
+	assert.Equal(t, int64(50000), wallets.credited[walletID])
+
+	// a deposit that has left review can't be resolved again
+	rr = resolve(h.RejectDeposit, "dep-short", "Changed my mind")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = resolve(h.ApproveDeposit, "dep-missing", "Looks fine")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	require.Len(t, auditLog.entries, 2)
+	assert.Equal(t, audit.ActionDepositApproved, auditLog.entries[0].Action)
+	assert.Equal(t, audit.ActionDepositRejected, auditLog.entries[1].Action)
+	assert.Equal(t, "admin:ops@example.com", auditLog.entries[1].Actor)
+	assert.Equal(t, walletID, *auditLog.entries[1].WalletID)
+	assert.Contains(t, auditLog.entries[1].Details, "Paid against another merchant's checkout")
+}
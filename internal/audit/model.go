@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	ActionPinChanged         Action = "PIN_CHANGED"
+	ActionPinReset           Action = "PIN_RESET"
+	ActionPinFailedAttempt   Action = "PIN_FAILED_ATTEMPT"
+	ActionPinLocked          Action = "PIN_LOCKED"
+	ActionDLQReplayed        Action = "DLQ_REPLAYED"
+	ActionDLQPurged          Action = "DLQ_PURGED"
+	ActionWebhookReplayed    Action = "WEBHOOK_REPLAYED"
+	ActionTransferReversed   Action = "TRANSFER_REVERSED"
+	ActionEscrowResolved     Action = "ESCROW_RESOLVED"
+	ActionFeeScheduleChanged Action = "FEE_SCHEDULE_CHANGED"
+	ActionUserTierChanged    Action = "USER_TIER_CHANGED"
+	ActionHoldReleased       Action = "HOLD_RELEASED"
+	ActionDepositApproved    Action = "DEPOSIT_APPROVED"
+	ActionDepositRejected    Action = "DEPOSIT_REJECTED"
+)
+
+type Log struct {
+	ID       uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID   *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	WalletID *uuid.UUID `gorm:"type:uuid" json:"wallet_id,omitempty"`
+	Action   Action     `gorm:"not null" json:"action"`
+	// Actor names who did it when that is not UserID acting on their own wallet, e.g. walletctl:<os user>
+	Actor     string    `json:"actor,omitempty"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Log) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Record(entry *Log) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Record(entry *Log) error {
+	return r.db.Create(entry).Error
+}
@@ -84,6 +84,7 @@ func (h *Handler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		utils.UserIDKey: usr.ID,
 		utils.ExpKey:    expirationTime.Unix(),
+		utils.IatKey:    time.Now().Unix(),
 	})
 
 	tokenString, err := jwtToken.SignedString([]byte(h.Config.JWTSecret))
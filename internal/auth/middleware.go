@@ -23,7 +23,7 @@ func JWTMiddleware(cfg config.Config, userRepo user.Repository) func(http.Handle
 				return
 			}
 			tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-			usr, err := validateJWT(tokenString, cfg.JWTSecret, userRepo)
+			usr, issuedAt, err := validateJWT(tokenString, cfg.JWTSecret, userRepo)
 			if err != nil {
 				utils.BuildErrorResponse(w, http.StatusUnauthorized, err.Error(), nil)
 				return
@@ -31,6 +31,7 @@ func JWTMiddleware(cfg config.Config, userRepo user.Repository) func(http.Handle
 
 			ctx := context.WithValue(r.Context(), utils.UserKey, *usr)
 			ctx = context.WithValue(ctx, utils.PermissionsKey, []string{"*"})
+			ctx = context.WithValue(ctx, utils.AuthTimeKey, issuedAt)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -45,14 +46,15 @@ func APIKeyMiddleware(keyRepo key.Repository, userRepo user.Repository) func(htt
 				return
 			}
 
-			usr, perms, err := validateAPIKey(apiKeyHeader, keyRepo, userRepo)
+			usr, apiKey, err := validateAPIKey(apiKeyHeader, keyRepo, userRepo)
 			if err != nil {
 				utils.BuildErrorResponse(w, http.StatusUnauthorized, err.Error(), nil)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), utils.UserKey, *usr)
-			ctx = context.WithValue(ctx, utils.PermissionsKey, perms)
+			ctx = context.WithValue(ctx, utils.PermissionsKey, []string(apiKey.Permissions))
+			ctx = context.WithValue(ctx, utils.APIKeyIDKey, apiKey.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -66,7 +68,7 @@ func UnifiedAuthMiddleware(cfg config.Config, userRepo user.Repository, keyRepo
 
 			if authHeader != "" {
 				tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-				usr, err := validateJWT(tokenString, cfg.JWTSecret, userRepo)
+				usr, issuedAt, err := validateJWT(tokenString, cfg.JWTSecret, userRepo)
 				if err != nil {
 					utils.BuildErrorResponse(w, http.StatusUnauthorized, "Invalid token: "+err.Error(), nil)
 					return
@@ -74,16 +76,18 @@ func UnifiedAuthMiddleware(cfg config.Config, userRepo user.Repository, keyRepo
 
 				ctx := context.WithValue(r.Context(), utils.UserKey, *usr)
 				ctx = context.WithValue(ctx, utils.PermissionsKey, []string{"*"})
+				ctx = context.WithValue(ctx, utils.AuthTimeKey, issuedAt)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			} else if apiKeyHeader != "" {
-				usr, perms, err := validateAPIKey(apiKeyHeader, keyRepo, userRepo)
+				usr, apiKey, err := validateAPIKey(apiKeyHeader, keyRepo, userRepo)
 				if err != nil {
 					utils.BuildErrorResponse(w, http.StatusUnauthorized, "Invalid API Key: "+err.Error(), nil)
 					return
 				}
 				ctx := context.WithValue(r.Context(), utils.UserKey, *usr)
-				ctx = context.WithValue(ctx, utils.PermissionsKey, perms)
+				ctx = context.WithValue(ctx, utils.PermissionsKey, []string(apiKey.Permissions))
+				ctx = context.WithValue(ctx, utils.APIKeyIDKey, apiKey.ID)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			} else {
@@ -96,7 +100,7 @@ func UnifiedAuthMiddleware(cfg config.Config, userRepo user.Repository, keyRepo
 
 // Helpers
 
-func validateJWT(tokenString, secret string, userRepo user.Repository) (*user.User, error) {
+func validateJWT(tokenString, secret string, userRepo user.Repository) (*user.User, time.Time, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method")
@@ -105,27 +109,34 @@ func validateJWT(tokenString, secret string, userRepo user.Repository) (*user.Us
 	})
 
 	if err != nil || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+		return nil, time.Time{}, fmt.Errorf("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, fmt.Errorf("invalid token claims")
+		return nil, time.Time{}, fmt.Errorf("invalid token claims")
 	}
 
 	userIDStr, ok := claims[utils.UserIDKey].(string)
 	if !ok {
-		return nil, fmt.Errorf("invalid user ID in token")
+		return nil, time.Time{}, fmt.Errorf("invalid user ID in token")
 	}
 
 	usr, err := userRepo.FindByID(userIDStr)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, time.Time{}, fmt.Errorf("user not found")
 	}
-	return usr, nil
+
+	// tokens issued before iat was added carry no auth time and never count as a recent login
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+
+	return usr, issuedAt, nil
 }
 
-func validateAPIKey(keyStr string, keyRepo key.Repository, userRepo user.Repository) (*user.User, []string, error) {
+func validateAPIKey(keyStr string, keyRepo key.Repository, userRepo user.Repository) (*user.User, *key.APIKey, error) {
 	apiKey, err := keyRepo.FindByKey(keyStr)
 	if err != nil {
 		return nil, nil, fmt.Errorf("invalid API Key")
@@ -143,10 +154,15 @@ func validateAPIKey(keyStr string, keyRepo key.Repository, userRepo user.Reposit
 	if err != nil {
 		return nil, nil, fmt.Errorf("associated user not found")
 	}
-	return usr, apiKey.Permissions, nil
+	return usr, apiKey, nil
 }
 
 func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return RequireAnyPermission(perm)
+}
+
+// RequireAnyPermission lets the request through when the caller holds at least one of perms
+func RequireAnyPermission(required ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			perms, ok := r.Context().Value(utils.PermissionsKey).([]string)
@@ -157,10 +173,19 @@ func RequirePermission(perm string) func(http.Handler) http.Handler {
 
 			hasPerm := false
 			for _, p := range perms {
-				if p == "*" || p == perm {
+				if p == "*" {
 					hasPerm = true
 					break
 				}
+				for _, perm := range required {
+					if p == perm {
+						hasPerm = true
+						break
+					}
+				}
+				if hasPerm {
+					break
+				}
 			}
 
 			if !hasPerm {
@@ -172,3 +197,35 @@ func RequirePermission(perm string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// RequireRecentLogin only admits JWT sessions, and when maxAge is set only ones signed in within maxAge
+func RequireRecentLogin(maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authTime, ok := r.Context().Value(utils.AuthTimeKey).(time.Time)
+			if !ok {
+				utils.BuildErrorResponse(w, http.StatusForbidden, "This action requires a signed-in session, API keys are not allowed", nil)
+				return
+			}
+
+			if maxAge > 0 && (authTime.IsZero() || time.Since(authTime) > maxAge) {
+				utils.BuildErrorResponse(w, http.StatusUnauthorized, "Please sign in again to continue", nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usr, ok := r.Context().Value(utils.UserKey).(user.User)
+		if !ok || !usr.IsAdmin {
+			utils.BuildErrorResponse(w, http.StatusForbidden, "Admin access required", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
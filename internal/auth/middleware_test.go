@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
 	"github.com/zjoart/go-paystack-wallet/pkg/utils"
 )
 
@@ -70,3 +72,160 @@ func TestRequirePermission(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireAnyPermission(t *testing.T) {
+	tests := []struct {
+		name           string
+		userPerms      []string
+		requiredPerms  []string
+		expectedStatus int
+	}{
+		{
+			name:           "JWT User (Wildcard) - Access Granted",
+			userPerms:      []string{"*"},
+			requiredPerms:  []string{"TRANSFER", "WITHDRAWAL"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "API Key (One Of) - Access Granted",
+			userPerms:      []string{"READ", "WITHDRAWAL"},
+			requiredPerms:  []string{"TRANSFER", "WITHDRAWAL"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "API Key (None Of) - Access Denied",
+			userPerms:      []string{"READ", "DEPOSIT"},
+			requiredPerms:  []string{"TRANSFER", "WITHDRAWAL"},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := RequireAnyPermission(tt.requiredPerms...)(nextHandler)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			ctx := context.WithValue(req.Context(), utils.PermissionsKey, tt.userPerms)
+			req = req.WithContext(ctx)
+
+			rr := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestRequireRecentLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		authTime       interface{}
+		maxAge         time.Duration
+		expectedStatus int
+	}{
+		{
+			name:           "Fresh JWT Session - Access Granted",
+			authTime:       time.Now().Add(-time.Minute),
+			maxAge:         10 * time.Minute,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Stale JWT Session - Sign In Again",
+			authTime:       time.Now().Add(-time.Hour),
+			maxAge:         10 * time.Minute,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "JWT Without iat - Sign In Again",
+			authTime:       time.Time{},
+			maxAge:         10 * time.Minute,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Any JWT Session - Access Granted",
+			authTime:       time.Now().Add(-time.Hour),
+			maxAge:         0,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "API Key - Access Denied",
+			authTime:       nil,
+			maxAge:         0,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := RequireRecentLogin(tt.maxAge)(nextHandler)
+
+			req := httptest.NewRequest("POST", "/", nil)
+			if tt.authTime != nil {
+				req = req.WithContext(context.WithValue(req.Context(), utils.AuthTimeKey, tt.authTime))
+			}
+
+			rr := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		user           interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Admin - Access Granted",
+			user:           user.User{IsAdmin: true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Regular User - Access Denied",
+			user:           user.User{},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "No User - Access Denied",
+			user:           nil,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := RequireAdmin(nextHandler)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, tt.user))
+			}
+
+			rr := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package batch
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// maxBodyBytes matches the limit DecodeJSONBody puts on a JSON body
+const maxBodyBytes = 1 << 20
+
+// Handler serves a user's batches, Wallets checks PINs, recipients and fees the way a transfer does
+type Handler struct {
+	Repo    Repository
+	Wallets *wallet.Handler
+}
+
+func NewHandler(repo Repository, wallets *wallet.Handler) *Handler {
+	return &Handler{Repo: repo, Wallets: wallets}
+}
+
+type ItemRequest struct {
+	WalletNumber string `json:"wallet_number"`
+	Amount       int64  `json:"amount"`
+	Description  string `json:"description"`
+}
+
+type CreateBatchRequest struct {
+	// Reference is chosen by the client, a batch sent again under a reference already used returns the first
+	// batch instead of paying twice
+	Reference string `json:"reference"`
+	Mode      Mode   `json:"mode"`
+	// Currency picks the sender wallet, every recipient wallet must hold the same currency
+	Currency    string        `json:"currency"`
+	Description string        `json:"description"`
+	Pin         string        `json:"pin"`
+	Items       []ItemRequest `json:"items"`
+}
+
+// LineProblem is why one line of a submitted batch was rejected
+type LineProblem struct {
+	Line         int    `json:"line"`
+	WalletNumber string `json:"wallet_number,omitempty"`
+	Error        string `json:"error"`
+}
+
+// CreateBatch pays many wallets from one of the user's wallets with a single PIN check. The batch comes as a
+// JSON body or as multipart/form-data with a CSV "file" whose header is wallet_number,amount,description.
+// Every line is validated before anything is paid, a batch with one bad line is rejected whole. A valid batch
+// is saved and accepted as PROCESSING, the Runner pays it and GetBatch reports how it went.
+func (h *Handler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	req, status, err := decodeBatchRequest(w, r)
+	if err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Reference == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "reference is required", nil)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = ModeAllOrNothing
+	}
+	if req.Mode != ModeAllOrNothing && req.Mode != ModeBestEffort {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "mode must be ALL_OR_NOTHING or BEST_EFFORT", nil)
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > h.Wallets.Config.BatchMaxItems {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("A batch must have between 1 and %d items", h.Wallets.Config.BatchMaxItems), nil)
+		return
+	}
+
+	currency, ok := wallet.ResolveCurrency(w, req.Currency)
+	if !ok {
+		return
+	}
+
+	sender, ok := h.Wallets.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	if !h.Wallets.VerifyPin(w, sender, req.Pin) {
+		return
+	}
+
+	if existing, err := h.Repo.GetByReference(usr.ID.String(), req.Reference); err == nil {
+		utils.BuildSuccessResponse(w, http.StatusOK, "Batch already submitted", existing)
+		return
+	}
+
+	batch := Batch{
+		ID:        uuid.New(),
+		UserID:    usr.ID,
+		WalletID:  sender.ID,
+		Reference: req.Reference,
+		Mode:      req.Mode,
+		Status:    StatusProcessing,
+		Currency:  sender.Currency,
+		ItemCount: len(req.Items),
+	}
+
+	apiKeyID, tier := wallet.PricedFor(r)
+	problems, err := h.buildItems(&batch, sender, req, apiKeyID, tier)
+	if err != nil {
+		logger.Error("Failed to price batch", logger.Fields{"error": err.Error(), "reference": req.Reference})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to work out the fee", nil)
+		return
+	}
+	if len(problems) > 0 {
+		utils.BuildErrorResponse(w, http.StatusUnprocessableEntity, "Batch has invalid lines, nothing was paid", map[string]interface{}{
+			"errors": problems,
+		})
+		return
+	}
+
+	if err := h.Repo.Create(&batch); err != nil {
+		// the same reference submitted twice at once, the other request saved the batch
+		if existing, lookupErr := h.Repo.GetByReference(usr.ID.String(), req.Reference); lookupErr == nil {
+			utils.BuildSuccessResponse(w, http.StatusOK, "Batch already submitted", existing)
+			return
+		}
+		logger.Error("Failed to create batch", logger.Fields{"error": err.Error(), "reference": req.Reference})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create batch", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusAccepted, "Batch accepted", batch)
+}
+
+// decodeBatchRequest reads a batch from a JSON body or from a multipart form carrying a CSV file
+func decodeBatchRequest(w http.ResponseWriter, r *http.Request) (*CreateBatchRequest, int, error) {
+	var req CreateBatchRequest
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		status, err := utils.DecodeJSONBody(w, r, &req)
+		return &req, status, err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := r.ParseMultipartForm(maxBodyBytes); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("a CSV file is required in the file field")
+	}
+	defer file.Close()
+
+	items, err := parseBatchCSV(file)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	req = CreateBatchRequest{
+		Reference:   r.FormValue("reference"),
+		Mode:        Mode(r.FormValue("mode")),
+		Currency:    r.FormValue("currency"),
+		Description: r.FormValue("description"),
+		Pin:         r.FormValue("pin"),
+		Items:       items,
+	}
+	return &req, http.StatusOK, nil
+}
+
+// parseBatchCSV reads rows under a header naming wallet_number, amount and optionally description, in any
+// order. An amount that is not a whole number of minor units is read as 0 and rejected with its line.
+func parseBatchCSV(file io.Reader) ([]ItemRequest, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	numberCol, hasNumber := columns["wallet_number"]
+	amountCol, hasAmount := columns["amount"]
+	if !hasNumber || !hasAmount {
+		return nil, errors.New("CSV header must name wallet_number and amount columns")
+	}
+	descriptionCol, hasDescription := columns["description"]
+
+	var items []ItemRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read CSV: %w", err)
+		}
+
+		amount, _ := strconv.ParseInt(strings.TrimSpace(record[amountCol]), 10, 64)
+		item := ItemRequest{WalletNumber: strings.TrimSpace(record[numberCol]), Amount: amount}
+		if hasDescription {
+			item.Description = record[descriptionCol]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// buildItems turns the submitted lines into the batch's items, each priced as a transfer of its own, and
+// reports every line that can't be paid
+func (h *Handler) buildItems(batch *Batch, sender *wallet.Wallet, req *CreateBatchRequest, apiKeyID *uuid.UUID, tier string) ([]LineProblem, error) {
+	var problems []LineProblem
+	recipients := map[string]*wallet.Wallet{}
+	minAmount := h.Wallets.Config.MinTransactionAmount(sender.Currency)
+
+	for i, line := range req.Items {
+		lineNumber := i + 1
+		problem := func(message string) {
+			problems = append(problems, LineProblem{Line: lineNumber, WalletNumber: line.WalletNumber, Error: message})
+		}
+
+		if line.Amount < minAmount {
+			problem(fmt.Sprintf("amount can't be less than %d %s minor units", minAmount, sender.Currency))
+			continue
+		}
+		if line.WalletNumber == "" {
+			problem("wallet_number is required")
+			continue
+		}
+		if line.WalletNumber == sender.WalletNumber {
+			problem("cannot transfer to self")
+			continue
+		}
+
+		recipient, seen := recipients[line.WalletNumber]
+		if !seen {
+			recipient, _ = h.Wallets.Repo.GetWalletByNumber(line.WalletNumber)
+			recipients[line.WalletNumber] = recipient
+		}
+		if recipient == nil {
+			problem("recipient wallet not found")
+			continue
+		}
+		if recipient.Currency != sender.Currency {
+			problem(fmt.Sprintf("recipient wallet holds %s not %s", recipient.Currency, sender.Currency))
+			continue
+		}
+
+		charge, err := h.Wallets.Fees.Charge(fee.CategoryTransfer, sender.Currency, line.Amount, apiKeyID, tier)
+		if err != nil {
+			return nil, err
+		}
+
+		description := line.Description
+		if description == "" {
+			description = req.Description
+		}
+		batch.TotalAmount += line.Amount
+		batch.TotalFee += charge.Amount
+		batch.Items = append(batch.Items, Item{
+			ID:                uuid.New(),
+			BatchID:           batch.ID,
+			Line:              lineNumber,
+			WalletNumber:      line.WalletNumber,
+			RecipientWalletID: recipient.ID,
+			Amount:            line.Amount,
+			Fee:               charge.Amount,
+			FeeScheduleID:     charge.ScheduleID,
+			Description:       description,
+			Status:            ItemPending,
+			Reference:         fmt.Sprintf("bat-%s-%d", batch.ID, lineNumber),
+		})
+	}
+	return problems, nil
+}
+
+func (h *Handler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	batches, err := h.Repo.List(usr.ID.String(), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch batches", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Batches", map[string]interface{}{
+		"batches": batches,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	batch, ok := h.ownBatch(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Batch", batch)
+}
+
+// GetBatchReport downloads the outcome of every line of a batch as CSV
+func (h *Handler) GetBatchReport(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	batch, ok := h.ownBatch(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"batch-%s.csv\"", batch.ID))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "wallet_number", "amount", "description", "status", "reference", "error"})
+	for _, item := range batch.Items {
+		writer.Write([]string{
+			strconv.Itoa(item.Line),
+			item.WalletNumber,
+			strconv.FormatInt(item.Amount, 10),
+			item.Description,
+			string(item.Status),
+			item.Reference,
+			item.Error,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		logger.Error("Failed to write batch report", logger.Fields{"error": err.Error(), "batch_id": batch.ID.String()})
+	}
+}
+
+// ownBatch loads one of the user's batches, anyone else's batch reads as not found
+func (h *Handler) ownBatch(w http.ResponseWriter, usr user.User, batchID string) (*Batch, bool) {
+	batch, err := h.Repo.Get(batchID)
+	if err != nil || batch.UserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Batch not found", nil)
+		return nil, false
+	}
+	return batch, true
+}
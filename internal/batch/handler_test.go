@@ -0,0 +1,331 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository paying out of wallets
+type memoryRepo struct {
+	mu      sync.Mutex
+	wallets *wallettest.Repository
+	batches map[uuid.UUID]*Batch
+}
+
+func newMemoryRepo(wallets *wallettest.Repository) *memoryRepo {
+	return &memoryRepo{wallets: wallets, batches: make(map[uuid.UUID]*Batch)}
+}
+
+func (m *memoryRepo) Create(batch *Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *batch
+	cp.Items = append([]Item(nil), batch.Items...)
+	m.batches[batch.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) Get(batchID string) (*Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(batchID)
+	if err != nil {
+		return nil, err
+	}
+	batch, ok := m.batches[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *batch
+	cp.Items = append([]Item(nil), batch.Items...)
+	return &cp, nil
+}
+
+func (m *memoryRepo) GetByReference(userID, reference string) (*Batch, error) {
+	m.mu.Lock()
+	var found *Batch
+	for _, b := range m.batches {
+		if b.UserID.String() == userID && b.Reference == reference {
+			found = b
+		}
+	}
+	m.mu.Unlock()
+	if found == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m.Get(found.ID.String())
+}
+
+func (m *memoryRepo) List(userID string, limit, offset int) ([]Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Batch
+	for _, b := range m.batches {
+		if b.UserID.String() == userID {
+			list = append(list, *b)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryRepo) Claim(now time.Time, claimFor time.Duration, limit int) ([]Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []Batch
+	for _, b := range m.batches {
+		if len(claimed) == limit {
+			break
+		}
+		if b.Status != StatusProcessing || (b.ClaimedUntil != nil && b.ClaimedUntil.After(now)) {
+			continue
+		}
+		until := now.Add(claimFor)
+		b.ClaimedUntil = &until
+		cp := *b
+		cp.Items = append([]Item(nil), b.Items...)
+		claimed = append(claimed, cp)
+	}
+	return claimed, nil
+}
+
+// TransferAll checks the whole batch fits the balance before paying any of it, which is what rolling back
+// on the first failure comes to
+func (m *memoryRepo) TransferAll(batch *Batch, senderNumber string) error {
+	sender, err := m.wallets.GetWalletByID(batch.WalletID.String())
+	if err != nil {
+		return err
+	}
+	available := sender.AvailableBalance()
+	for _, item := range batch.Items {
+		if available < item.Amount+item.Fee {
+			return &LineError{Line: item.Line, Err: errors.New("insufficient balance")}
+		}
+		available -= item.Amount + item.Fee
+	}
+	for _, item := range batch.Items {
+		if err := m.wallets.TransferFunds(batch.WalletID.String(), item.RecipientWalletID.String(), senderNumber, item.WalletNumber, item.Reference, item.Amount, item.Charge(), item.Description); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.batches[batch.ID].Items {
+		m.batches[batch.ID].Items[i].Status = ItemSuccess
+	}
+	return nil
+}
+
+func (m *memoryRepo) RecordItem(item *Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.batches[item.BatchID].Items
+	for i := range items {
+		if items[i].ID == item.ID {
+			items[i].Status = item.Status
+			items[i].Error = item.Error
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepo) Finish(batch *Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *batch
+	cp.Items = append([]Item(nil), batch.Items...)
+	m.batches[batch.ID] = &cp
+	return nil
+}
+
+// runBatch pays the batches accepted so far the way the Runner does, and returns batch id as GetBatch reports
+// it afterwards
+func runBatch(t *testing.T, env *wallettest.Env, h *Handler, id string) map[string]interface{} {
+	t.Helper()
+	_, err := NewRunner(env.Handler.Config, h.Repo, env.Repo).RunPending(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	rr := env.Do(h.GetBatch, "GET", "/wallet/batches/"+id, nil, map[string]string{"id": id})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	return wallettest.DecodeData(t, rr)
+}
+
+func TestBatchTransfers(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	repo := newMemoryRepo(env.Repo)
+	h := NewHandler(repo, env.Handler)
+	alice := env.AddWallet(t, "5555555555", "NGN")
+	bob := env.AddWallet(t, "6666666666", "NGN")
+	cedi := env.AddWallet(t, "7777777777", "GHS")
+
+	// every bad line is reported and nothing is paid
+	rr := env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+		Reference: "payroll-1",
+		Pin:       wallettest.Pin,
+		Items: []ItemRequest{
+			{WalletNumber: alice.WalletNumber, Amount: 20000},
+			{WalletNumber: cedi.WalletNumber, Amount: 20000},
+			{WalletNumber: "0000000000", Amount: 20000},
+			{WalletNumber: bob.WalletNumber, Amount: 1},
+		},
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+		Reference: "payroll-1",
+		Pin:       wallettest.Pin,
+		Items: []ItemRequest{
+			{WalletNumber: alice.WalletNumber, Amount: 20000},
+			{WalletNumber: cedi.WalletNumber, Amount: 20000},
+			{WalletNumber: "0000000000", Amount: 20000},
+		},
+	}, nil)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	assert.Len(t, wallettest.DecodeData(t, rr)["errors"], 2)
+	assert.Empty(t, repo.batches)
+	assert.Equal(t, int64(100000), env.Wallet.Balance)
+
+	// all or nothing rolls every line back when one can't be paid
+	rr = env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+		Reference: "payroll-2",
+		Pin:       wallettest.Pin,
+		Items: []ItemRequest{
+			{WalletNumber: alice.WalletNumber, Amount: 60000},
+			{WalletNumber: bob.WalletNumber, Amount: 60000},
+		},
+	}, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	data := wallettest.DecodeData(t, rr)
+	assert.Equal(t, "PROCESSING", data["status"])
+	assert.Equal(t, "FAILED", runBatch(t, env, h, data["id"].(string))["status"])
+	assert.Equal(t, int64(100000), env.Wallet.Balance)
+
+	// best effort pays what it can
+	best := CreateBatchRequest{
+		Reference: "payroll-3",
+		Mode:      ModeBestEffort,
+		Pin:       wallettest.Pin,
+		Items: []ItemRequest{
+			{WalletNumber: alice.WalletNumber, Amount: 60000},
+			{WalletNumber: bob.WalletNumber, Amount: 60000},
+			{WalletNumber: bob.WalletNumber, Amount: 30000},
+		},
+	}
+	rr = env.Do(h.CreateBatch, "POST", "/wallet/batches", best, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	id := wallettest.DecodeData(t, rr)["id"].(string)
+	data = runBatch(t, env, h, id)
+	assert.Equal(t, "PARTIAL", data["status"])
+	assert.Equal(t, float64(2), data["succeeded_count"])
+	assert.Equal(t, int64(10000), env.Wallet.Balance)
+	assert.Equal(t, int64(60000), alice.Balance)
+	assert.Equal(t, int64(30000), bob.Balance)
+
+	// the same reference returns the first batch without paying again
+	rr = env.Do(h.CreateBatch, "POST", "/wallet/batches", best, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, id, wallettest.DecodeData(t, rr)["id"])
+	assert.Equal(t, int64(10000), env.Wallet.Balance)
+
+	vars := map[string]string{"id": id}
+	rr = env.Do(h.GetBatchReport, "GET", "/wallet/batches/"+id+"/report", nil, vars)
+	require.Equal(t, http.StatusOK, rr.Code)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "line,wallet_number,amount,description,status,reference,error", lines[0])
+	assert.Contains(t, lines[2], "FAILED,bat-"+id+"-2,insufficient balance")
+
+	// someone else's batch reads as not found
+	env.User.ID = alice.UserID
+	rr = env.Do(h.GetBatch, "GET", "/wallet/batches/"+id, nil, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestBatchTransfersCharged(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	h := NewHandler(newMemoryRepo(env.Repo), env.Handler)
+	alice := env.AddWallet(t, "5555555555", "NGN")
+	bob := env.AddWallet(t, "6666666666", "NGN")
+	schedule := env.Fees.Add(fee.Schedule{Name: "Transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindFlat, Flat: 1000})
+
+	// the amounts fit the balance but not with a fee on each line
+	rr := env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+		Reference: "payroll-1",
+		Pin:       wallettest.Pin,
+		Items: []ItemRequest{
+			{WalletNumber: alice.WalletNumber, Amount: 50000},
+			{WalletNumber: bob.WalletNumber, Amount: 49500},
+		},
+	}, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.Equal(t, "FAILED", runBatch(t, env, h, wallettest.DecodeData(t, rr)["id"].(string))["status"])
+	assert.Equal(t, int64(100000), env.Wallet.Balance)
+
+	for _, mode := range []Mode{ModeAllOrNothing, ModeBestEffort} {
+		rr = env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+			Reference: "payroll-" + string(mode),
+			Mode:      mode,
+			Pin:       wallettest.Pin,
+			Items: []ItemRequest{
+				{WalletNumber: alice.WalletNumber, Amount: 20000},
+				{WalletNumber: bob.WalletNumber, Amount: 20000},
+			},
+		}, nil)
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		data := runBatch(t, env, h, wallettest.DecodeData(t, rr)["id"].(string))
+		assert.Equal(t, "COMPLETED", data["status"], mode)
+		assert.Equal(t, float64(2000), data["total_fee"], mode)
+
+		debit, err := env.Repo.GetTransactionByReference(fmt.Sprintf("bat-%s-1-debit", data["id"]))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), debit.Fee)
+		assert.Equal(t, &schedule, debit.FeeScheduleID)
+	}
+	assert.Equal(t, int64(100000-2*42000), env.Wallet.Balance)
+	assert.Equal(t, int64(40000), alice.Balance)
+}
+
+func TestBatchTransfersFromCSV(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	h := NewHandler(newMemoryRepo(env.Repo), env.Handler)
+	alice := env.AddWallet(t, "5555555555", "NGN")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("reference", "payroll-csv")
+	form.WriteField("pin", wallettest.Pin)
+	file, err := form.CreateFormFile("file", "payroll.csv")
+	require.NoError(t, err)
+	file.Write([]byte("amount,wallet_number,description\n20000,5555555555,March salary\n15000,5555555555,Bonus\n"))
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/wallet/batches", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, env.User))
+	rr := httptest.NewRecorder()
+	h.CreateBatch(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	data := runBatch(t, env, h, wallettest.DecodeData(t, rr)["id"].(string))
+	assert.Equal(t, "COMPLETED", data["status"])
+	assert.Equal(t, float64(35000), data["total_amount"])
+	assert.Equal(t, int64(35000), alice.Balance)
+	assert.Equal(t, "March salary", env.Repo.Transactions()[fmt.Sprintf("bat-%s-1-credit", data["id"])].Description)
+}
@@ -0,0 +1,84 @@
+package batch
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+)
+
+type Mode string
+
+const (
+	// ModeAllOrNothing makes every transfer in one database transaction, one failure rolls all of them back
+	ModeAllOrNothing Mode = "ALL_OR_NOTHING"
+	// ModeBestEffort makes each transfer on its own and carries on past the ones that fail
+	ModeBestEffort Mode = "BEST_EFFORT"
+)
+
+type Status string
+
+const (
+	StatusProcessing Status = "PROCESSING"
+	StatusCompleted  Status = "COMPLETED"
+	StatusPartial    Status = "PARTIAL"
+	StatusFailed     Status = "FAILED"
+)
+
+type ItemStatus string
+
+const (
+	ItemPending ItemStatus = "PENDING"
+	ItemSuccess ItemStatus = "SUCCESS"
+	ItemFailed  ItemStatus = "FAILED"
+)
+
+// Batch is many transfers from one wallet authorized with a single PIN check, made by the Runner after it is
+// accepted. Reference is the client's idempotency reference, a batch sent again under the same reference is
+// not run twice. ClaimedUntil is when the runner working on it may be presumed gone.
+type Batch struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null" json:"-"`
+	WalletID    uuid.UUID `gorm:"type:uuid;not null" json:"wallet_id"`
+	Reference   string    `gorm:"not null" json:"reference"`
+	Mode        Mode      `gorm:"not null" json:"mode"`
+	Status      Status    `gorm:"not null" json:"status"`
+	Currency    string    `gorm:"not null" json:"currency"`
+	TotalAmount int64     `gorm:"not null" json:"total_amount"`
+	// TotalFee is charged on top of TotalAmount, each item carries its own share
+	TotalFee       int64      `gorm:"not null;default:0" json:"total_fee"`
+	ItemCount      int        `gorm:"not null" json:"item_count"`
+	SucceededCount int        `gorm:"not null;default:0" json:"succeeded_count"`
+	FailedCount    int        `gorm:"not null;default:0" json:"failed_count"`
+	Items          []Item     `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+	ClaimedUntil   *time.Time `json:"-"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (Batch) TableName() string { return "transfer_batches" }
+
+// Item is one transfer of a batch, Line is its position in the submitted batch starting at 1 and Reference is
+// the transfer it made, whose legs end in -debit and -credit
+type Item struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"-"`
+	BatchID           uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	Line              int        `gorm:"not null" json:"line"`
+	WalletNumber      string     `gorm:"not null" json:"wallet_number"`
+	RecipientWalletID uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	Amount            int64      `gorm:"not null" json:"amount"`
+	Fee               int64      `gorm:"not null;default:0" json:"fee"`
+	FeeScheduleID     *uuid.UUID `gorm:"type:uuid" json:"fee_schedule_id,omitempty"`
+	Description       string     `json:"description,omitempty"`
+	Status            ItemStatus `gorm:"not null" json:"status"`
+	Reference         string     `gorm:"not null" json:"reference"`
+	Error             string     `json:"error,omitempty"`
+}
+
+func (Item) TableName() string { return "transfer_batch_items" }
+
+// Charge is the fee the item was priced at when its batch was submitted
+func (i Item) Charge() fee.Charge {
+	return fee.Charge{ScheduleID: i.FeeScheduleID, Amount: i.Fee}
+}
@@ -0,0 +1,150 @@
+package batch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LineError is a transfer of a batch that failed, Line is its position in the batch
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Err) }
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+type Repository interface {
+	Create(batch *Batch) error
+	Get(batchID string) (*Batch, error)
+	GetByReference(userID, reference string) (*Batch, error)
+	List(userID string, limit, offset int) ([]Batch, error)
+	Claim(now time.Time, claimFor time.Duration, limit int) ([]Batch, error)
+	TransferAll(batch *Batch, senderNumber string) error
+	RecordItem(item *Item) error
+	Finish(batch *Batch) error
+}
+
+type repository struct {
+	db    *gorm.DB
+	funds wallet.Funds
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db, funds: wallet.NewFunds(db)}
+}
+
+func (r *repository) Create(batch *Batch) error {
+	return r.db.Create(batch).Error
+}
+
+func (r *repository) Get(batchID string) (*Batch, error) {
+	var batch Batch
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("line asc") }).
+		Where("id = ?", batchID).
+		First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *repository) GetByReference(userID, reference string) (*Batch, error) {
+	var batch Batch
+	if err := r.db.Where("user_id = ? AND reference = ?", userID, reference).First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return r.Get(batch.ID.String())
+}
+
+// List leaves out the items, Get has them
+func (r *repository) List(userID string, limit, offset int) ([]Batch, error) {
+	var batches []Batch
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&batches).Error
+	return batches, err
+}
+
+// Claim hands out up to limit batches still PROCESSING that no one has claimed in the last claimFor, with
+// their items, and claims them until now plus claimFor. A batch whose runner stopped part way is handed out
+// again once its claim lapses.
+func (r *repository) Claim(now time.Time, claimFor time.Duration, limit int) ([]Batch, error) {
+	var batches []Batch
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (claimed_until IS NULL OR claimed_until <= ?)", StatusProcessing, now).
+			Order("created_at asc").
+			Limit(limit).
+			Find(&batches).Error; err != nil {
+			return err
+		}
+		if len(batches) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(batches))
+		for i, b := range batches {
+			ids[i] = b.ID
+		}
+		if err := tx.Model(&Batch{}).Where("id IN ?", ids).UpdateColumn("claimed_until", now.Add(claimFor)).Error; err != nil {
+			return err
+		}
+
+		for i := range batches {
+			if err := tx.Where("batch_id = ?", batches[i].ID).Order("line asc").Find(&batches[i].Items).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return batches, err
+}
+
+// TransferAll makes every transfer of an ALL_OR_NOTHING batch and marks its items paid in one database
+// transaction, the first that fails rolls back the ones before it and is returned with its line
+func (r *repository) TransferAll(batch *Batch, senderNumber string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range batch.Items {
+			err := r.funds.Transfer(tx, batch.WalletID.String(), item.RecipientWalletID.String(), senderNumber, item.WalletNumber, item.Reference, item.Amount, item.Charge(), item.Description)
+			if err != nil {
+				return &LineError{Line: item.Line, Err: err}
+			}
+		}
+		return tx.Model(&Item{}).Where("batch_id = ?", batch.ID).Update("status", ItemSuccess).Error
+	})
+}
+
+// RecordItem saves the outcome of one item as soon as it is known, so a resumed batch skips it
+func (r *repository) RecordItem(item *Item) error {
+	return r.db.Model(&Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"status": item.Status,
+		"error":  item.Error,
+	}).Error
+}
+
+// Finish saves the outcome of a batch and of each of its items
+func (r *repository) Finish(batch *Batch) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range batch.Items {
+			err := tx.Model(&Item{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"status": item.Status,
+				"error":  item.Error,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&Batch{}).Where("id = ?", batch.ID).Updates(map[string]interface{}{
+			"status":          batch.Status,
+			"succeeded_count": batch.SucceededCount,
+			"failed_count":    batch.FailedCount,
+			"completed_at":    batch.CompletedAt,
+		}).Error
+	})
+}
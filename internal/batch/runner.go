@@ -0,0 +1,196 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+)
+
+const claimSize = 10
+
+// claimDuration is how long a runner has a batch to itself, one still PROCESSING after that is taken to have
+// lost its runner and is resumed
+const claimDuration = 10 * time.Minute
+
+// Runner makes the transfers of batches accepted by CreateBatch. Each item's outcome is saved as soon as it is
+// known, so a batch whose runner stopped part way is resumed from the first item left PENDING, and an item
+// that was paid before its outcome was saved is found by its transfer reference rather than paid again.
+type Runner struct {
+	Config  config.Config
+	Repo    Repository
+	Wallets wallet.Repository
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRunner(cfg config.Config, repo Repository, wallets wallet.Repository) *Runner {
+	return &Runner{Config: cfg, Repo: repo, Wallets: wallets, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (b *Runner) Start() {
+	logger.Info("Starting batch runner...", logger.Fields{"interval": b.Config.BatchInterval.String()})
+	go b.run()
+}
+
+// Stop waits for the batch in progress to finish
+func (b *Runner) Stop(ctx context.Context) error {
+	close(b.stop)
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Runner) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.Config.BatchInterval)
+	defer ticker.Stop()
+
+	for {
+		finished, err := b.RunPending(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("BatchRunner: Sweep failed", logger.Fields{"error": err.Error()})
+		} else if finished > 0 {
+			logger.Info("BatchRunner: Sweep finished", logger.Fields{"finished": finished})
+		}
+
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending claims the batches waiting to run at now, new ones and ones whose runner stopped part way, and
+// runs them to the end. It returns how many were finished.
+func (b *Runner) RunPending(ctx context.Context, now time.Time) (int, error) {
+	batches, err := b.Repo.Claim(now, claimDuration, claimSize)
+	if err != nil {
+		return 0, err
+	}
+
+	finished := 0
+	for i := range batches {
+		if ctx.Err() != nil {
+			return finished, ctx.Err()
+		}
+		// left PROCESSING, it is picked up again once the claim lapses
+		if err := b.runBatch(&batches[i]); err != nil {
+			logger.Error("BatchRunner: Batch stopped part way", logger.Fields{"error": err.Error(), "batch_id": batches[i].ID.String()})
+			continue
+		}
+		finished++
+	}
+
+	return finished, nil
+}
+
+// runBatch makes the batch's outstanding transfers and records the outcome of the batch
+func (b *Runner) runBatch(batch *Batch) error {
+	sender, err := b.Wallets.GetWalletByID(batch.WalletID.String())
+	if err != nil {
+		return err
+	}
+
+	if batch.Mode == ModeAllOrNothing {
+		b.transferAll(batch, sender)
+	} else if err := b.transferEach(batch, sender); err != nil {
+		return err
+	}
+
+	batch.SucceededCount, batch.FailedCount = 0, 0
+	for _, item := range batch.Items {
+		if item.Status == ItemSuccess {
+			batch.SucceededCount++
+		} else {
+			batch.FailedCount++
+		}
+	}
+
+	switch {
+	case batch.FailedCount == 0:
+		batch.Status = StatusCompleted
+	case batch.SucceededCount == 0:
+		batch.Status = StatusFailed
+	default:
+		batch.Status = StatusPartial
+	}
+	now := time.Now()
+	batch.CompletedAt = &now
+
+	if err := b.Repo.Finish(batch); err != nil {
+		return err
+	}
+	if batch.FailedCount > 0 {
+		logger.Warn("BatchRunner: Batch finished with failures", logger.Fields{"batch_id": batch.ID.String(), "status": string(batch.Status), "failed": batch.FailedCount})
+	}
+	return nil
+}
+
+// transferAll makes every transfer of an ALL_OR_NOTHING batch at once. TransferAll marks the items paid with
+// the transfers, so items no longer PENDING mean the batch was already paid or already failed.
+func (b *Runner) transferAll(batch *Batch, sender *wallet.Wallet) {
+	for _, item := range batch.Items {
+		if item.Status != ItemPending {
+			return
+		}
+	}
+
+	err := b.Repo.TransferAll(batch, sender.WalletNumber)
+	var lineErr *LineError
+	if err != nil && !errors.As(err, &lineErr) {
+		lineErr = &LineError{Err: err}
+	}
+
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		switch {
+		case err == nil:
+			item.Status = ItemSuccess
+		case item.Line == lineErr.Line:
+			item.Status = ItemFailed
+			item.Error = lineErr.Err.Error()
+		default:
+			item.Status = ItemFailed
+			item.Error = "not paid, the batch was rolled back"
+		}
+	}
+	if err != nil {
+		logger.Warn("BatchRunner: Batch rolled back", logger.Fields{"error": err.Error(), "batch_id": batch.ID.String()})
+	}
+}
+
+// transferEach makes the outstanding transfers of a BEST_EFFORT batch one at a time, carrying on past the ones
+// that fail. An outcome that can't be saved stops the batch so it is resumed later.
+func (b *Runner) transferEach(batch *Batch, sender *wallet.Wallet) error {
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.Status != ItemPending {
+			continue
+		}
+
+		item.Status = ItemSuccess
+		if _, err := b.Wallets.GetTransactionByReference(item.Reference + "-debit"); err != nil {
+			recipient := &wallet.Wallet{ID: item.RecipientWalletID, WalletNumber: item.WalletNumber, Currency: batch.Currency}
+			if err := wallet.SendTransfer(b.Wallets, sender, recipient, item.Reference, item.Amount, item.Charge(), item.Description); err != nil {
+				item.Status = ItemFailed
+				item.Error = err.Error()
+			}
+		}
+
+		if err := b.Repo.RecordItem(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+)
+
+func TestRunnerResumes(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	repo := newMemoryRepo(env.Repo)
+	h := NewHandler(repo, env.Handler)
+	alice := env.AddWallet(t, "5555555555", "NGN")
+	bob := env.AddWallet(t, "6666666666", "NGN")
+
+	submit := func(reference string, mode Mode) uuid.UUID {
+		rr := env.Do(h.CreateBatch, "POST", "/wallet/batches", CreateBatchRequest{
+			Reference: reference,
+			Mode:      mode,
+			Pin:       wallettest.Pin,
+			Items: []ItemRequest{
+				{WalletNumber: alice.WalletNumber, Amount: 20000},
+				{WalletNumber: bob.WalletNumber, Amount: 20000},
+				{WalletNumber: alice.WalletNumber, Amount: 10000},
+			},
+		}, nil)
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		return uuid.MustParse(wallettest.DecodeData(t, rr)["id"].(string))
+	}
+
+	// a runner claims a best effort batch, records the first line, pays the second and stops
+	now := time.Now()
+	id := submit("payroll-1", ModeBestEffort)
+	claimed, err := repo.Claim(now, claimDuration, claimSize)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	first, second := claimed[0].Items[0], claimed[0].Items[1]
+	require.NoError(t, wallet.SendTransfer(env.Repo, env.Wallet, alice, first.Reference, first.Amount, fee.Charge{}, ""))
+	first.Status = ItemSuccess
+	require.NoError(t, repo.RecordItem(&first))
+	require.NoError(t, wallet.SendTransfer(env.Repo, env.Wallet, bob, second.Reference, second.Amount, fee.Charge{}, ""))
+
+	// the batch is left alone while the claim holds
+	runner := NewRunner(env.Handler.Config, repo, env.Repo)
+	finished, err := runner.RunPending(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, finished)
+	assert.Equal(t, StatusProcessing, repo.batches[id].Status)
+
+	// and resumed once it lapses, without paying the first two lines again
+	finished, err = runner.RunPending(context.Background(), now.Add(claimDuration))
+	require.NoError(t, err)
+	assert.Equal(t, 1, finished)
+	batch := repo.batches[id]
+	assert.Equal(t, StatusCompleted, batch.Status)
+	assert.Equal(t, 3, batch.SucceededCount)
+	assert.Equal(t, int64(50000), env.Wallet.Balance)
+	assert.Equal(t, int64(30000), alice.Balance)
+	assert.Equal(t, int64(20000), bob.Balance)
+
+	// an all or nothing batch paid before its outcome was saved is only finished
+	id = submit("payroll-2", ModeAllOrNothing)
+	claimed, err = repo.Claim(now, claimDuration, claimSize)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.NoError(t, repo.TransferAll(&claimed[0], env.Wallet.WalletNumber))
+
+	finished, err = runner.RunPending(context.Background(), now.Add(claimDuration))
+	require.NoError(t, err)
+	assert.Equal(t, 1, finished)
+	assert.Equal(t, StatusCompleted, repo.batches[id].Status)
+	assert.Equal(t, int64(0), env.Wallet.Balance)
+	assert.Equal(t, int64(60000), alice.Balance)
+}
@@ -0,0 +1,297 @@
+package beneficiary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const bankListCacheTTL = 24 * time.Hour
+
+type Handler struct {
+	Config      config.Config
+	Repo        Repository
+	RedisClient *events.RedisClient
+	Paystack    paystack.Client
+}
+
+func NewHandler(cfg config.Config, repo Repository, redisClient *events.RedisClient, paystackClient paystack.Client) *Handler {
+	return &Handler{Config: cfg, Repo: repo, RedisClient: redisClient, Paystack: paystackClient}
+}
+
+func (h *Handler) ListBanks(w http.ResponseWriter, r *http.Request) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency == "" {
+		currency = config.DefaultCurrency
+	}
+
+	banks, err := h.getBanks(r.Context(), currency)
+	if err != nil {
+		logger.Error("Failed to fetch bank list", logger.Fields{"error": err.Error(), "currency": currency})
+		utils.BuildErrorResponse(w, http.StatusBadGateway, "Failed to fetch bank list", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Banks retrieved", banks)
+}
+
+func (h *Handler) ResolveAccount(w http.ResponseWriter, r *http.Request) {
+	accountNumber := r.URL.Query().Get("account_number")
+	bankCode := r.URL.Query().Get("bank_code")
+	if accountNumber == "" || bankCode == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "account_number and bank_code are required", nil)
+		return
+	}
+
+	accountName, err := h.resolveAccount(r.Context(), accountNumber, bankCode)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusUnprocessableEntity, "Could not resolve account", map[string]string{"error": err.Error()})
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Account resolved", map[string]string{
+		"account_number": accountNumber,
+		"account_name":   accountName,
+		"bank_code":      bankCode,
+	})
+}
+
+type CreateBeneficiaryRequest struct {
+	Type          string `json:"type"`
+	Nickname      string `json:"nickname"`
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	WalletNumber  string `json:"wallet_number"`
+	// Currency of a BANK account, the default currency when left out
+	Currency string `json:"currency"`
+}
+
+func (h *Handler) CreateBeneficiary(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CreateBeneficiaryRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Nickname == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "nickname is required", nil)
+		return
+	}
+
+	existing, err := h.Repo.ListByUserID(usr.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch beneficiaries", nil)
+		return
+	}
+
+	b := Beneficiary{
+		UserID:   usr.ID,
+		Nickname: req.Nickname,
+	}
+
+	switch BeneficiaryType(req.Type) {
+	case TypeBank:
+		if req.AccountNumber == "" || req.BankCode == "" {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "account_number and bank_code are required", nil)
+			return
+		}
+
+		currency := strings.ToUpper(req.Currency)
+		if currency == "" {
+			currency = config.DefaultCurrency
+		}
+		if !slices.Contains(config.SupportedCurrencies, currency) {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Unsupported currency", map[string]interface{}{
+				"supported": config.SupportedCurrencies,
+			})
+			return
+		}
+
+		for _, e := range existing {
+			if e.Type == TypeBank && e.AccountNumber == req.AccountNumber && e.BankCode == req.BankCode {
+				utils.BuildErrorResponse(w, http.StatusConflict, "Beneficiary already exists", nil)
+				return
+			}
+		}
+
+		accountName, err := h.resolveAccount(r.Context(), req.AccountNumber, req.BankCode)
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusUnprocessableEntity, "Could not resolve account", map[string]string{"error": err.Error()})
+			return
+		}
+
+		b.Type = TypeBank
+		b.AccountNumber = req.AccountNumber
+		b.AccountName = accountName
+		b.BankCode = req.BankCode
+		b.BankName = h.bankName(r.Context(), req.BankCode, currency)
+		b.Currency = currency
+
+	case TypeWallet:
+		if req.WalletNumber == "" {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "wallet_number is required", nil)
+			return
+		}
+
+		for _, e := range existing {
+			if e.Type == TypeWallet && e.WalletNumber == req.WalletNumber {
+				utils.BuildErrorResponse(w, http.StatusConflict, "Beneficiary already exists", nil)
+				return
+			}
+		}
+
+		exists, err := h.Repo.WalletNumberExists(req.WalletNumber)
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to look up wallet", nil)
+			return
+		}
+		if !exists {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+			return
+		}
+
+		b.Type = TypeWallet
+		b.WalletNumber = req.WalletNumber
+
+	default:
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "type must be BANK or WALLET", nil)
+		return
+	}
+
+	if err := h.Repo.Create(&b); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to save beneficiary", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Beneficiary saved", b)
+}
+
+func (h *Handler) ListBeneficiaries(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	list, err := h.Repo.ListByUserID(usr.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch beneficiaries", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Beneficiaries retrieved", list)
+}
+
+func (h *Handler) GetBeneficiary(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	b, err := h.Repo.GetByID(mux.Vars(r)["id"], usr.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Beneficiary not found", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Beneficiary retrieved", b)
+}
+
+type UpdateBeneficiaryRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+func (h *Handler) UpdateBeneficiary(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req UpdateBeneficiaryRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Nickname == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "nickname is required", nil)
+		return
+	}
+
+	if err := h.Repo.UpdateNickname(mux.Vars(r)["id"], usr.ID.String(), req.Nickname); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Beneficiary not found", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to update beneficiary", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Beneficiary updated", nil)
+}
+
+func (h *Handler) DeleteBeneficiary(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	if err := h.Repo.Delete(mux.Vars(r)["id"], usr.ID.String()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Beneficiary not found", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to delete beneficiary", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Beneficiary deleted", nil)
+}
+
+// getBanks serves the bank list from redis and falls back to paystack on a cache miss
+func (h *Handler) getBanks(ctx context.Context, currency string) ([]paystack.Bank, error) {
+	cacheKey := "paystack_banks:" + currency
+
+	if cached, err := h.RedisClient.Client.Get(ctx, cacheKey).Bytes(); err == nil {
+		var banks []paystack.Bank
+		if err := json.Unmarshal(cached, &banks); err == nil {
+			return banks, nil
+		}
+	}
+
+	banks, err := h.Paystack.ListBanks(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(banks); err == nil {
+		if err := h.RedisClient.Client.Set(ctx, cacheKey, data, bankListCacheTTL).Err(); err != nil {
+			logger.Warn("Failed to cache bank list", logger.Fields{"error": err.Error()})
+		}
+	}
+
+	return banks, nil
+}
+
+func (h *Handler) bankName(ctx context.Context, bankCode, currency string) string {
+	banks, err := h.getBanks(ctx, currency)
+	if err != nil {
+		return ""
+	}
+	for _, b := range banks {
+		if b.Code == bankCode {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+func (h *Handler) resolveAccount(ctx context.Context, accountNumber, bankCode string) (string, error) {
+	account, err := h.Paystack.ResolveAccount(ctx, accountNumber, bankCode)
+	if err != nil {
+		return "", err
+	}
+	return account.AccountName, nil
+}
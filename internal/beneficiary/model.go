@@ -0,0 +1,30 @@
+package beneficiary
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BeneficiaryType string
+
+const (
+	TypeBank   BeneficiaryType = "BANK"
+	TypeWallet BeneficiaryType = "WALLET"
+)
+
+type Beneficiary struct {
+	ID            uuid.UUID       `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID        uuid.UUID       `gorm:"type:uuid;not null" json:"user_id"`
+	Type          BeneficiaryType `gorm:"not null" json:"type"`
+	Nickname      string          `gorm:"not null" json:"nickname"`
+	AccountNumber string          `json:"account_number,omitempty"`
+	AccountName   string          `json:"account_name,omitempty"`
+	BankCode      string          `json:"bank_code,omitempty"`
+	BankName      string          `json:"bank_name,omitempty"`
+	Currency      string          `json:"currency,omitempty"`
+	RecipientCode string          `json:"-"`
+	WalletNumber  string          `json:"wallet_number,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
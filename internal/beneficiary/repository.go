@@ -0,0 +1,74 @@
+package beneficiary
+
+import (
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(b *Beneficiary) error
+	GetByID(id string, userID string) (*Beneficiary, error)
+	ListByUserID(userID string) ([]Beneficiary, error)
+	UpdateNickname(id string, userID string, nickname string) error
+	UpdateRecipientCode(id string, recipientCode string) error
+	Delete(id string, userID string) error
+	WalletNumberExists(number string) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(b *Beneficiary) error {
+	return r.db.Create(b).Error
+}
+
+func (r *repository) GetByID(id string, userID string) (*Beneficiary, error) {
+	var b Beneficiary
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *repository) ListByUserID(userID string) ([]Beneficiary, error) {
+	var list []Beneficiary
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&list).Error
+	return list, err
+}
+
+func (r *repository) UpdateNickname(id string, userID string, nickname string) error {
+	result := r.db.Model(&Beneficiary{}).Where("id = ? AND user_id = ?", id, userID).Update("nickname", nickname)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *repository) UpdateRecipientCode(id string, recipientCode string) error {
+	return r.db.Model(&Beneficiary{}).Where("id = ?", id).Update("recipient_code", recipientCode).Error
+}
+
+func (r *repository) Delete(id string, userID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&Beneficiary{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// WalletNumberExists checks the wallets table directly, the wallet package depends on this one
+func (r *repository) WalletNumberExists(number string) (bool, error) {
+	var count int64
+	err := r.db.Table("wallets").Where("wallet_number = ?", number).Count(&count).Error
+	return count > 0, err
+}
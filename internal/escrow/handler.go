@@ -0,0 +1,249 @@
+package escrow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// Handler serves the escrows a user sent or receives, Wallets checks amounts, PINs and recipients the way a
+// transfer does
+type Handler struct {
+	Repo    Repository
+	Wallets *wallet.Handler
+}
+
+func NewHandler(repo Repository, wallets *wallet.Handler) *Handler {
+	return &Handler{Repo: repo, Wallets: wallets}
+}
+
+type CreateEscrowRequest struct {
+	WalletNumber string `json:"wallet_number"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Description  string `json:"description"`
+	// ReleaseAt pays the recipient if the escrow is not disputed by then, EscrowDefaultRelease from now
+	// applies when it is left out
+	ReleaseAt *time.Time `json:"release_at"`
+	Pin       string     `json:"pin"`
+}
+
+// CreateEscrow takes money out of the user's wallet and keeps it in escrow for the recipient
+func (h *Handler) CreateEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CreateEscrowRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := wallet.ResolveCurrency(w, req.Currency)
+	if !ok || !h.Wallets.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	now := time.Now()
+	releaseAt := now.Add(h.Wallets.Config.EscrowDefaultRelease)
+	if req.ReleaseAt != nil {
+		releaseAt = *req.ReleaseAt
+	}
+	if !releaseAt.After(now) || releaseAt.After(now.Add(h.Wallets.Config.EscrowMaxRelease)) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("release_at must be in the future and at most %d days away", int(h.Wallets.Config.EscrowMaxRelease.Hours()/24)), nil)
+		return
+	}
+
+	sender, ok := h.Wallets.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	if !h.Wallets.VerifyPin(w, sender, req.Pin) {
+		return
+	}
+
+	recipient, ok := h.Wallets.TransferRecipient(w, sender, req.WalletNumber)
+	if !ok {
+		return
+	}
+
+	escrow := Escrow{
+		SenderUserID:          usr.ID,
+		SenderWalletID:        sender.ID,
+		SenderWalletNumber:    sender.WalletNumber,
+		RecipientUserID:       recipient.UserID,
+		RecipientWalletID:     recipient.ID,
+		RecipientWalletNumber: recipient.WalletNumber,
+		Amount:                req.Amount,
+		Currency:              sender.Currency,
+		Description:           req.Description,
+		ReleaseAt:             releaseAt,
+	}
+
+	if err := h.Repo.Create(&escrow); err != nil {
+		if err.Error() == "insufficient balance" {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
+		} else {
+			logger.Error("Failed to create escrow", logger.Fields{"error": err.Error(), "wallet_id": sender.ID.String()})
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create escrow", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Escrow funded", escrow)
+}
+
+// ListEscrows lists the escrows the user sent or receives
+func (h *Handler) ListEscrows(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	escrows, err := h.Repo.List(usr.ID.String(), Status(r.URL.Query().Get("status")), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch escrows", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Escrows", map[string]interface{}{
+		"escrows": escrows,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	escrow, _, ok := h.escrowParty(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Escrow", escrow)
+}
+
+type ReleaseEscrowRequest struct {
+	Pin string `json:"pin"`
+}
+
+// ReleaseEscrow pays the recipient on the sender's say, a disputed escrow can be released too
+func (h *Handler) ReleaseEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req ReleaseEscrowRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	escrow, party, ok := h.escrowParty(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if party != BySender {
+		utils.BuildErrorResponse(w, http.StatusForbidden, "Only the sender can release an escrow", nil)
+		return
+	}
+
+	sender, err := h.Wallets.Repo.GetWalletByID(escrow.SenderWalletID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+	if !h.Wallets.VerifyPin(w, sender, req.Pin) {
+		return
+	}
+
+	h.moveEscrow(w, escrow, StatusReleased, party, "")
+}
+
+type DisputeEscrowRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DisputeEscrow stops an escrow from being released at its release date until an admin resolves it, either
+// party can raise one
+func (h *Handler) DisputeEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req DisputeEscrowRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Reason == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "reason is required", nil)
+		return
+	}
+
+	escrow, party, ok := h.escrowParty(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	h.moveEscrow(w, escrow, StatusDisputed, party, req.Reason)
+}
+
+// RefundEscrow lets the recipient send an escrow back to the sender, e.g. when they can't deliver
+func (h *Handler) RefundEscrow(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	escrow, party, ok := h.escrowParty(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if party != ByRecipient {
+		utils.BuildErrorResponse(w, http.StatusForbidden, "Only the recipient can refund an escrow", nil)
+		return
+	}
+
+	h.moveEscrow(w, escrow, StatusRefunded, party, "")
+}
+
+func (h *Handler) moveEscrow(w http.ResponseWriter, escrow *Escrow, status Status, by Actor, note string) {
+	moved, err := h.Repo.Move(escrow.ID.String(), status, by, note, time.Now())
+	if err != nil {
+		WriteError(w, err, escrow.ID.String())
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Escrow "+strings.ToLower(string(moved.Status)), moved)
+}
+
+// escrowParty loads an escrow the user sent or receives and says which side they are on, any other escrow
+// reads as not found
+func (h *Handler) escrowParty(w http.ResponseWriter, usr user.User, escrowID string) (*Escrow, Actor, bool) {
+	escrow, err := h.Repo.Get(escrowID)
+	switch {
+	case err != nil:
+	case escrow.SenderUserID == usr.ID:
+		return escrow, BySender, true
+	case escrow.RecipientUserID == usr.ID:
+		return escrow, ByRecipient, true
+	}
+	utils.BuildErrorResponse(w, http.StatusNotFound, "Escrow not found", nil)
+	return nil, "", false
+}
+
+// WriteError maps Move's errors onto responses, the admin resolution shares it
+func WriteError(w http.ResponseWriter, err error, escrowID string) {
+	switch {
+	case errors.Is(err, ErrTransition):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Escrow can't make that change in its current state", nil)
+	case errors.Is(err, ErrDeadlinePassed):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Escrow release date has passed, it can no longer be disputed", nil)
+	default:
+		logger.Error("Escrow operation failed", logger.Fields{"error": err.Error(), "escrow_id": escrowID})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Escrow operation failed", nil)
+	}
+}
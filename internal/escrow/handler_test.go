@@ -0,0 +1,206 @@
+package escrow
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/ledger"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository holding its money through wallets
+type memoryRepo struct {
+	mu      sync.Mutex
+	wallets *wallettest.Repository
+	escrows map[uuid.UUID]*Escrow
+}
+
+func newMemoryRepo(wallets *wallettest.Repository) *memoryRepo {
+	return &memoryRepo{wallets: wallets, escrows: make(map[uuid.UUID]*Escrow)}
+}
+
+func (m *memoryRepo) recordLegs(legs []wallet.Transaction) {
+	for i := range legs {
+		m.wallets.CreateTransaction(&legs[i])
+	}
+}
+
+func (m *memoryRepo) Create(escrow *Escrow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	escrow.ID = uuid.New()
+	escrow.Status = StatusFunded
+	reference := escrowReference(escrow, "fund")
+	if err := m.wallets.ToSystem(nil, escrow.SenderWalletID.String(), ledger.SystemEscrow, reference, "Escrow funded", escrow.Amount); err != nil {
+		return err
+	}
+	cp := *escrow
+	m.escrows[escrow.ID] = &cp
+	m.recordLegs(legs(escrow, reference, "Escrow funded", escrow.Amount, 0))
+	return nil
+}
+
+func (m *memoryRepo) Get(escrowID string) (*Escrow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(escrowID)
+	if err != nil {
+		return nil, err
+	}
+	escrow, ok := m.escrows[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *escrow
+	return &cp, nil
+}
+
+func (m *memoryRepo) List(userID string, status Status, limit, offset int) ([]Escrow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Escrow
+	for _, e := range m.escrows {
+		if (userID == "" || e.SenderUserID.String() == userID || e.RecipientUserID.String() == userID) && (status == "" || e.Status == status) {
+			list = append(list, *e)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryRepo) GetDue(now time.Time, limit int) ([]Escrow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Escrow
+	for _, e := range m.escrows {
+		if e.Status == StatusFunded && !e.ReleaseAt.After(now) {
+			due = append(due, *e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ReleaseAt.Before(due[j].ReleaseAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (m *memoryRepo) Move(escrowID string, status Status, by Actor, note string, now time.Time) (*Escrow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	escrow := m.escrows[uuid.MustParse(escrowID)]
+	if !escrow.CanMoveTo(status) {
+		return nil, ErrTransition
+	}
+	if status == StatusDisputed && !now.Before(escrow.ReleaseAt) {
+		return nil, ErrDeadlinePassed
+	}
+	event := "dispute"
+	var payee uuid.UUID
+	switch status {
+	case StatusDisputed:
+		escrow.DisputedBy, escrow.DisputeReason = by, note
+	case StatusReleased:
+		event, payee = "release", escrow.RecipientWalletID
+	case StatusRefunded:
+		event, payee = "refund", escrow.SenderWalletID
+	}
+	reference := escrowReference(escrow, event)
+	var senderAmount, recipientAmount int64
+	if payee != uuid.Nil {
+		if err := m.wallets.FromSystem(nil, payee.String(), ledger.SystemEscrow, reference, "Escrow "+string(status), escrow.Amount); err != nil {
+			return nil, err
+		}
+		if payee == escrow.SenderWalletID {
+			senderAmount = escrow.Amount
+		} else {
+			recipientAmount = escrow.Amount
+		}
+		escrow.ClosedBy, escrow.Resolution, escrow.ClosedAt = by, note, &now
+	}
+	escrow.Status = status
+	m.recordLegs(legs(escrow, reference, "Escrow "+string(status), senderAmount, recipientAmount))
+	cp := *escrow
+	return &cp, nil
+}
+
+func TestEscrow(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	h := NewHandler(newMemoryRepo(env.Repo), env.Handler)
+	buyer := env.User
+	sellerWallet := env.AddWallet(t, "9999999999", "NGN")
+	seller := user.User{ID: sellerWallet.UserID}
+
+	create := func(amount int64) string {
+		t.Helper()
+		env.User = buyer
+		rr := env.Do(h.CreateEscrow, "POST", "/wallet/escrows", CreateEscrowRequest{WalletNumber: sellerWallet.WalletNumber, Amount: amount, Description: "Used bike", Pin: wallettest.Pin}, nil)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		return wallettest.DecodeData(t, rr)["id"].(string)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	rr := env.Do(h.CreateEscrow, "POST", "/wallet/escrows", CreateEscrowRequest{WalletNumber: sellerWallet.WalletNumber, Amount: 40000, ReleaseAt: &past, Pin: wallettest.Pin}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// the sender releases: funded then released, with a leg on each wallet both times
+	id := create(40000)
+	vars := map[string]string{"id": id}
+	assert.Equal(t, int64(60000), env.Wallet.Balance)
+	assert.Equal(t, int64(0), sellerWallet.Balance)
+
+	env.User = seller
+	rr = env.Do(h.ReleaseEscrow, "POST", "/wallet/escrows/"+id+"/release", ReleaseEscrowRequest{Pin: wallettest.Pin}, vars)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	env.User = buyer
+	rr = env.Do(h.ReleaseEscrow, "POST", "/wallet/escrows/"+id+"/release", ReleaseEscrowRequest{Pin: wallettest.Pin}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "RELEASED", wallettest.DecodeData(t, rr)["status"])
+	assert.Equal(t, int64(40000), sellerWallet.Balance)
+
+	rr = env.Do(h.DisputeEscrow, "POST", "/wallet/escrows/"+id+"/dispute", DisputeEscrowRequest{Reason: "Too late"}, vars)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	txs := env.Repo.Transactions()
+	for _, event := range []string{"fund", "release"} {
+		debit, ok := txs["esc-"+id+"-"+event+"-debit"]
+		require.True(t, ok, event)
+		credit, ok := txs["esc-"+id+"-"+event+"-credit"]
+		require.True(t, ok, event)
+		assert.Equal(t, env.Wallet.ID, debit.WalletID)
+		assert.Equal(t, sellerWallet.ID, credit.WalletID)
+		assert.Equal(t, wallet.CategoryEscrow, credit.Category)
+		assert.Equal(t, int64(40000), credit.Escrow.Amount)
+	}
+	assert.Equal(t, int64(0), txs["esc-"+id+"-fund-credit"].Amount, "the seller got nothing when it was funded")
+	assert.Equal(t, int64(40000), txs["esc-"+id+"-release-credit"].Amount)
+
+	// the seller disputes a second escrow, then refunds it
+	id = create(30000)
+	vars = map[string]string{"id": id}
+	env.User = seller
+	rr = env.Do(h.DisputeEscrow, "POST", "/wallet/escrows/"+id+"/dispute", DisputeEscrowRequest{Reason: "Bike was stolen"}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "RECIPIENT", wallettest.DecodeData(t, rr)["disputed_by"])
+
+	rr = env.Do(h.RefundEscrow, "POST", "/wallet/escrows/"+id+"/refund", nil, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "REFUNDED", wallettest.DecodeData(t, rr)["status"])
+	assert.Equal(t, int64(60000), env.Wallet.Balance)
+	refund := env.Repo.Transactions()["esc-"+id+"-refund-credit"]
+	assert.Equal(t, wallet.TransactionCredit, refund.Type)
+	assert.Equal(t, env.Wallet.ID, refund.WalletID)
+
+	// no one else can see an escrow
+	env.User = user.User{ID: uuid.New()}
+	rr = env.Do(h.GetEscrow, "GET", "/wallet/escrows/"+id, nil, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,67 @@
+package escrow
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	StatusFunded   Status = "FUNDED"
+	StatusDisputed Status = "DISPUTED"
+	StatusReleased Status = "RELEASED"
+	StatusRefunded Status = "REFUNDED"
+)
+
+// Actor is who moved an escrow to its current state
+type Actor string
+
+const (
+	BySender    Actor = "SENDER"
+	ByRecipient Actor = "RECIPIENT"
+	ByAdmin     Actor = "ADMIN"
+	ByDeadline  Actor = "DEADLINE"
+)
+
+// transitions lists the states each state can move to, RELEASED and REFUNDED are final
+var transitions = map[Status][]Status{
+	StatusFunded:   {StatusDisputed, StatusReleased, StatusRefunded},
+	StatusDisputed: {StatusReleased, StatusRefunded},
+}
+
+// Escrow keeps a sender's money in the ESCROW system account until the sender releases it to the recipient,
+// the recipient refunds it, a dispute over it is resolved or ReleaseAt passes, which releases it unless it
+// is disputed
+type Escrow struct {
+	ID                    uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	SenderUserID          uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	SenderWalletID        uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	SenderWalletNumber    string     `gorm:"not null" json:"sender_wallet_number"`
+	RecipientUserID       uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	RecipientWalletID     uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	RecipientWalletNumber string     `gorm:"not null" json:"recipient_wallet_number"`
+	Amount                int64      `gorm:"not null" json:"amount"`
+	Currency              string     `gorm:"not null" json:"currency"`
+	Description           string     `json:"description,omitempty"`
+	Status                Status     `gorm:"not null" json:"status"`
+	ReleaseAt             time.Time  `gorm:"not null" json:"release_at"`
+	DisputedBy            Actor      `json:"disputed_by,omitempty"`
+	DisputeReason         string     `json:"dispute_reason,omitempty"`
+	ClosedBy              Actor      `json:"closed_by,omitempty"`
+	Resolution            string     `json:"resolution,omitempty"`
+	ClosedAt              *time.Time `json:"closed_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// CanMoveTo reports whether the escrow's state machine allows a change to status
+func (e *Escrow) CanMoveTo(status Status) bool {
+	for _, next := range transitions[e.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+)
+
+// releaseBatchSize bounds how many escrows one sweep releases
+const releaseBatchSize = 100
+
+// Releaser pays out undisputed escrows once their release date passes, every ReconcileInterval
+type Releaser struct {
+	Config config.Config
+	Repo   Repository
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewReleaser(cfg config.Config, repo Repository) *Releaser {
+	return &Releaser{Config: cfg, Repo: repo, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (r *Releaser) Start() {
+	logger.Info("Starting escrow releaser...", logger.Fields{"interval": r.Config.ReconcileInterval.String()})
+	go r.run()
+}
+
+// Stop waits for a sweep in progress to finish
+func (r *Releaser) Stop(ctx context.Context) error {
+	close(r.stop)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Releaser) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.Config.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if released, err := r.ReleaseDue(context.Background(), time.Now()); err != nil {
+			logger.Error("EscrowReleaser: Failed to release escrows", logger.Fields{"error": err.Error()})
+		} else if released > 0 {
+			logger.Info("EscrowReleaser: Released escrows past their release date", logger.Fields{"count": released})
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReleaseDue releases one batch of undisputed escrows past their release date and returns how many it
+// released. An escrow that can't be released is logged and left for the next sweep.
+func (r *Releaser) ReleaseDue(ctx context.Context, now time.Time) (int, error) {
+	escrows, err := r.Repo.GetDue(now, releaseBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, escrow := range escrows {
+		if ctx.Err() != nil {
+			return released, ctx.Err()
+		}
+
+		_, err := r.Repo.Move(escrow.ID.String(), StatusReleased, ByDeadline, "", now)
+		// an escrow disputed, released or refunded since the select has left FUNDED, which is fine
+		if errors.Is(err, ErrTransition) {
+			continue
+		}
+		if err != nil {
+			logger.Error("EscrowReleaser: Failed to release escrow", logger.Fields{"error": err.Error(), "escrow_id": escrow.ID.String()})
+			continue
+		}
+		released++
+	}
+
+	return released, nil
+}
@@ -0,0 +1,60 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+)
+
+// poisonedRepo fails to move one escrow, the way a broken row or a ledger error would
+type poisonedRepo struct {
+	*memoryRepo
+
+	poisoned uuid.UUID
+}
+
+func (p *poisonedRepo) Move(escrowID string, status Status, by Actor, note string, now time.Time) (*Escrow, error) {
+	if escrowID == p.poisoned.String() {
+		return nil, errors.New("ledger account missing")
+	}
+	return p.memoryRepo.Move(escrowID, status, by, note, now)
+}
+
+func TestReleaseDueSkipsFailures(t *testing.T) {
+	env := wallettest.NewEnv(t, 90000)
+	repo := newMemoryRepo(env.Repo)
+	recipient := env.AddWallet(t, "4444444444", "NGN")
+
+	now := time.Now()
+	escrows := make([]*Escrow, 3)
+	for i := range escrows {
+		escrows[i] = &Escrow{
+			SenderUserID:          env.User.ID,
+			SenderWalletID:        env.Wallet.ID,
+			SenderWalletNumber:    env.Wallet.WalletNumber,
+			RecipientUserID:       recipient.UserID,
+			RecipientWalletID:     recipient.ID,
+			RecipientWalletNumber: recipient.WalletNumber,
+			Amount:                30000,
+			Currency:              "NGN",
+			ReleaseAt:             now.Add(time.Duration(i-3) * time.Hour),
+		}
+		require.NoError(t, repo.Create(escrows[i]))
+	}
+
+	// the longest overdue escrow fails, the ones after it are still released
+	releaser := NewReleaser(env.Handler.Config, &poisonedRepo{memoryRepo: repo, poisoned: escrows[0].ID})
+	released, err := releaser.ReleaseDue(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, released)
+	assert.Equal(t, StatusFunded, repo.escrows[escrows[0].ID].Status)
+	assert.Equal(t, StatusReleased, repo.escrows[escrows[1].ID].Status)
+	assert.Equal(t, StatusReleased, repo.escrows[escrows[2].ID].Status)
+	assert.Equal(t, int64(60000), recipient.Balance)
+}
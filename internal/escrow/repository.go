@@ -0,0 +1,187 @@
+package escrow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/ledger"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTransition     = errors.New("escrow can't make that change in its current state")
+	ErrDeadlinePassed = errors.New("escrow release date has passed")
+)
+
+type Repository interface {
+	Create(escrow *Escrow) error
+	Get(escrowID string) (*Escrow, error)
+	List(userID string, status Status, limit, offset int) ([]Escrow, error)
+	Move(escrowID string, status Status, by Actor, note string, now time.Time) (*Escrow, error)
+	GetDue(now time.Time, limit int) ([]Escrow, error)
+}
+
+type repository struct {
+	db    *gorm.DB
+	funds wallet.Funds
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db, funds: wallet.NewFunds(db)}
+}
+
+// Create takes the escrow's amount out of the sender's wallet into the ESCROW system account
+func (r *repository) Create(escrow *Escrow) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		escrow.Status = StatusFunded
+		if err := tx.Create(escrow).Error; err != nil {
+			return err
+		}
+
+		reference := escrowReference(escrow, "fund")
+		description := escrowDescription(escrow, "Escrow funded")
+		if err := r.funds.ToSystem(tx, escrow.SenderWalletID.String(), ledger.SystemEscrow, reference, description, escrow.Amount); err != nil {
+			return err
+		}
+
+		return tx.Create(legs(escrow, reference, description, escrow.Amount, 0)).Error
+	})
+}
+
+func (r *repository) Get(escrowID string) (*Escrow, error) {
+	var escrow Escrow
+	if err := r.db.Where("id = ?", escrowID).First(&escrow).Error; err != nil {
+		return nil, err
+	}
+	return &escrow, nil
+}
+
+// List lists the escrows userID sent or receives, every escrow when userID is empty
+func (r *repository) List(userID string, status Status, limit, offset int) ([]Escrow, error) {
+	query := r.db.Model(&Escrow{})
+	if userID != "" {
+		query = query.Where("sender_user_id = ? OR recipient_user_id = ?", userID, userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var escrows []Escrow
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&escrows).Error
+	return escrows, err
+}
+
+// Move makes one state change of an escrow. Releasing pays the recipient and refunding pays the sender back
+// out of the ESCROW system account, a dispute moves no money and can only be raised before ReleaseAt. Either
+// way both wallets get a leg recording the change. note is the dispute reason or the resolution.
+func (r *repository) Move(escrowID string, status Status, by Actor, note string, now time.Time) (*Escrow, error) {
+	var escrow Escrow
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", escrowID).First(&escrow).Error; err != nil {
+			return err
+		}
+		if !escrow.CanMoveTo(status) {
+			return ErrTransition
+		}
+
+		var event string
+		var payee uuid.UUID
+		updates := map[string]interface{}{"status": status}
+		switch status {
+		case StatusDisputed:
+			if !now.Before(escrow.ReleaseAt) {
+				return ErrDeadlinePassed
+			}
+			event = "dispute"
+			escrow.DisputedBy, escrow.DisputeReason = by, note
+			updates["disputed_by"], updates["dispute_reason"] = by, note
+		case StatusReleased:
+			event, payee = "release", escrow.RecipientWalletID
+		case StatusRefunded:
+			event, payee = "refund", escrow.SenderWalletID
+		}
+		if status != StatusDisputed {
+			escrow.ClosedBy, escrow.Resolution, escrow.ClosedAt = by, note, &now
+			updates["closed_by"], updates["resolution"], updates["closed_at"] = by, note, now
+		}
+		escrow.Status = status
+
+		reference := escrowReference(&escrow, event)
+		description := escrowDescription(&escrow, "Escrow "+strings.ToLower(string(status)))
+		var senderAmount, recipientAmount int64
+		if payee != uuid.Nil {
+			if err := r.funds.FromSystem(tx, payee.String(), ledger.SystemEscrow, reference, description, escrow.Amount); err != nil {
+				return err
+			}
+
+			if payee == escrow.SenderWalletID {
+				senderAmount = escrow.Amount
+			} else {
+				recipientAmount = escrow.Amount
+			}
+		}
+
+		if err := tx.Create(legs(&escrow, reference, description, senderAmount, recipientAmount)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Escrow{}).Where("id = ?", escrow.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &escrow, nil
+}
+
+// GetDue lists up to limit undisputed escrows whose ReleaseAt has passed, the longest overdue first
+func (r *repository) GetDue(now time.Time, limit int) ([]Escrow, error) {
+	var escrows []Escrow
+	err := r.db.Where("status = ? AND release_at <= ?", StatusFunded, now).
+		Order("release_at asc").
+		Limit(limit).
+		Find(&escrows).Error
+	return escrows, err
+}
+
+func escrowReference(escrow *Escrow, event string) string {
+	return fmt.Sprintf("esc-%s-%s", escrow.ID, event)
+}
+
+func escrowDescription(escrow *Escrow, what string) string {
+	if escrow.Description == "" {
+		return what
+	}
+	return what + ": " + escrow.Description
+}
+
+// legs writes a state change on both wallets. The sender's leg is a debit and the recipient's a credit,
+// except for a refund where the money goes back the other way.
+func legs(escrow *Escrow, reference, description string, senderAmount, recipientAmount int64) []wallet.Transaction {
+	senderType, recipientType := wallet.TransactionDebit, wallet.TransactionCredit
+	if escrow.Status == StatusRefunded {
+		senderType, recipientType = wallet.TransactionCredit, wallet.TransactionDebit
+	}
+
+	leg := func(walletID uuid.UUID, txType wallet.TransactionType, amount int64) wallet.Transaction {
+		return wallet.Transaction{
+			WalletID:              walletID,
+			Reference:             reference + "-" + strings.ToLower(string(txType)),
+			Category:              wallet.CategoryEscrow,
+			Type:                  txType,
+			Amount:                amount,
+			Status:                wallet.TransactionSuccess,
+			SenderWalletNumber:    &escrow.SenderWalletNumber,
+			RecipientWalletNumber: &escrow.RecipientWalletNumber,
+			Description:           description,
+			Escrow:                wallet.EscrowLeg{ID: &escrow.ID, Status: string(escrow.Status), Amount: escrow.Amount},
+		}
+	}
+	return []wallet.Transaction{
+		leg(escrow.SenderWalletID, senderType, senderAmount),
+		leg(escrow.RecipientWalletID, recipientType, recipientAmount),
+	}
+}
@@ -0,0 +1,190 @@
+package fee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	tiered := []Tier{{UpTo: 500000, Flat: 1000}, {UpTo: 5000000, Flat: 2500}, {Flat: 5000, Bps: 10}}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		amount   int64
+		want     int64
+	}{
+		{
+			name:     "Flat",
+			schedule: Schedule{Kind: KindFlat, Flat: 5000},
+			amount:   100000,
+			want:     5000,
+		},
+		{
+			name:     "Percentage Plus Flat",
+			schedule: Schedule{Kind: KindPercentage, Flat: 100, Bps: 150},
+			amount:   200000,
+			want:     3100,
+		},
+		{
+			name:     "Percentage Capped",
+			schedule: Schedule{Kind: KindPercentage, Bps: 150, MaxFee: 200000},
+			amount:   100000000,
+			want:     200000,
+		},
+		{
+			name:     "Percentage Floored",
+			schedule: Schedule{Kind: KindPercentage, Bps: 150, MinFee: 1000},
+			amount:   10000,
+			want:     1000,
+		},
+		{
+			name:     "Tier Boundary Is Inclusive",
+			schedule: Schedule{Kind: KindTiered, Tiers: tiered},
+			amount:   500000,
+			want:     1000,
+		},
+		{
+			name:     "Middle Tier",
+			schedule: Schedule{Kind: KindTiered, Tiers: tiered},
+			amount:   500001,
+			want:     2500,
+		},
+		{
+			name:     "Unbounded Tier",
+			schedule: Schedule{Kind: KindTiered, Tiers: tiered},
+			amount:   10000000,
+			want:     15000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.schedule.Compute(tt.amount))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	key, otherKey := uuid.New(), uuid.New()
+	now := time.Now()
+
+	general := Schedule{ID: uuid.New(), CreatedAt: now.Add(-time.Hour)}
+	newerGeneral := Schedule{ID: uuid.New(), CreatedAt: now}
+	gold := Schedule{ID: uuid.New(), UserTier: "GOLD", CreatedAt: now.Add(-2 * time.Hour)}
+	forKey := Schedule{ID: uuid.New(), APIKeyID: &key, CreatedAt: now.Add(-3 * time.Hour)}
+	forOtherKey := Schedule{ID: uuid.New(), APIKeyID: &otherKey, CreatedAt: now}
+
+	tests := []struct {
+		name      string
+		schedules []Schedule
+		apiKeyID  *uuid.UUID
+		tier      string
+		want      *uuid.UUID
+	}{
+		{
+			name:      "None",
+			schedules: []Schedule{gold, forOtherKey},
+			tier:      DefaultTier,
+		},
+		{
+			name:      "Newest General",
+			schedules: []Schedule{general, newerGeneral, gold},
+			tier:      DefaultTier,
+			want:      &newerGeneral.ID,
+		},
+		{
+			name:      "Tier Beats General",
+			schedules: []Schedule{general, newerGeneral, gold},
+			tier:      "GOLD",
+			want:      &gold.ID,
+		},
+		{
+			name:      "Key Beats Tier",
+			schedules: []Schedule{general, gold, forKey, forOtherKey},
+			apiKeyID:  &key,
+			tier:      "GOLD",
+			want:      &forKey.ID,
+		},
+		{
+			name:      "Key Schedule Needs The Key",
+			schedules: []Schedule{general, forKey},
+			tier:      DefaultTier,
+			want:      &general.ID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Match(tt.schedules, tt.apiKeyID, tt.tier)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, *tt.want, got.ID)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  bool
+	}{
+		{
+			name:     "Flat",
+			schedule: Schedule{Name: "Transfers", Category: CategoryTransfer, Kind: KindFlat, Flat: 1000},
+		},
+		{
+			name:     "Tiered",
+			schedule: Schedule{Name: "Withdrawals", Category: CategoryWithdrawal, Kind: KindTiered, Tiers: []Tier{{UpTo: 500000, Flat: 1000}, {Flat: 2500}}},
+		},
+		{
+			name:     "Unknown Category",
+			schedule: Schedule{Name: "Refunds", Category: "REFUND", Kind: KindFlat},
+			wantErr:  true,
+		},
+		{
+			name:     "Bps Above Whole Amount",
+			schedule: Schedule{Name: "Deposits", Category: CategoryDeposit, Kind: KindPercentage, Bps: 10001},
+			wantErr:  true,
+		},
+		{
+			name:     "Max Below Min",
+			schedule: Schedule{Name: "Deposits", Category: CategoryDeposit, Kind: KindPercentage, Bps: 150, MinFee: 1000, MaxFee: 500},
+			wantErr:  true,
+		},
+		{
+			name:     "Last Tier Bounded",
+			schedule: Schedule{Name: "Withdrawals", Category: CategoryWithdrawal, Kind: KindTiered, Tiers: []Tier{{UpTo: 500000, Flat: 1000}}},
+			wantErr:  true,
+		},
+		{
+			name:     "Tiers Out Of Order",
+			schedule: Schedule{Name: "Withdrawals", Category: CategoryWithdrawal, Kind: KindTiered, Tiers: []Tier{{UpTo: 500000}, {UpTo: 100000}, {}}},
+			wantErr:  true,
+		},
+		{
+			name:     "Tiers On Flat",
+			schedule: Schedule{Name: "Transfers", Category: CategoryTransfer, Kind: KindFlat, Tiers: []Tier{{Flat: 1000}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
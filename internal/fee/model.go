@@ -0,0 +1,163 @@
+package fee
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is the kind of money movement a schedule prices, the values match the wallet's transaction categories
+type Category string
+
+const (
+	CategoryDeposit    Category = "DEPOSIT"
+	CategoryWithdrawal Category = "WITHDRAWAL"
+	CategoryTransfer   Category = "TRANSFER"
+)
+
+type Kind string
+
+const (
+	// KindFlat charges Flat whatever the amount
+	KindFlat Kind = "FLAT"
+	// KindPercentage charges Bps of the amount on top of Flat
+	KindPercentage Kind = "PERCENTAGE"
+	// KindTiered charges the Flat and Bps of the first tier the amount falls into
+	KindTiered Kind = "TIERED"
+)
+
+// DefaultTier is the tier every user starts on
+const DefaultTier = "STANDARD"
+
+// Schedule prices one category in one currency. APIKeyID and UserTier narrow it to requests made with that key
+// or by users on that tier, the most specific active schedule wins. MinFee and MaxFee bound the fee, a MaxFee of
+// zero leaves it uncapped.
+type Schedule struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name      string     `gorm:"not null" json:"name"`
+	Category  Category   `gorm:"not null" json:"category"`
+	Currency  string     `gorm:"not null" json:"currency"`
+	Kind      Kind       `gorm:"not null" json:"kind"`
+	Flat      int64      `gorm:"not null;default:0" json:"flat"`
+	Bps       int64      `gorm:"not null;default:0" json:"bps"`
+	Tiers     []Tier     `gorm:"foreignKey:ScheduleID" json:"tiers,omitempty"`
+	MinFee    int64      `gorm:"not null;default:0" json:"min_fee"`
+	MaxFee    int64      `gorm:"not null;default:0" json:"max_fee"`
+	APIKeyID  *uuid.UUID `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	UserTier  string     `json:"user_tier,omitempty"`
+	Active    bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (Schedule) TableName() string { return "fee_schedules" }
+
+// Tier covers amounts up to and including UpTo, an UpTo of zero has no upper bound. Tiers are kept in
+// ascending UpTo order with the unbounded one last.
+type Tier struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"-"`
+	ScheduleID uuid.UUID `gorm:"type:uuid;not null" json:"-"`
+	UpTo       int64     `gorm:"not null;default:0" json:"up_to"`
+	Flat       int64     `gorm:"not null;default:0" json:"flat"`
+	Bps        int64     `gorm:"not null;default:0" json:"bps"`
+}
+
+func (Tier) TableName() string { return "fee_tiers" }
+
+// Validate checks a schedule an admin sends before it is saved, the currency is checked by the caller
+func (s *Schedule) Validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("name is required")
+	case s.Category != CategoryTransfer && s.Category != CategoryWithdrawal && s.Category != CategoryDeposit:
+		return errors.New("category must be TRANSFER, WITHDRAWAL or DEPOSIT")
+	case s.Kind != KindFlat && s.Kind != KindPercentage && s.Kind != KindTiered:
+		return errors.New("kind must be FLAT, PERCENTAGE or TIERED")
+	case s.Flat < 0 || s.MinFee < 0 || s.MaxFee < 0:
+		return errors.New("flat, min_fee and max_fee can't be negative")
+	case s.Bps < 0 || s.Bps > 10000:
+		return errors.New("bps must be between 0 and 10000")
+	case s.MaxFee > 0 && s.MaxFee < s.MinFee:
+		return errors.New("max_fee can't be less than min_fee")
+	case s.Kind == KindTiered && len(s.Tiers) == 0:
+		return errors.New("a TIERED schedule needs tiers")
+	case s.Kind != KindTiered && len(s.Tiers) > 0:
+		return errors.New("only a TIERED schedule has tiers")
+	}
+
+	for i, t := range s.Tiers {
+		last := i == len(s.Tiers)-1
+		switch {
+		case t.Flat < 0 || t.Bps < 0 || t.Bps > 10000:
+			return errors.New("tier flat can't be negative and bps must be between 0 and 10000")
+		case last && t.UpTo != 0:
+			return errors.New("the last tier must have no up_to")
+		case !last && t.UpTo <= 0:
+			return errors.New("only the last tier can leave up_to out")
+		case i > 0 && !last && t.UpTo <= s.Tiers[i-1].UpTo:
+			return errors.New("tiers must be in ascending up_to order")
+		}
+	}
+	return nil
+}
+
+// Compute prices amount, the result is in the same minor unit
+func (s *Schedule) Compute(amount int64) int64 {
+	var fee int64
+	switch s.Kind {
+	case KindFlat:
+		fee = s.Flat
+	case KindPercentage:
+		fee = s.Flat + amount*s.Bps/10000
+	case KindTiered:
+		for _, t := range s.Tiers {
+			if t.UpTo == 0 || amount <= t.UpTo {
+				fee = t.Flat + amount*t.Bps/10000
+				break
+			}
+		}
+	}
+
+	if fee < s.MinFee {
+		fee = s.MinFee
+	}
+	if s.MaxFee > 0 && fee > s.MaxFee {
+		fee = s.MaxFee
+	}
+	return fee
+}
+
+// Match picks the schedule for a request made with apiKeyID (nil for a session) by a user on userTier. A
+// schedule for another key or tier never applies, of the rest one for the key beats one for the tier which
+// beats a general one, and among equals the newest wins.
+func Match(schedules []Schedule, apiKeyID *uuid.UUID, userTier string) *Schedule {
+	var best *Schedule
+	bestScore := -1
+	for i := range schedules {
+		s := &schedules[i]
+		score := 0
+		if s.APIKeyID != nil {
+			if apiKeyID == nil || *s.APIKeyID != *apiKeyID {
+				continue
+			}
+			score += 2
+		}
+		if s.UserTier != "" {
+			if s.UserTier != userTier {
+				continue
+			}
+			score++
+		}
+		if score > bestScore || (score == bestScore && s.CreatedAt.After(best.CreatedAt)) {
+			best, bestScore = s, score
+		}
+	}
+	return best
+}
+
+// Charge is the fee a transaction pays and the schedule it came from, the zero Charge is no fee
+type Charge struct {
+	ScheduleID *uuid.UUID `json:"schedule_id,omitempty"`
+	Amount     int64      `json:"amount"`
+}
@@ -0,0 +1,119 @@
+package fee
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(schedule *Schedule) error
+	Get(id string) (*Schedule, error)
+	List(category Category, currency string) ([]Schedule, error)
+	Update(schedule *Schedule) error
+	Delete(id string) error
+	// Charge prices amount with the schedule Match picks among the active ones for category and currency
+	Charge(category Category, currency string, amount int64, apiKeyID *uuid.UUID, userTier string) (Charge, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(schedule *Schedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *repository) Get(id string) (*Schedule, error) {
+	var schedule Schedule
+	if err := r.db.Preload("Tiers", orderTiers).Where("id = ?", id).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// List lists the schedules, category and currency filter them when set
+func (r *repository) List(category Category, currency string) ([]Schedule, error) {
+	query := r.db.Preload("Tiers", orderTiers)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+
+	var schedules []Schedule
+	err := query.Order("category, currency, created_at DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+// Update saves every field of the schedule and replaces its tiers
+func (r *repository) Update(schedule *Schedule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&Tier{}).Error; err != nil {
+			return err
+		}
+		for i := range schedule.Tiers {
+			schedule.Tiers[i].ID = uuid.Nil
+			schedule.Tiers[i].ScheduleID = schedule.ID
+		}
+		if len(schedule.Tiers) > 0 {
+			if err := tx.Create(&schedule.Tiers).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Schedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+			"name":       schedule.Name,
+			"category":   schedule.Category,
+			"currency":   schedule.Currency,
+			"kind":       schedule.Kind,
+			"flat":       schedule.Flat,
+			"bps":        schedule.Bps,
+			"min_fee":    schedule.MinFee,
+			"max_fee":    schedule.MaxFee,
+			"api_key_id": schedule.APIKeyID,
+			"user_tier":  schedule.UserTier,
+			"active":     schedule.Active,
+		}).Error
+	})
+}
+
+func (r *repository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", id).Delete(&Tier{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&Schedule{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *repository) Charge(category Category, currency string, amount int64, apiKeyID *uuid.UUID, userTier string) (Charge, error) {
+	var schedules []Schedule
+	err := r.db.Preload("Tiers", orderTiers).
+		Where("category = ? AND currency = ? AND active", category, currency).
+		Find(&schedules).Error
+	if err != nil {
+		return Charge{}, err
+	}
+
+	schedule := Match(schedules, apiKeyID, userTier)
+	if schedule == nil {
+		return Charge{}, nil
+	}
+	return Charge{ScheduleID: &schedule.ID, Amount: schedule.Compute(amount)}, nil
+}
+
+// orderTiers keeps the unbounded tier, stored with up_to 0, after the bounded ones
+func orderTiers(db *gorm.DB) *gorm.DB {
+	return db.Order("up_to = 0, up_to")
+}
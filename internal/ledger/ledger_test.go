@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	wallet := &Account{ID: uuid.New(), Currency: "NGN"}
+	clearing := &Account{ID: uuid.New(), Currency: "NGN"}
+	fees := &Account{ID: uuid.New(), Currency: "NGN"}
+	usdWallet := &Account{ID: uuid.New(), Currency: "USD"}
+
+	tests := []struct {
+		name    string
+		lines   []Line
+		wantErr error
+	}{
+		{
+			name:  "Balanced Pair",
+			lines: []Line{DebitLine(clearing, 5000), CreditLine(wallet, 5000)},
+		},
+		{
+			name:  "Balanced Split",
+			lines: []Line{DebitLine(wallet, 5100), CreditLine(clearing, 5000), CreditLine(fees, 100)},
+		},
+		{
+			name:    "Unbalanced",
+			lines:   []Line{DebitLine(clearing, 5000), CreditLine(wallet, 4999)},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name:    "Balanced Total Across Currencies",
+			lines:   []Line{DebitLine(usdWallet, 5000), CreditLine(wallet, 5000)},
+			wantErr: ErrUnbalancedJournal,
+		},
+		{
+			name:    "Single Entry",
+			lines:   []Line{DebitLine(clearing, 5000)},
+			wantErr: ErrEmptyJournal,
+		},
+		{
+			name:    "Zero Amount",
+			lines:   []Line{DebitLine(clearing, 0), CreditLine(wallet, 0)},
+			wantErr: ErrInvalidAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.lines)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountType string
+
+const (
+	AccountWallet AccountType = "WALLET"
+	AccountSystem AccountType = "SYSTEM"
+)
+
+// system account codes, each one exists once per currency
+const (
+	SystemPaystackClearing = "PAYSTACK_CLEARING"
+	SystemFees             = "FEES"
+	SystemSuspense         = "SUSPENSE"
+	// SystemFXPosition takes one side of every currency conversion, so each currency still balances on its own
+	SystemFXPosition = "FX_POSITION"
+	// SystemEscrow holds the money of escrows that are neither released nor refunded
+	SystemEscrow = "ESCROW"
+)
+
+type Direction string
+
+const (
+	Debit  Direction = "DEBIT"
+	Credit Direction = "CREDIT"
+)
+
+// Account balances are credits minus debits, so wallet accounts read as the
+// wallet balance and the balances of all accounts in a currency sum to zero.
+type Account struct {
+	ID        uuid.UUID   `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Code      string      `gorm:"uniqueIndex;not null" json:"code"`
+	Type      AccountType `gorm:"not null" json:"type"`
+	WalletID  *uuid.UUID  `gorm:"type:uuid" json:"wallet_id,omitempty"`
+	Currency  string      `gorm:"not null" json:"currency"`
+	Balance   int64       `gorm:"not null;default:0" json:"balance"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (Account) TableName() string { return "ledger_accounts" }
+
+type Journal struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Reference   string    `gorm:"uniqueIndex;not null" json:"reference"`
+	Description string    `json:"description"`
+	Entries     []Entry   `gorm:"foreignKey:JournalID" json:"entries,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (Journal) TableName() string { return "ledger_journals" }
+
+type Entry struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	JournalID uuid.UUID `gorm:"type:uuid;not null" json:"journal_id"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null" json:"account_id"`
+	Direction Direction `gorm:"not null" json:"direction"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Entry) TableName() string { return "ledger_entries" }
+
+// Line is one side of a posting before it is written as an Entry
+type Line struct {
+	AccountID uuid.UUID
+	Currency  string
+	Direction Direction
+	Amount    int64
+}
+
+func DebitLine(account *Account, amount int64) Line {
+	return Line{AccountID: account.ID, Currency: account.Currency, Direction: Debit, Amount: amount}
+}
+
+func CreditLine(account *Account, amount int64) Line {
+	return Line{AccountID: account.ID, Currency: account.Currency, Direction: Credit, Amount: amount}
+}
@@ -0,0 +1,173 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEmptyJournal      = errors.New("journal must have at least two entries")
+	ErrInvalidAmount     = errors.New("journal entry amounts must be positive")
+	ErrUnbalancedJournal = errors.New("journal debits and credits do not balance")
+)
+
+type Repository interface {
+	// WithTx scopes the repository to an open database transaction so postings commit with the caller's changes
+	WithTx(tx *gorm.DB) Repository
+	Post(reference, description string, lines ...Line) (*Journal, error)
+	WalletAccount(walletID uuid.UUID, currency string) (*Account, error)
+	SystemAccount(code, currency string) (*Account, error)
+	GetAccountByWalletID(walletID string) (*Account, error)
+	DerivedBalance(accountID uuid.UUID) (int64, error)
+	GetJournal(reference string) (*Journal, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) WithTx(tx *gorm.DB) Repository {
+	return &repository{db: tx}
+}
+
+// Validate checks that a set of lines forms a balanced journal in every currency it touches
+func Validate(lines []Line) error {
+	if len(lines) < 2 {
+		return ErrEmptyJournal
+	}
+
+	totals := make(map[string]int64)
+	for _, l := range lines {
+		if l.Amount <= 0 {
+			return ErrInvalidAmount
+		}
+		switch l.Direction {
+		case Debit:
+			totals[l.Currency] += l.Amount
+		case Credit:
+			totals[l.Currency] -= l.Amount
+		default:
+			return fmt.Errorf("invalid entry direction: %s", l.Direction)
+		}
+	}
+
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("%w: %s off by %d", ErrUnbalancedJournal, currency, total)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) Post(reference, description string, lines ...Line) (*Journal, error) {
+	if err := Validate(lines); err != nil {
+		return nil, err
+	}
+
+	journal := Journal{Reference: reference, Description: description}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&journal).Error; err != nil {
+			return err
+		}
+
+		for _, l := range lines {
+			entry := Entry{
+				JournalID: journal.ID,
+				AccountID: l.AccountID,
+				Direction: l.Direction,
+				Amount:    l.Amount,
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			journal.Entries = append(journal.Entries, entry)
+
+			delta := l.Amount
+			if l.Direction == Debit {
+				delta = -l.Amount
+			}
+
+			res := tx.Model(&Account{}).
+				Where("id = ? AND currency = ?", l.AccountID, l.Currency).
+				UpdateColumn("balance", gorm.Expr("balance + ?", delta))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("ledger account %s not found in %s", l.AccountID, l.Currency)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal, nil
+}
+
+func (r *repository) WalletAccount(walletID uuid.UUID, currency string) (*Account, error) {
+	return r.getOrCreate(Account{
+		Code:     "WALLET:" + walletID.String(),
+		Type:     AccountWallet,
+		WalletID: &walletID,
+		Currency: currency,
+	})
+}
+
+func (r *repository) SystemAccount(code, currency string) (*Account, error) {
+	return r.getOrCreate(Account{
+		Code:     code + ":" + currency,
+		Type:     AccountSystem,
+		Currency: currency,
+	})
+}
+
+func (r *repository) getOrCreate(account Account) (*Account, error) {
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+
+	var existing Account
+	if err := r.db.Where("code = ?", account.Code).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (r *repository) GetAccountByWalletID(walletID string) (*Account, error) {
+	var account Account
+	if err := r.db.Where("wallet_id = ? AND type = ?", walletID, AccountWallet).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// DerivedBalance recomputes an account balance from its postings, ignoring the cached balance column
+func (r *repository) DerivedBalance(accountID uuid.UUID) (int64, error) {
+	var balance int64
+	err := r.db.Model(&Entry{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", Credit).
+		Where("account_id = ?", accountID).
+		Scan(&balance).Error
+	return balance, err
+}
+
+func (r *repository) GetJournal(reference string) (*Journal, error) {
+	var journal Journal
+	if err := r.db.Preload("Entries").Where("reference = ?", reference).First(&journal).Error; err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
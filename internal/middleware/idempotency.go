@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyLockTTL        = time.Minute
+	defaultIdempotencyTTL     = 24 * time.Hour
+	idempotencyRedisKeyPrefix = "idempotency:"
+)
+
+type IdempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Status      int    `json:"status"`
+	Body        []byte `json:"body"`
+}
+
+type IdempotencyStore interface {
+	// Get returns nil when no response has been stored for key
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	Save(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error
+	// Lock reserves key for one in-flight request identified by token and reports false if it is already held
+	Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Unlock releases key only while token still holds it, a lock that expired and was taken by another
+	// request is left alone
+	Unlock(ctx context.Context, key, token string) error
+}
+
+type Idempotency struct {
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+func NewIdempotency(store IdempotencyStore, ttl time.Duration) *Idempotency {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &Idempotency{store: store, ttl: ttl}
+}
+
+// Handle replays the stored response for a repeated Idempotency-Key instead of running next again.
+// Requests without the header are passed straight through.
+func (i *Idempotency) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idemKey := r.Header.Get(IdempotencyKeyHeader)
+		if idemKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(idemKey) > maxIdempotencyKeyLength {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Idempotency-Key is too long", nil)
+			return
+		}
+
+		usr, ok := r.Context().Value(utils.UserKey).(user.User)
+		if !ok {
+			utils.BuildErrorResponse(w, http.StatusUnauthorized, "Unauthorized", nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1048576))
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid request", map[string]string{"error": err.Error()})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		storeKey := usr.ID.String() + ":" + idemKey
+		requestHash := hashRequest(r, body)
+		ctx := r.Context()
+
+		if i.replay(w, ctx, storeKey, requestHash) {
+			return
+		}
+
+		token := uuid.NewString()
+		locked, err := i.store.Lock(ctx, storeKey, token, idempotencyLockTTL)
+		if err != nil {
+			logger.Error("Idempotency: Failed to acquire lock", logger.Fields{"error": err.Error(), "user_id": usr.ID.String()})
+			utils.BuildErrorResponse(w, http.StatusServiceUnavailable, "Could not process Idempotency-Key, retry later", nil)
+			return
+		}
+		if !locked {
+			// the other request may have finished between the first lookup and the lock attempt
+			if i.replay(w, ctx, storeKey, requestHash) {
+				return
+			}
+			utils.BuildErrorResponse(w, http.StatusConflict, "A request with this Idempotency-Key is already in progress", nil)
+			return
+		}
+		defer func() {
+			if err := i.store.Unlock(context.Background(), storeKey, token); err != nil {
+				logger.Warn("Idempotency: Failed to release lock", logger.Fields{"error": err.Error(), "user_id": usr.ID.String()})
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// server errors are not stored so the client can retry them with the same key
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		record := IdempotencyRecord{RequestHash: requestHash, Status: rec.status, Body: rec.body.Bytes()}
+		if err := i.store.Save(context.Background(), storeKey, record, i.ttl); err != nil {
+			logger.Error("Idempotency: Failed to store response", logger.Fields{"error": err.Error(), "user_id": usr.ID.String()})
+		}
+	})
+}
+
+func (i *Idempotency) replay(w http.ResponseWriter, ctx context.Context, storeKey, requestHash string) bool {
+	record, err := i.store.Get(ctx, storeKey)
+	if err != nil {
+		logger.Error("Idempotency: Failed to read stored response", logger.Fields{"error": err.Error()})
+		return false
+	}
+	if record == nil {
+		return false
+	}
+
+	if record.RequestHash != requestHash {
+		utils.BuildErrorResponse(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+	return true
+}
+
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+func NewRedisIdempotencyStore(client *redis.Client) IdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+func (s *redisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	data, err := s.client.Get(ctx, idempotencyRedisKeyPrefix+"response:"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *redisIdempotencyStore) Save(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, idempotencyRedisKeyPrefix+"response:"+key, data, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, idempotencyRedisKeyPrefix+"lock:"+key, token, ttl).Result()
+}
+
+// unlockScript deletes the lock only if it still holds the caller's token, in one step so nothing can take
+// the lock between the check and the delete
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (s *redisIdempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	return unlockScript.Run(ctx, s.client, []string{idempotencyRedisKeyPrefix + "lock:" + key}, token).Err()
+}
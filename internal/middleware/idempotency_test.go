@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	locks   map[string]string
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]IdempotencyRecord), locks: make(map[string]string)}
+}
+
+func (m *memoryIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[key]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (m *memoryIdempotencyStore) Save(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = record
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Lock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, held := m.locks[key]; held {
+		return false, nil
+	}
+	m.locks[key] = token
+	return true, nil
+}
+
+func (m *memoryIdempotencyStore) Unlock(ctx context.Context, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[key] == token {
+		delete(m.locks, key)
+	}
+	return nil
+}
+
+// expire drops the lock on key as if its TTL ran out
+func (m *memoryIdempotencyStore) expire(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.locks, key)
+}
+
+func TestIdempotency(t *testing.T) {
+
+	calls := 0
+	idem := NewIdempotency(newMemoryIdempotencyStore(), time.Hour)
+	handler := idem.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"reference":"trf-1"}`))
+	}))
+
+	usr := user.User{ID: uuid.New()}
+	makeRequest := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/wallet/transfer", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, usr))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := makeRequest("key-1", `{"amount":100}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", first.Code)
+	}
+
+	replay := makeRequest("key-1", `{"amount":100}`)
+	if replay.Code != http.StatusCreated {
+		t.Errorf("Expected replayed 201, got %d", replay.Code)
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %q, got %q", first.Body.String(), replay.Body.String())
+	}
+	if replay.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("Expected %s header on replay", IdempotentReplayedHeader)
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+
+	// same key with a different body is rejected
+	if code := makeRequest("key-1", `{"amount":200}`).Code; code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", code)
+	}
+
+	// requests without a key are not deduplicated
+	makeRequest("", `{"amount":100}`)
+	makeRequest("", `{"amount":100}`)
+	if calls != 3 {
+		t.Errorf("Expected handler to run 3 times, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+
+	store := newMemoryIdempotencyStore()
+	usr := user.User{ID: uuid.New()}
+
+	// simulate a first request that is still being processed
+	store.Lock(context.Background(), usr.ID.String()+":key-1", "first", time.Minute)
+
+	handler := NewIdempotency(store, time.Hour).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/wallet/deposit", strings.NewReader(`{"amount":100}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, usr))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d", w.Code)
+	}
+}
+
+func TestIdempotencySlowRequestKeepsOtherLock(t *testing.T) {
+
+	store := newMemoryIdempotencyStore()
+	usr := user.User{ID: uuid.New()}
+	storeKey := usr.ID.String() + ":key-1"
+
+	// the request outlives its lock and a retry takes the key over before it finishes
+	handler := NewIdempotency(store, time.Hour).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.expire(storeKey)
+		store.Lock(context.Background(), storeKey, "retry", time.Minute)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest("POST", "/wallet/withdraw", strings.NewReader(`{"amount":100}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, usr))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if store.locks[storeKey] != "retry" {
+		t.Errorf("Expected the retry to keep its lock, got %q", store.locks[storeKey])
+	}
+}
@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	Repo Repository
+}
+
+func NewHandler(repo Repository) *Handler {
+	return &Handler{Repo: repo}
+}
+
+// ListNotifications returns the user's notifications newest first, ?unread=true leaves out ones already read
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	list, err := h.Repo.ListByUserID(usr.ID.String(), r.URL.Query().Get("unread") == "true", limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch notifications", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Notifications", map[string]interface{}{
+		"notifications": list,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	id := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Notification not found", nil)
+		return
+	}
+
+	if err := h.Repo.MarkRead(id, usr.ID.String(), time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Notification not found", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to update notification", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Notification marked as read", nil)
+}
@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Kind string
+
+const (
+	KindScheduledTransferFailed Kind = "SCHEDULED_TRANSFER_FAILED"
+	KindScheduledTransferPaused Kind = "SCHEDULED_TRANSFER_PAUSED"
+	KindPaymentRequestReceived  Kind = "PAYMENT_REQUEST_RECEIVED"
+	KindPaymentRequestApproved  Kind = "PAYMENT_REQUEST_APPROVED"
+	KindPaymentRequestDeclined  Kind = "PAYMENT_REQUEST_DECLINED"
+	KindPaymentRequestCancelled Kind = "PAYMENT_REQUEST_CANCELLED"
+)
+
+// Notification is a message for a user about something that happened without them, e.g. a scheduled
+// transfer the worker could not make. Reference names what it is about.
+type Notification struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Kind      Kind       `gorm:"not null" json:"kind"`
+	Title     string     `gorm:"not null" json:"title"`
+	Message   string     `json:"message"`
+	Reference string     `json:"reference,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
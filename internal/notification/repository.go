@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(n *Notification) error
+	ListByUserID(userID string, unreadOnly bool, limit, offset int) ([]Notification, error)
+	MarkRead(id, userID string, at time.Time) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(n *Notification) error {
+	return r.db.Create(n).Error
+}
+
+func (r *repository) ListByUserID(userID string, unreadOnly bool, limit, offset int) ([]Notification, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var list []Notification
+	err := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&list).Error
+	return list, err
+}
+
+// MarkRead keeps the first read time, marking a notification read twice is not an error
+func (r *repository) MarkRead(id, userID string, at time.Time) error {
+	var n Notification
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		return err
+	}
+	if n.ReadAt != nil {
+		return nil
+	}
+	return r.db.Model(&Notification{}).Where("id = ?", id).Update("read_at", at).Error
+}
@@ -0,0 +1,61 @@
+package paymentrequest
+
+import (
+	"context"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+)
+
+// Expirer marks pending requests EXPIRED once their expiry passes, every ReconcileInterval. A request is
+// refused past its expiry whether or not it has been marked, marking it only keeps the listings honest.
+type Expirer struct {
+	Config config.Config
+	Repo   Repository
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewExpirer(cfg config.Config, repo Repository) *Expirer {
+	return &Expirer{Config: cfg, Repo: repo, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (e *Expirer) Start() {
+	logger.Info("Starting payment request expirer...", logger.Fields{"interval": e.Config.ReconcileInterval.String()})
+	go e.run()
+}
+
+// Stop waits for a sweep in progress to finish
+func (e *Expirer) Stop(ctx context.Context) error {
+	close(e.stop)
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Expirer) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.Config.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if expired, err := e.Repo.Expire(time.Now()); err != nil {
+			logger.Error("PaymentRequestExpirer: Failed to expire payment requests", logger.Fields{"error": err.Error()})
+		} else if expired > 0 {
+			logger.Info("PaymentRequestExpirer: Expired payment requests", logger.Fields{"count": expired})
+		}
+
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
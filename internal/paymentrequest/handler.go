@@ -0,0 +1,305 @@
+package paymentrequest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// Handler serves the payment requests a user sent and was sent, Wallets checks amounts, PINs and fees the way
+// a transfer does
+type Handler struct {
+	Repo    Repository
+	Wallets *wallet.Handler
+}
+
+func NewHandler(repo Repository, wallets *wallet.Handler) *Handler {
+	return &Handler{Repo: repo, Wallets: wallets}
+}
+
+type RequestFundsRequest struct {
+	// WalletNumber is the wallet asked to pay, it must hold the currency asked for
+	WalletNumber string `json:"wallet_number"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Note         string `json:"note"`
+	// ExpiresIn is in seconds, RequestDefaultExpiry applies when it is left out
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// CreatePaymentRequest asks another wallet for money, nothing moves until its owner approves
+func (h *Handler) CreatePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req RequestFundsRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := wallet.ResolveCurrency(w, req.Currency)
+	if !ok || !h.Wallets.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	expiresIn := h.Wallets.Config.RequestDefaultExpiry
+	if req.ExpiresIn != 0 {
+		expiresIn = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiresIn <= 0 || expiresIn > h.Wallets.Config.RequestMaxExpiry {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("expires_in must be between 1 and %d seconds", int64(h.Wallets.Config.RequestMaxExpiry.Seconds())), nil)
+		return
+	}
+
+	if req.WalletNumber == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "wallet_number is required", nil)
+		return
+	}
+
+	requester, ok := h.Wallets.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	payer, err := h.Wallets.Repo.GetWalletByNumber(req.WalletNumber)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+	if payer.UserID == usr.ID {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Cannot request money from your own wallet", nil)
+		return
+	}
+	if payer.Currency != requester.Currency {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Currency mismatch, wallet holds %s not %s", payer.Currency, requester.Currency), nil)
+		return
+	}
+
+	request := Request{
+		RequesterUserID:       usr.ID,
+		RequesterWalletID:     requester.ID,
+		RequesterWalletNumber: requester.WalletNumber,
+		PayerUserID:           payer.UserID,
+		PayerWalletID:         payer.ID,
+		PayerWalletNumber:     payer.WalletNumber,
+		Amount:                req.Amount,
+		Currency:              requester.Currency,
+		Note:                  req.Note,
+		Status:                StatusPending,
+		ExpiresAt:             time.Now().Add(expiresIn),
+	}
+
+	if err := h.Repo.Create(&request); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create payment request", nil)
+		return
+	}
+
+	h.notify(request.PayerUserID, notification.KindPaymentRequestReceived, "Payment request received",
+		fmt.Sprintf("Wallet %s asked you for %d %s%s.", request.RequesterWalletNumber, request.Amount, request.Currency, notePart(request.Note)), request.ID.String())
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Payment request sent", request)
+}
+
+// ListIncomingRequests lists the requests the user was asked to pay
+func (h *Handler) ListIncomingRequests(w http.ResponseWriter, r *http.Request) {
+	h.listPaymentRequests(w, r, true)
+}
+
+// ListOutgoingRequests lists the requests the user sent
+func (h *Handler) ListOutgoingRequests(w http.ResponseWriter, r *http.Request) {
+	h.listPaymentRequests(w, r, false)
+}
+
+func (h *Handler) listPaymentRequests(w http.ResponseWriter, r *http.Request, incoming bool) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	requests, err := h.Repo.List(usr.ID.String(), incoming, Status(r.URL.Query().Get("status")), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch payment requests", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Payment requests", map[string]interface{}{
+		"requests": requests,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	request, err := h.Repo.Get(mux.Vars(r)["id"])
+	if err != nil || (request.RequesterUserID != usr.ID && request.PayerUserID != usr.ID) {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Payment request not found", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Payment request", request)
+}
+
+type ApprovePaymentRequestRequest struct {
+	Pin string `json:"pin"`
+}
+
+// ApprovePaymentRequest pays a request the user was sent, the transfer is checked like any other
+func (h *Handler) ApprovePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req ApprovePaymentRequestRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	request, ok := h.incomingRequest(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	payer, err := h.Wallets.Repo.GetWalletByID(request.PayerWalletID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+	if !h.Wallets.VerifyPin(w, payer, req.Pin) {
+		return
+	}
+
+	charge, ok := h.Wallets.Charge(w, r, fee.CategoryTransfer, payer.Currency, request.Amount)
+	if !ok {
+		return
+	}
+
+	request, err = h.Repo.Pay(request.ID.String(), charge, time.Now())
+	if err != nil {
+		h.requestError(w, err, mux.Vars(r)["id"])
+		return
+	}
+
+	h.notify(request.RequesterUserID, notification.KindPaymentRequestApproved, "Payment request approved",
+		fmt.Sprintf("Wallet %s paid your request for %d %s.", request.PayerWalletNumber, request.Amount, request.Currency), request.ID.String())
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Payment request approved", request)
+}
+
+type DeclinePaymentRequestRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DeclinePaymentRequest turns down a request the user was sent, the body with a reason is optional
+func (h *Handler) DeclinePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req DeclinePaymentRequestRequest
+	if r.ContentLength != 0 {
+		if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+			utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	request, ok := h.incomingRequest(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	request, err := h.Repo.Close(request.ID.String(), StatusDeclined, req.Reason, time.Now())
+	if err != nil {
+		h.requestError(w, err, mux.Vars(r)["id"])
+		return
+	}
+
+	h.notify(request.RequesterUserID, notification.KindPaymentRequestDeclined, "Payment request declined",
+		fmt.Sprintf("Wallet %s declined your request for %d %s%s.", request.PayerWalletNumber, request.Amount, request.Currency, reasonPart(request.DeclineReason)), request.ID.String())
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Payment request declined", request)
+}
+
+// CancelPaymentRequest withdraws a request the user sent while it is still pending
+func (h *Handler) CancelPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	request, err := h.Repo.Get(mux.Vars(r)["id"])
+	if err != nil || request.RequesterUserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Payment request not found", nil)
+		return
+	}
+
+	request, err = h.Repo.Close(request.ID.String(), StatusCancelled, "", time.Now())
+	if err != nil {
+		h.requestError(w, err, mux.Vars(r)["id"])
+		return
+	}
+
+	h.notify(request.PayerUserID, notification.KindPaymentRequestCancelled, "Payment request cancelled",
+		fmt.Sprintf("Wallet %s cancelled its request for %d %s.", request.RequesterWalletNumber, request.Amount, request.Currency), request.ID.String())
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Payment request cancelled", request)
+}
+
+// incomingRequest loads a request the user was asked to pay, any other request reads as not found
+func (h *Handler) incomingRequest(w http.ResponseWriter, usr user.User, requestID string) (*Request, bool) {
+	request, err := h.Repo.Get(requestID)
+	if err != nil || request.PayerUserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Payment request not found", nil)
+		return nil, false
+	}
+	return request, true
+}
+
+func (h *Handler) requestError(w http.ResponseWriter, err error, requestID string) {
+	switch {
+	case errors.Is(err, ErrClosed):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Payment request was already answered or cancelled", nil)
+	case errors.Is(err, ErrExpired):
+		utils.BuildErrorResponse(w, http.StatusGone, "Payment request has expired", nil)
+	case err.Error() == "insufficient balance":
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
+	default:
+		logger.Error("Payment request operation failed", logger.Fields{"error": err.Error(), "request_id": requestID})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Payment request operation failed", nil)
+	}
+}
+
+// notify tells a party of a request what the other party did, a notification that can't be saved is logged
+// and does not undo the change
+func (h *Handler) notify(userID uuid.UUID, kind notification.Kind, title, message, reference string) {
+	entry := notification.Notification{
+		UserID:    userID,
+		Kind:      kind,
+		Title:     title,
+		Message:   message,
+		Reference: reference,
+	}
+	if err := h.Wallets.Notifications.Create(&entry); err != nil {
+		logger.Error("Failed to send notification", logger.Fields{"error": err.Error(), "kind": string(kind), "reference": reference})
+	}
+}
+
+func notePart(note string) string {
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for %q", note)
+}
+
+func reasonPart(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(": %s", reason)
+}
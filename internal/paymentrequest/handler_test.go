@@ -0,0 +1,224 @@
+package paymentrequest
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository paying out of wallets
+type memoryRepo struct {
+	mu       sync.Mutex
+	wallets  *wallettest.Repository
+	requests map[uuid.UUID]*Request
+}
+
+func newMemoryRepo(wallets *wallettest.Repository) *memoryRepo {
+	return &memoryRepo{wallets: wallets, requests: make(map[uuid.UUID]*Request)}
+}
+
+func (m *memoryRepo) Create(request *Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request.ID = uuid.New()
+	cp := *request
+	m.requests[request.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) Get(requestID string) (*Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(requestID)
+	if err != nil {
+		return nil, err
+	}
+	request, ok := m.requests[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *request
+	return &cp, nil
+}
+
+func (m *memoryRepo) List(userID string, incoming bool, status Status, limit, offset int) ([]Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Request
+	for _, p := range m.requests {
+		owner := p.RequesterUserID
+		if incoming {
+			owner = p.PayerUserID
+		}
+		if owner.String() == userID && (status == "" || p.Status == status) {
+			list = append(list, *p)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryRepo) pending(requestID string, now time.Time) (*Request, error) {
+	request := m.requests[uuid.MustParse(requestID)]
+	if request.Status == StatusPending && !now.Before(request.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if request.Status != StatusPending {
+		return nil, ErrClosed
+	}
+	return request, nil
+}
+
+func (m *memoryRepo) Pay(requestID string, charge fee.Charge, now time.Time) (*Request, error) {
+	m.mu.Lock()
+	request, err := m.pending(requestID, now)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	reference := "req-" + requestID
+	if err := m.wallets.TransferFunds(request.PayerWalletID.String(), request.RequesterWalletID.String(), request.PayerWalletNumber, request.RequesterWalletNumber, reference, request.Amount, charge, request.Note); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request.Status, request.TransferReference, request.RespondedAt = StatusApproved, reference, &now
+	cp := *request
+	return &cp, nil
+}
+
+func (m *memoryRepo) Close(requestID string, status Status, reason string, now time.Time) (*Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	request, err := m.pending(requestID, now)
+	if err != nil {
+		return nil, err
+	}
+	request.Status, request.DeclineReason, request.RespondedAt = status, reason, &now
+	cp := *request
+	return &cp, nil
+}
+
+func (m *memoryRepo) Expire(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired int64
+	for _, request := range m.requests {
+		if request.Status == StatusPending && !request.ExpiresAt.After(now) {
+			request.Status = StatusExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func TestPaymentRequests(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	repo := newMemoryRepo(env.Repo)
+	h := NewHandler(repo, env.Handler)
+	requester := env.User
+	friendWallet := env.AddWallet(t, "8888888888", "NGN")
+	friend := user.User{ID: friendWallet.UserID}
+
+	rr := env.Do(h.CreatePaymentRequest, "POST", "/wallet/requests", RequestFundsRequest{WalletNumber: env.Wallet.WalletNumber, Amount: 20000}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// the friend asks the user for money
+	env.User = friend
+	rr = env.Do(h.CreatePaymentRequest, "POST", "/wallet/requests", RequestFundsRequest{WalletNumber: env.Wallet.WalletNumber, Amount: 30000, Note: "Dinner"}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	id := wallettest.DecodeData(t, rr)["id"].(string)
+	vars := map[string]string{"id": id}
+
+	// only the wallet asked can answer
+	rr = env.Do(h.ApprovePaymentRequest, "POST", "/wallet/requests/"+id+"/approve", ApprovePaymentRequestRequest{Pin: wallettest.Pin}, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	env.User = requester
+	rr = env.Do(h.ListIncomingRequests, "GET", "/wallet/requests/incoming", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, wallettest.DecodeData(t, rr)["requests"], 1)
+
+	rr = env.Do(h.ApprovePaymentRequest, "POST", "/wallet/requests/"+id+"/approve", ApprovePaymentRequestRequest{Pin: "0000"}, vars)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = env.Do(h.ApprovePaymentRequest, "POST", "/wallet/requests/"+id+"/approve", ApprovePaymentRequestRequest{Pin: wallettest.Pin}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "APPROVED", wallettest.DecodeData(t, rr)["status"])
+	assert.Equal(t, int64(70000), env.Wallet.Balance)
+	assert.Equal(t, int64(30000), friendWallet.Balance)
+
+	rr = env.Do(h.DeclinePaymentRequest, "POST", "/wallet/requests/"+id+"/decline", nil, vars)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// a second request is declined, a third cancelled by the friend and a fourth left to expire
+	env.User = friend
+	ids := make([]string, 3)
+	for i := range ids {
+		rr = env.Do(h.CreatePaymentRequest, "POST", "/wallet/requests", RequestFundsRequest{WalletNumber: env.Wallet.WalletNumber, Amount: 10000}, nil)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		ids[i] = wallettest.DecodeData(t, rr)["id"].(string)
+	}
+
+	rr = env.Do(h.CancelPaymentRequest, "POST", "/wallet/requests/"+ids[1]+"/cancel", nil, map[string]string{"id": ids[1]})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	repo.requests[uuid.MustParse(ids[2])].ExpiresAt = time.Now().Add(-time.Second)
+
+	env.User = requester
+	rr = env.Do(h.DeclinePaymentRequest, "POST", "/wallet/requests/"+ids[0]+"/decline", DeclinePaymentRequestRequest{Reason: "Already paid in cash"}, map[string]string{"id": ids[0]})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "DECLINED", wallettest.DecodeData(t, rr)["status"])
+
+	rr = env.Do(h.ApprovePaymentRequest, "POST", "/wallet/requests/"+ids[2]+"/approve", ApprovePaymentRequestRequest{Pin: wallettest.Pin}, map[string]string{"id": ids[2]})
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Equal(t, int64(70000), env.Wallet.Balance)
+
+	// the requester heard about the approval and the decline, the payer about each request and the cancel
+	var kinds []notification.Kind
+	for _, n := range env.Notifications.Sent() {
+		if n.UserID == friend.ID {
+			kinds = append(kinds, n.Kind)
+		}
+	}
+	assert.Equal(t, []notification.Kind{notification.KindPaymentRequestApproved, notification.KindPaymentRequestDeclined}, kinds)
+	assert.Len(t, env.Notifications.Sent(), 7)
+
+	// the sweep marks the one left pending past its expiry
+	expired, err := repo.Expire(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+	assert.Equal(t, StatusExpired, repo.requests[uuid.MustParse(ids[2])].Status)
+}
+
+func TestPaymentRequestCharged(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	h := NewHandler(newMemoryRepo(env.Repo), env.Handler)
+	requester := env.User
+	friendWallet := env.AddWallet(t, "8888888888", "NGN")
+	env.Fees.Add(fee.Schedule{Name: "Transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindFlat, Flat: 1000})
+
+	env.User = user.User{ID: friendWallet.UserID}
+	rr := env.Do(h.CreatePaymentRequest, "POST", "/wallet/requests", RequestFundsRequest{WalletNumber: env.Wallet.WalletNumber, Amount: 30000}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	id := wallettest.DecodeData(t, rr)["id"].(string)
+
+	// the payer is charged like any other transfer, the requester gets what they asked for
+	env.User = requester
+	rr = env.Do(h.ApprovePaymentRequest, "POST", "/wallet/requests/"+id+"/approve", ApprovePaymentRequestRequest{Pin: wallettest.Pin}, map[string]string{"id": id})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(69000), env.Wallet.Balance)
+	assert.Equal(t, int64(30000), friendWallet.Balance)
+
+	debit, err := env.Repo.GetTransactionByReference("req-" + id + "-debit")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), debit.Fee)
+}
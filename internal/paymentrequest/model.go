@@ -0,0 +1,41 @@
+package paymentrequest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusApproved  Status = "APPROVED"
+	StatusDeclined  Status = "DECLINED"
+	StatusCancelled Status = "CANCELLED"
+	StatusExpired   Status = "EXPIRED"
+)
+
+// Request asks the owner of PayerWalletNumber for money, approving it transfers Amount into the requester's
+// wallet under TransferReference
+type Request struct {
+	ID                    uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	RequesterUserID       uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	RequesterWalletID     uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	RequesterWalletNumber string     `gorm:"not null" json:"requester_wallet_number"`
+	PayerUserID           uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	PayerWalletID         uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	PayerWalletNumber     string     `gorm:"not null" json:"payer_wallet_number"`
+	Amount                int64      `gorm:"not null" json:"amount"`
+	Currency              string     `gorm:"not null" json:"currency"`
+	Note                  string     `json:"note,omitempty"`
+	Status                Status     `gorm:"not null" json:"status"`
+	DeclineReason         string     `json:"decline_reason,omitempty"`
+	TransferReference     string     `json:"transfer_reference,omitempty"`
+	ExpiresAt             time.Time  `gorm:"not null" json:"expires_at"`
+	RespondedAt           *time.Time `json:"responded_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+func (Request) TableName() string { return "payment_requests" }
@@ -0,0 +1,138 @@
+package paymentrequest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrClosed  = errors.New("payment request was already answered or cancelled")
+	ErrExpired = errors.New("payment request has expired")
+)
+
+type Repository interface {
+	Create(request *Request) error
+	Get(requestID string) (*Request, error)
+	List(userID string, incoming bool, status Status, limit, offset int) ([]Request, error)
+	Pay(requestID string, charge fee.Charge, now time.Time) (*Request, error)
+	Close(requestID string, status Status, reason string, now time.Time) (*Request, error)
+	Expire(now time.Time) (int64, error)
+}
+
+type repository struct {
+	db    *gorm.DB
+	funds wallet.Funds
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db, funds: wallet.NewFunds(db)}
+}
+
+func (r *repository) Create(request *Request) error {
+	return r.db.Create(request).Error
+}
+
+func (r *repository) Get(requestID string) (*Request, error) {
+	var request Request
+	if err := r.db.Where("id = ?", requestID).First(&request).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// List lists the requests userID was sent when incoming is set and the ones they sent otherwise
+func (r *repository) List(userID string, incoming bool, status Status, limit, offset int) ([]Request, error) {
+	query := r.db.Where("requester_user_id = ?", userID)
+	if incoming {
+		query = r.db.Where("payer_user_id = ?", userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []Request
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&requests).Error
+	return requests, err
+}
+
+// Pay approves a pending request and makes its transfer in the same database transaction, so a request is
+// never paid twice. The payer pays charge on top of the amount requested.
+func (r *repository) Pay(requestID string, charge fee.Charge, now time.Time) (*Request, error) {
+	var request Request
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, requestID, now, &request); err != nil {
+			return err
+		}
+
+		reference := "req-" + request.ID.String()
+		description := request.Note
+		if description == "" {
+			description = "Payment request"
+		}
+		if err := r.funds.Transfer(tx, request.PayerWalletID.String(), request.RequesterWalletID.String(), request.PayerWalletNumber, request.RequesterWalletNumber, reference, request.Amount, charge, description); err != nil {
+			return err
+		}
+
+		request.Status = StatusApproved
+		request.TransferReference = reference
+		request.RespondedAt = &now
+		return tx.Model(&Request{}).Where("id = ?", request.ID).Updates(map[string]interface{}{
+			"status":             request.Status,
+			"transfer_reference": request.TransferReference,
+			"responded_at":       request.RespondedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Close declines or cancels a pending request, reason is kept for a decline
+func (r *repository) Close(requestID string, status Status, reason string, now time.Time) (*Request, error) {
+	var request Request
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, requestID, now, &request); err != nil {
+			return err
+		}
+
+		request.Status = status
+		request.DeclineReason = reason
+		request.RespondedAt = &now
+		return tx.Model(&Request{}).Where("id = ?", request.ID).Updates(map[string]interface{}{
+			"status":         request.Status,
+			"decline_reason": request.DeclineReason,
+			"responded_at":   request.RespondedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Expire marks the pending requests whose expiry has passed and returns how many it marked
+func (r *repository) Expire(now time.Time) (int64, error) {
+	res := r.db.Model(&Request{}).
+		Where("status = ? AND expires_at <= ?", StatusPending, now).
+		Update("status", StatusExpired)
+	return res.RowsAffected, res.Error
+}
+
+func lockPending(tx *gorm.DB, requestID string, now time.Time, request *Request) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", requestID).First(request).Error; err != nil {
+		return err
+	}
+	if request.Status == StatusExpired || (request.Status == StatusPending && !now.Before(request.ExpiresAt)) {
+		return ErrExpired
+	}
+	if request.Status != StatusPending {
+		return ErrClosed
+	}
+	return nil
+}
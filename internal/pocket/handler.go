@@ -0,0 +1,263 @@
+package pocket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+const maxName = 50
+
+// Handler serves the pockets of a user's wallets, Wallets finds the wallet a pocket belongs to
+type Handler struct {
+	Repo    Repository
+	Wallets *wallet.Handler
+}
+
+func NewHandler(repo Repository, wallets *wallet.Handler) *Handler {
+	return &Handler{Repo: repo, Wallets: wallets}
+}
+
+type CreatePocketRequest struct {
+	Name         string     `json:"name"`
+	Currency     string     `json:"currency"`
+	TargetAmount *int64     `json:"target_amount"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// CreatePocket adds an empty pocket to the user's wallet in the currency asked for
+func (h *Handler) CreatePocket(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CreatePocketRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := wallet.ResolveCurrency(w, req.Currency)
+	if !ok {
+		return
+	}
+
+	pocket := Pocket{Name: strings.TrimSpace(req.Name), TargetAmount: req.TargetAmount, LockedUntil: req.LockedUntil}
+	if !checkPocket(w, &pocket, req.LockedUntil, time.Now()) {
+		return
+	}
+
+	owner, ok := h.Wallets.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+	pocket.WalletID = owner.ID
+
+	if err := h.Repo.Create(&pocket); err != nil {
+		writeError(w, err, "")
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Pocket created", pocket)
+}
+
+// ListPockets lists the pockets of the wallet named by ?currency=
+func (h *Handler) ListPockets(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	owner, ok := h.Wallets.QueryWallet(w, r, usr)
+	if !ok {
+		return
+	}
+
+	pockets, err := h.Repo.List(owner.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch pockets", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Pockets", map[string]interface{}{
+		"pockets": pockets,
+	})
+}
+
+func (h *Handler) GetPocket(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	pocket, ok := h.ownPocket(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Pocket", pocket)
+}
+
+// UpdatePocketRequest changes only the fields that are sent
+type UpdatePocketRequest struct {
+	Name         *string    `json:"name"`
+	TargetAmount *int64     `json:"target_amount"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// UpdatePocket renames a pocket or changes its target or lock, a lock that is running can be extended but not
+// brought forward
+func (h *Handler) UpdatePocket(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req UpdatePocketRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	pocket, ok := h.ownPocket(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if req.LockedUntil != nil && pocket.Locked(now) && req.LockedUntil.Before(*pocket.LockedUntil) {
+		utils.BuildErrorResponse(w, http.StatusConflict, "Pocket is locked, its lock can only be extended", nil)
+		return
+	}
+
+	if req.Name != nil {
+		pocket.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.TargetAmount != nil {
+		pocket.TargetAmount = req.TargetAmount
+	}
+	if req.LockedUntil != nil {
+		pocket.LockedUntil = req.LockedUntil
+	}
+	if !checkPocket(w, pocket, req.LockedUntil, now) {
+		return
+	}
+
+	if err := h.Repo.Update(pocket); err != nil {
+		writeError(w, err, pocket.ID.String())
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Pocket updated", pocket)
+}
+
+type PocketMoveRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+// FundPocket moves money from the wallet's available balance into a pocket
+func (h *Handler) FundPocket(w http.ResponseWriter, r *http.Request) {
+	h.move(w, r, h.Repo.Fund, "Pocket funded")
+}
+
+// DrawPocket moves money out of an unlocked pocket back into the wallet's main balance
+func (h *Handler) DrawPocket(w http.ResponseWriter, r *http.Request) {
+	h.move(w, r, func(pocketID, reference string, amount int64) (*Pocket, error) {
+		return h.Repo.Draw(pocketID, reference, amount, time.Now())
+	}, "Pocket withdrawn")
+}
+
+func (h *Handler) move(w http.ResponseWriter, r *http.Request, move func(pocketID, reference string, amount int64) (*Pocket, error), message string) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req PocketMoveRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+	if req.Amount <= 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount, must be greater than zero", nil)
+		return
+	}
+
+	pocket, ok := h.ownPocket(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	pocket, err := move(pocket.ID.String(), pocketReference(), req.Amount)
+	if err != nil {
+		writeError(w, err, mux.Vars(r)["id"])
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, message, pocket)
+}
+
+// DeletePocket removes an unlocked pocket and returns what was left in it to the main balance
+func (h *Handler) DeletePocket(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	pocket, ok := h.ownPocket(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	pocket, err := h.Repo.Delete(pocket.ID.String(), pocketReference(), time.Now())
+	if err != nil {
+		writeError(w, err, mux.Vars(r)["id"])
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Pocket deleted", pocket)
+}
+
+// ownPocket loads a pocket of one of the user's wallets, any other pocket reads as not found
+func (h *Handler) ownPocket(w http.ResponseWriter, usr user.User, pocketID string) (*Pocket, bool) {
+	pocket, err := h.Repo.Get(pocketID)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Pocket not found", nil)
+		return nil, false
+	}
+
+	owner, err := h.Wallets.Repo.GetWalletByID(pocket.WalletID.String())
+	if err != nil || owner.UserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Pocket not found", nil)
+		return nil, false
+	}
+	return pocket, true
+}
+
+// checkPocket validates the fields a user sets on a pocket, lockedUntil is the lock they asked for if any
+func checkPocket(w http.ResponseWriter, pocket *Pocket, lockedUntil *time.Time, now time.Time) bool {
+	switch {
+	case pocket.Name == "":
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "name is required", nil)
+	case len(pocket.Name) > maxName:
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("name can be at most %d characters", maxName), nil)
+	case pocket.TargetAmount != nil && *pocket.TargetAmount <= 0:
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "target_amount must be greater than zero", nil)
+	case lockedUntil != nil && !lockedUntil.After(now):
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "locked_until must be in the future", nil)
+	default:
+		return true
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, err error, pocketID string) {
+	switch {
+	case errors.Is(err, ErrNameTaken):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Wallet already has a pocket with that name", nil)
+	case errors.Is(err, ErrLocked):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Pocket is locked", nil)
+	case errors.Is(err, ErrInsufficient):
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient pocket balance", nil)
+	case err.Error() == "insufficient balance":
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient available balance", nil)
+	default:
+		logger.Error("Pocket operation failed", logger.Fields{"error": err.Error(), "pocket_id": pocketID})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Pocket operation failed", nil)
+	}
+}
+
+func pocketReference() string {
+	return fmt.Sprintf("pkt-%d", time.Now().UnixNano())
+}
@@ -0,0 +1,224 @@
+package pocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository setting money aside in wallets
+type memoryRepo struct {
+	mu      sync.Mutex
+	wallets *wallettest.Repository
+	pockets map[uuid.UUID]*Pocket
+}
+
+func newMemoryRepo(wallets *wallettest.Repository) *memoryRepo {
+	return &memoryRepo{wallets: wallets, pockets: make(map[uuid.UUID]*Pocket)}
+}
+
+func (m *memoryRepo) nameTaken(pocket *Pocket) bool {
+	for _, p := range m.pockets {
+		if p.ID != pocket.ID && p.WalletID == pocket.WalletID && strings.EqualFold(p.Name, pocket.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryRepo) Create(pocket *Pocket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nameTaken(pocket) {
+		return ErrNameTaken
+	}
+	pocket.ID = uuid.New()
+	pocket.CreatedAt = time.Now()
+	cp := *pocket
+	m.pockets[pocket.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) Get(pocketID string) (*Pocket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(pocketID)
+	if err != nil {
+		return nil, err
+	}
+	pocket, ok := m.pockets[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *pocket
+	return &cp, nil
+}
+
+func (m *memoryRepo) List(walletID string) ([]Pocket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pockets []Pocket
+	for _, p := range m.pockets {
+		if p.WalletID.String() == walletID {
+			pockets = append(pockets, *p)
+		}
+	}
+	sort.Slice(pockets, func(i, j int) bool { return pockets[i].CreatedAt.Before(pockets[j].CreatedAt) })
+	return pockets, nil
+}
+
+func (m *memoryRepo) Update(pocket *Pocket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nameTaken(pocket) {
+		return ErrNameTaken
+	}
+	stored := m.pockets[pocket.ID]
+	stored.Name, stored.TargetAmount, stored.LockedUntil = pocket.Name, pocket.TargetAmount, pocket.LockedUntil
+	return nil
+}
+
+func (m *memoryRepo) Fund(pocketID, reference string, amount int64) (*Pocket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pocket := m.pockets[uuid.MustParse(pocketID)]
+	if err := m.wallets.SetAside(nil, pocket.WalletID.String(), amount); err != nil {
+		return nil, err
+	}
+	pocket.Balance += amount
+	m.wallets.CreateTransaction(transaction(pocket, reference, wallet.TransactionDebit, amount, "Moved to pocket "+pocket.Name))
+	cp := *pocket
+	return &cp, nil
+}
+
+func (m *memoryRepo) Draw(pocketID, reference string, amount int64, now time.Time) (*Pocket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pocket := m.pockets[uuid.MustParse(pocketID)]
+	if pocket.Locked(now) {
+		return nil, ErrLocked
+	}
+	if amount > pocket.Balance {
+		return nil, ErrInsufficient
+	}
+	m.wallets.PutBack(nil, pocket.WalletID.String(), amount)
+	pocket.Balance -= amount
+	m.wallets.CreateTransaction(transaction(pocket, reference, wallet.TransactionCredit, amount, "Moved from pocket "+pocket.Name))
+	cp := *pocket
+	return &cp, nil
+}
+
+func (m *memoryRepo) Delete(pocketID, reference string, now time.Time) (*Pocket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pocket := m.pockets[uuid.MustParse(pocketID)]
+	if pocket.Locked(now) {
+		return nil, ErrLocked
+	}
+	if pocket.Balance > 0 {
+		m.wallets.PutBack(nil, pocket.WalletID.String(), pocket.Balance)
+		m.wallets.CreateTransaction(transaction(pocket, reference, wallet.TransactionCredit, pocket.Balance, "Closed pocket "+pocket.Name))
+	}
+	delete(m.pockets, pocket.ID)
+	return pocket, nil
+}
+
+func TestPockets(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	h := NewHandler(newMemoryRepo(env.Repo), env.Handler)
+
+	create := func(body CreatePocketRequest) *httptest.ResponseRecorder {
+		return env.Do(h.CreatePocket, "POST", "/wallet/pockets", body, nil)
+	}
+	target := int64(500000)
+	rr := create(CreatePocketRequest{Name: "Rent", TargetAmount: &target})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rent := wallettest.DecodeData(t, rr)["id"].(string)
+
+	lockedUntil := time.Now().Add(30 * 24 * time.Hour)
+	rr = create(CreatePocketRequest{Name: "Holiday", LockedUntil: &lockedUntil})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	holiday := wallettest.DecodeData(t, rr)["id"].(string)
+
+	rr = create(CreatePocketRequest{Name: "rent"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	past := time.Now().Add(-time.Hour)
+	rr = create(CreatePocketRequest{Name: "Car", LockedUntil: &past})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	move := func(handler http.HandlerFunc, id string, amount int64) *httptest.ResponseRecorder {
+		return env.Do(handler, "POST", "/wallet/pockets/"+id, PocketMoveRequest{Amount: amount}, map[string]string{"id": id})
+	}
+
+	rr = move(h.FundPocket, rent, 30000)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, float64(30000), wallettest.DecodeData(t, rr)["balance"])
+	rr = move(h.FundPocket, holiday, 50000)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// only the 20000 left outside the pockets can be spent or pocketed
+	rr = move(h.FundPocket, rent, 20001)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, int64(20000), env.Wallet.AvailableBalance())
+	assert.Equal(t, int64(100000), env.Wallet.Balance)
+
+	rr = move(h.DrawPocket, holiday, 10000)
+	assert.Equal(t, http.StatusConflict, rr.Code, "a locked pocket can't be taken out of")
+	rr = move(h.DrawPocket, rent, 40000)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = move(h.DrawPocket, rent, 10000)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, float64(20000), wallettest.DecodeData(t, rr)["balance"])
+
+	var moves []wallet.Transaction
+	for _, tx := range env.Repo.Transactions() {
+		if tx.Category == wallet.CategoryPocket {
+			moves = append(moves, tx)
+		}
+	}
+	require.Len(t, moves, 3)
+	for _, tx := range moves {
+		assert.Equal(t, env.Wallet.ID, tx.WalletID)
+		assert.NotNil(t, tx.PocketID)
+	}
+
+	rr = env.Do(env.Handler.GetWalletBalance, "GET", "/wallet/balance", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	balance := wallettest.DecodeData(t, rr)
+	assert.Equal(t, float64(100000), balance["balance"])
+	assert.Equal(t, float64(30000), balance["main_balance"])
+	assert.Equal(t, float64(70000), balance["pocket_balance"])
+	assert.Equal(t, float64(30000), balance["available_balance"])
+
+	rr = env.Do(h.ListPockets, "GET", "/wallet/pockets", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, wallettest.DecodeData(t, rr)["pockets"], 2)
+
+	// a running lock can be extended but not brought forward
+	sooner := time.Now().Add(time.Hour)
+	rr = env.Do(h.UpdatePocket, "PUT", "/wallet/pockets/"+holiday, UpdatePocketRequest{LockedUntil: &sooner}, map[string]string{"id": holiday})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = env.Do(h.DeletePocket, "DELETE", "/wallet/pockets/"+holiday, nil, map[string]string{"id": holiday})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = env.Do(h.DeletePocket, "DELETE", "/wallet/pockets/"+rent, nil, map[string]string{"id": rent})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(50000), env.Wallet.PocketBalance)
+	assert.Equal(t, int64(50000), env.Wallet.AvailableBalance())
+
+	env.User = user.User{ID: uuid.New()}
+	rr = env.Do(h.GetPocket, "GET", "/wallet/pockets/"+holiday, nil, map[string]string{"id": holiday})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
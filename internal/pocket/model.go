@@ -0,0 +1,26 @@
+package pocket
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pocket ring-fences part of a wallet's balance for a goal. The money stays in the wallet's Balance but not in
+// what it can spend, the wallet's PocketBalance is the sum of its pockets. A pocket can't be taken out of
+// before LockedUntil.
+type Pocket struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	WalletID     uuid.UUID  `gorm:"type:uuid;not null" json:"wallet_id"`
+	Name         string     `gorm:"not null" json:"name"`
+	Balance      int64      `gorm:"not null;default:0" json:"balance"`
+	TargetAmount *int64     `json:"target_amount,omitempty"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Locked reports whether the pocket's money can't be moved out at now
+func (p *Pocket) Locked(now time.Time) bool {
+	return p.LockedUntil != nil && now.Before(*p.LockedUntil)
+}
@@ -0,0 +1,181 @@
+package pocket
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNameTaken    = errors.New("wallet already has a pocket with that name")
+	ErrLocked       = errors.New("pocket is locked")
+	ErrInsufficient = errors.New("pocket balance is too low")
+)
+
+type Repository interface {
+	Create(pocket *Pocket) error
+	Get(pocketID string) (*Pocket, error)
+	List(walletID string) ([]Pocket, error)
+	Update(pocket *Pocket) error
+	Fund(pocketID, reference string, amount int64) (*Pocket, error)
+	Draw(pocketID, reference string, amount int64, now time.Time) (*Pocket, error)
+	Delete(pocketID, reference string, now time.Time) (*Pocket, error)
+}
+
+type repository struct {
+	db    *gorm.DB
+	funds wallet.Funds
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db, funds: wallet.NewFunds(db)}
+}
+
+func (r *repository) Create(pocket *Pocket) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkName(tx, pocket); err != nil {
+			return err
+		}
+		return tx.Create(pocket).Error
+	})
+}
+
+func (r *repository) Get(pocketID string) (*Pocket, error) {
+	var pocket Pocket
+	if err := r.db.Where("id = ?", pocketID).First(&pocket).Error; err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+func (r *repository) List(walletID string) ([]Pocket, error) {
+	var pockets []Pocket
+	err := r.db.Where("wallet_id = ?", walletID).Order("created_at").Find(&pockets).Error
+	return pockets, err
+}
+
+// Update saves the pocket's name, target and lock, its balance only changes through Fund and Draw
+func (r *repository) Update(pocket *Pocket) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkName(tx, pocket); err != nil {
+			return err
+		}
+		return tx.Model(&Pocket{}).Where("id = ?", pocket.ID).Updates(map[string]interface{}{
+			"name":          pocket.Name,
+			"target_amount": pocket.TargetAmount,
+			"locked_until":  pocket.LockedUntil,
+		}).Error
+	})
+}
+
+// Fund moves amount of the wallet's available balance into the pocket
+func (r *repository) Fund(pocketID, reference string, amount int64) (*Pocket, error) {
+	var pocket Pocket
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", pocketID).First(&pocket).Error; err != nil {
+			return err
+		}
+
+		if err := r.funds.SetAside(tx, pocket.WalletID.String(), amount); err != nil {
+			return err
+		}
+
+		pocket.Balance += amount
+		return record(tx, &pocket, reference, wallet.TransactionDebit, amount, "Moved to pocket "+pocket.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+// Draw moves amount out of an unlocked pocket back into the wallet's main balance
+func (r *repository) Draw(pocketID, reference string, amount int64, now time.Time) (*Pocket, error) {
+	var pocket Pocket
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", pocketID).First(&pocket).Error; err != nil {
+			return err
+		}
+		if pocket.Locked(now) {
+			return ErrLocked
+		}
+		if amount > pocket.Balance {
+			return ErrInsufficient
+		}
+
+		if err := r.funds.PutBack(tx, pocket.WalletID.String(), amount); err != nil {
+			return err
+		}
+
+		pocket.Balance -= amount
+		return record(tx, &pocket, reference, wallet.TransactionCredit, amount, "Moved from pocket "+pocket.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+// Delete removes an unlocked pocket, whatever is left in it goes back into the main balance first
+func (r *repository) Delete(pocketID, reference string, now time.Time) (*Pocket, error) {
+	var pocket Pocket
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", pocketID).First(&pocket).Error; err != nil {
+			return err
+		}
+		if pocket.Locked(now) {
+			return ErrLocked
+		}
+
+		if pocket.Balance > 0 {
+			if err := r.funds.PutBack(tx, pocket.WalletID.String(), pocket.Balance); err != nil {
+				return err
+			}
+			if err := tx.Create(transaction(&pocket, reference, wallet.TransactionCredit, pocket.Balance, "Closed pocket "+pocket.Name)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&Pocket{}, "id = ?", pocket.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pocket, nil
+}
+
+func record(tx *gorm.DB, pocket *Pocket, reference string, txType wallet.TransactionType, amount int64, description string) error {
+	if err := tx.Model(&Pocket{}).Where("id = ?", pocket.ID).UpdateColumn("balance", pocket.Balance).Error; err != nil {
+		return err
+	}
+	return tx.Create(transaction(pocket, reference, txType, amount, description)).Error
+}
+
+// transaction records a move on the wallet's side, a debit takes money out of the main balance into the
+// pocket and a credit brings it back
+func transaction(pocket *Pocket, reference string, txType wallet.TransactionType, amount int64, description string) *wallet.Transaction {
+	return &wallet.Transaction{
+		WalletID:    pocket.WalletID,
+		Reference:   reference,
+		Category:    wallet.CategoryPocket,
+		Type:        txType,
+		Amount:      amount,
+		Status:      wallet.TransactionSuccess,
+		Description: description,
+		PocketID:    &pocket.ID,
+	}
+}
+
+func checkName(tx *gorm.DB, pocket *Pocket) error {
+	var count int64
+	if err := tx.Model(&Pocket{}).Where("wallet_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", pocket.WalletID, pocket.Name, pocket.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrNameTaken
+	}
+	return nil
+}
@@ -5,26 +5,45 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"github.com/zjoart/go-paystack-wallet/internal/admin"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
 	"github.com/zjoart/go-paystack-wallet/internal/auth"
+	"github.com/zjoart/go-paystack-wallet/internal/batch"
+	"github.com/zjoart/go-paystack-wallet/internal/beneficiary"
+	"github.com/zjoart/go-paystack-wallet/internal/escrow"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
 	"github.com/zjoart/go-paystack-wallet/internal/key"
 	"github.com/zjoart/go-paystack-wallet/internal/middleware"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/paymentrequest"
+	"github.com/zjoart/go-paystack-wallet/internal/pocket"
+	"github.com/zjoart/go-paystack-wallet/internal/schedule"
 	"github.com/zjoart/go-paystack-wallet/internal/user"
 	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
 	"github.com/zjoart/go-paystack-wallet/pkg/config"
 	"github.com/zjoart/go-paystack-wallet/pkg/database"
 	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/fx"
 	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
 	"github.com/zjoart/go-paystack-wallet/pkg/utils"
 	"golang.org/x/time/rate"
 )
 
-func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisClient, walletRepo wallet.Repository) http.Handler {
+func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisClient, paystackClient paystack.Client, walletRepo wallet.Repository, rates fx.RateProvider) http.Handler {
 	userRepo := user.NewRepository(database.DB)
 	keyRepo := key.NewRepository(database.DB)
+	beneficiaryRepo := beneficiary.NewRepository(database.DB)
+	auditRepo := audit.NewRepository(database.DB)
+	deliveryRepo := webhook.NewRepository(database.DB)
+	notificationRepo := notification.NewRepository(database.DB)
+	feeRepo := fee.NewRepository(database.DB)
 
 	authHandler := auth.NewHandler(cfg, userRepo)
 	keyHandler := key.NewHandler(cfg, keyRepo)
@@ -32,6 +51,7 @@ func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisC
 	r.Use(middleware.LoggingMiddleware)
 
 	rateLimiter := middleware.NewRateLimiter(rate.Limit(cfg.RateLimit), cfg.RateBurst)
+	idempotency := middleware.NewIdempotency(middleware.NewRedisIdempotencyStore(redisClient.Client), 24*time.Hour)
 
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		utils.BuildSuccessResponse(w, http.StatusOK, "Service is running", nil)
@@ -50,12 +70,21 @@ func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisC
 	keysR.HandleFunc("", keyHandler.ListAPIKeys).Methods("GET")
 	keysR.HandleFunc("/revoke", keyHandler.RevokeAPIKey).Methods("POST")
 
-	walletHandler := wallet.NewHandler(cfg, walletRepo, redisClient)
+	walletHandler := wallet.NewHandler(cfg, walletRepo, beneficiaryRepo, auditRepo, deliveryRepo, notificationRepo, feeRepo, redisClient, paystackClient, rates)
+	beneficiaryHandler := beneficiary.NewHandler(cfg, beneficiaryRepo, redisClient, paystackClient)
+	notificationHandler := notification.NewHandler(notificationRepo)
+	scheduleHandler := schedule.NewHandler(schedule.NewRepository(database.DB), walletHandler)
+	batchHandler := batch.NewHandler(batch.NewRepository(database.DB), walletHandler)
+	requestHandler := paymentrequest.NewHandler(paymentrequest.NewRepository(database.DB), walletHandler)
+	escrowRepo := escrow.NewRepository(database.DB)
+	escrowHandler := escrow.NewHandler(escrowRepo, walletHandler)
+	pocketHandler := pocket.NewHandler(pocket.NewRepository(database.DB), walletHandler)
 
 	walletR := r.PathPrefix("/wallet").Subrouter()
 	walletR.Use(rateLimiter.Limit)
 
 	walletR.HandleFunc("/paystack/webhook", walletHandler.PaystackWebhook).Methods("POST")
+	walletR.HandleFunc("/deposit/callback", walletHandler.DepositCallback).Methods("GET")
 
 	opsR := walletR.PathPrefix("").Subrouter()
 	opsR.Use(auth.UnifiedAuthMiddleware(cfg, userRepo, keyRepo))
@@ -63,13 +92,95 @@ func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisC
 	opsR.HandleFunc("/create",
 		walletHandler.CreateWallet).Methods("POST")
 
+	opsR.Handle("/pin", auth.RequireRecentLogin(0)(http.HandlerFunc(walletHandler.ChangePin))).Methods("PUT")
+	opsR.Handle("/pin/reset", auth.RequireRecentLogin(cfg.PinResetMaxAuthAge)(http.HandlerFunc(walletHandler.ResetPin))).Methods("POST")
+
 	opsR.Handle("", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.GetWallet))).Methods("GET")
-	opsR.Handle("/deposit", auth.RequirePermission(string(key.PermissionDeposit))(http.HandlerFunc(walletHandler.WalletDeposit))).Methods("POST")
+	opsR.Handle("/list", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.ListWallets))).Methods("GET")
+	opsR.Handle("/deposit", auth.RequirePermission(string(key.PermissionDeposit))(idempotency.Handle(http.HandlerFunc(walletHandler.WalletDeposit)))).Methods("POST")
+	opsR.Handle("/dedicated-account", auth.RequirePermission(string(key.PermissionDeposit))(http.HandlerFunc(walletHandler.RequestDedicatedAccount))).Methods("POST")
+	opsR.Handle("/deposit/{reference}/refund", auth.RequirePermission(string(key.PermissionWithdrawal))(idempotency.Handle(http.HandlerFunc(walletHandler.RefundDeposit)))).Methods("POST")
 	opsR.Handle("/deposit/{reference}/status", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.GetDepositStatus))).Methods("GET")
-	opsR.Handle("/transfer", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(walletHandler.TransferFunds))).Methods("POST")
+	opsR.Handle("/withdraw", auth.RequirePermission(string(key.PermissionWithdrawal))(idempotency.Handle(http.HandlerFunc(walletHandler.Withdraw)))).Methods("POST")
+	opsR.Handle("/transfer", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(walletHandler.TransferFunds)))).Methods("POST")
+	opsR.Handle("/fees/quote", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.QuoteFee))).Methods("POST")
+	opsR.Handle("/convert/quote", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(walletHandler.CreateQuote))).Methods("POST")
+	opsR.Handle("/convert", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(walletHandler.Convert)))).Methods("POST")
+	opsR.Handle("/holds", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(walletHandler.PlaceHold)))).Methods("POST")
+	opsR.Handle("/holds", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.ListHolds))).Methods("GET")
+	opsR.Handle("/holds/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.GetHold))).Methods("GET")
+	opsR.Handle("/holds/{id}/capture", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(walletHandler.CaptureHold)))).Methods("POST")
+	opsR.Handle("/holds/{id}/release", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(walletHandler.ReleaseHold))).Methods("POST")
+	opsR.Handle("/schedules", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(scheduleHandler.CreateSchedule)))).Methods("POST")
+	opsR.Handle("/schedules", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(scheduleHandler.ListSchedules))).Methods("GET")
+	opsR.Handle("/schedules/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(scheduleHandler.GetSchedule))).Methods("GET")
+	opsR.Handle("/schedules/{id}", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(scheduleHandler.UpdateSchedule))).Methods("PUT")
+	opsR.Handle("/schedules/{id}", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(scheduleHandler.CancelSchedule))).Methods("DELETE")
+	opsR.Handle("/batches", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(batchHandler.CreateBatch)))).Methods("POST")
+	opsR.Handle("/batches", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(batchHandler.ListBatches))).Methods("GET")
+	opsR.Handle("/batches/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(batchHandler.GetBatch))).Methods("GET")
+	opsR.Handle("/batches/{id}/report", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(batchHandler.GetBatchReport))).Methods("GET")
+	opsR.Handle("/requests", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(requestHandler.CreatePaymentRequest)))).Methods("POST")
+	opsR.Handle("/requests/incoming", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(requestHandler.ListIncomingRequests))).Methods("GET")
+	opsR.Handle("/requests/outgoing", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(requestHandler.ListOutgoingRequests))).Methods("GET")
+	opsR.Handle("/requests/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(requestHandler.GetPaymentRequest))).Methods("GET")
+	opsR.Handle("/requests/{id}/approve", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(requestHandler.ApprovePaymentRequest)))).Methods("POST")
+	opsR.Handle("/requests/{id}/decline", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(requestHandler.DeclinePaymentRequest))).Methods("POST")
+	opsR.Handle("/requests/{id}/cancel", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(requestHandler.CancelPaymentRequest))).Methods("POST")
+	opsR.Handle("/escrows", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(escrowHandler.CreateEscrow)))).Methods("POST")
+	opsR.Handle("/escrows", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(escrowHandler.ListEscrows))).Methods("GET")
+	opsR.Handle("/escrows/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(escrowHandler.GetEscrow))).Methods("GET")
+	opsR.Handle("/escrows/{id}/release", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(escrowHandler.ReleaseEscrow))).Methods("POST")
+	opsR.Handle("/escrows/{id}/dispute", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(escrowHandler.DisputeEscrow))).Methods("POST")
+	opsR.Handle("/escrows/{id}/refund", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(escrowHandler.RefundEscrow))).Methods("POST")
+	opsR.Handle("/pockets", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(pocketHandler.CreatePocket))).Methods("POST")
+	opsR.Handle("/pockets", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(pocketHandler.ListPockets))).Methods("GET")
+	opsR.Handle("/pockets/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(pocketHandler.GetPocket))).Methods("GET")
+	opsR.Handle("/pockets/{id}", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(pocketHandler.UpdatePocket))).Methods("PUT")
+	opsR.Handle("/pockets/{id}", auth.RequirePermission(string(key.PermissionTransfer))(http.HandlerFunc(pocketHandler.DeletePocket))).Methods("DELETE")
+	opsR.Handle("/pockets/{id}/fund", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(pocketHandler.FundPocket)))).Methods("POST")
+	opsR.Handle("/pockets/{id}/withdraw", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(pocketHandler.DrawPocket)))).Methods("POST")
 	opsR.Handle("/balance", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.GetWalletBalance))).Methods("GET")
+	opsR.Handle("/balance/verify", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.VerifyWalletBalance))).Methods("GET")
+	opsR.Handle("/transactions/{reference}/reverse", auth.RequirePermission(string(key.PermissionTransfer))(idempotency.Handle(http.HandlerFunc(walletHandler.ReverseTransfer)))).Methods("POST")
 	opsR.Handle("/transactions", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(walletHandler.GetTransactions))).Methods("GET")
 
+	opsR.Handle("/notifications", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(notificationHandler.ListNotifications))).Methods("GET")
+	opsR.Handle("/notifications/{id}/read", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(notificationHandler.MarkRead))).Methods("POST")
+
+	opsR.Handle("/banks", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(beneficiaryHandler.ListBanks))).Methods("GET")
+	opsR.Handle("/banks/resolve", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(beneficiaryHandler.ResolveAccount))).Methods("GET")
+	opsR.Handle("/beneficiaries", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(beneficiaryHandler.ListBeneficiaries))).Methods("GET")
+	opsR.Handle("/beneficiaries", auth.RequireAnyPermission(string(key.PermissionTransfer), string(key.PermissionWithdrawal))(http.HandlerFunc(beneficiaryHandler.CreateBeneficiary))).Methods("POST")
+	opsR.Handle("/beneficiaries/{id}", auth.RequirePermission(string(key.PermissionRead))(http.HandlerFunc(beneficiaryHandler.GetBeneficiary))).Methods("GET")
+	opsR.Handle("/beneficiaries/{id}", auth.RequireAnyPermission(string(key.PermissionTransfer), string(key.PermissionWithdrawal))(http.HandlerFunc(beneficiaryHandler.UpdateBeneficiary))).Methods("PUT")
+	opsR.Handle("/beneficiaries/{id}", auth.RequireAnyPermission(string(key.PermissionTransfer), string(key.PermissionWithdrawal))(http.HandlerFunc(beneficiaryHandler.DeleteBeneficiary))).Methods("DELETE")
+
+	adminHandler := admin.NewHandler(redisClient, auditRepo, deliveryRepo, walletRepo, escrowRepo, feeRepo, userRepo)
+
+	adminR := r.PathPrefix("/admin").Subrouter()
+	adminR.Use(rateLimiter.Limit)
+	adminR.Use(auth.JWTMiddleware(cfg, userRepo))
+	adminR.Use(auth.RequireAdmin)
+	adminR.HandleFunc("/dlq", adminHandler.ListDLQ).Methods("GET")
+	adminR.HandleFunc("/dlq/replay", adminHandler.ReplayDLQ).Methods("POST")
+	adminR.HandleFunc("/dlq/purge", adminHandler.PurgeDLQ).Methods("POST")
+	adminR.HandleFunc("/webhooks/deliveries", adminHandler.ListDeliveries).Methods("GET")
+	adminR.HandleFunc("/webhooks/deliveries/{id}", adminHandler.GetDelivery).Methods("GET")
+	adminR.HandleFunc("/webhooks/deliveries/{id}/replay", adminHandler.ReplayDelivery).Methods("POST")
+	adminR.HandleFunc("/transfers/{reference}/reverse", adminHandler.ReverseTransfer).Methods("POST")
+	adminR.HandleFunc("/escrows", adminHandler.ListEscrows).Methods("GET")
+	adminR.HandleFunc("/escrows/{id}/resolve", adminHandler.ResolveEscrow).Methods("POST")
+	adminR.HandleFunc("/holds/{id}/release", adminHandler.ReleaseHold).Methods("POST")
+	adminR.HandleFunc("/deposits/{reference}/approve", adminHandler.ApproveDeposit).Methods("POST")
+	adminR.HandleFunc("/deposits/{reference}/reject", adminHandler.RejectDeposit).Methods("POST")
+	adminR.HandleFunc("/fees", adminHandler.ListFeeSchedules).Methods("GET")
+	adminR.HandleFunc("/fees", adminHandler.CreateFeeSchedule).Methods("POST")
+	adminR.HandleFunc("/fees/{id}", adminHandler.GetFeeSchedule).Methods("GET")
+	adminR.HandleFunc("/fees/{id}", adminHandler.UpdateFeeSchedule).Methods("PUT")
+	adminR.HandleFunc("/fees/{id}", adminHandler.DeleteFeeSchedule).Methods("DELETE")
+	adminR.HandleFunc("/users/{id}/tier", adminHandler.SetUserTier).Methods("PUT")
+
 	if cfg.Env != "production" {
 
 		r.HandleFunc("/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
@@ -82,7 +193,11 @@ func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisC
 
 			baseURL := "/"
 			modifiedContent := strings.Replace(string(content), "{{BASE_URL}}", baseURL, -1)
-			modifiedContent = strings.Replace(modifiedContent, "{{MIN_TRANSACTION_AMOUNT}}", fmt.Sprintf("%d", cfg.MinTransactionAmount), -1)
+			minimums := make([]string, 0, len(config.SupportedCurrencies))
+			for _, currency := range config.SupportedCurrencies {
+				minimums = append(minimums, fmt.Sprintf("%s %d", currency, cfg.MinTransactionAmount(currency)))
+			}
+			modifiedContent = strings.Replace(modifiedContent, "{{MIN_TRANSACTION_AMOUNTS}}", strings.Join(minimums, ", "), -1)
 
 			w.Header().Set("Content-Type", "application/yaml")
 			w.Write([]byte(modifiedContent))
@@ -97,7 +212,8 @@ func RegisterRoutes(r *mux.Router, cfg config.Config, redisClient *events.RedisC
 	corsObj := handlers.CORS(
 		handlers.AllowedOrigins(cfg.AllowedOrigins),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", middleware.IdempotencyKeyHeader}),
+		handlers.ExposedHeaders([]string{middleware.IdempotentReplayedHeader}),
 	)
 
 	return corsObj(r)
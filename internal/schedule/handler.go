@@ -0,0 +1,270 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// Handler serves a user's schedules, Wallets checks amounts, PINs and recipients the way a transfer does
+type Handler struct {
+	Repo    Repository
+	Wallets *wallet.Handler
+}
+
+func NewHandler(repo Repository, wallets *wallet.Handler) *Handler {
+	return &Handler{Repo: repo, Wallets: wallets}
+}
+
+type CreateScheduleRequest struct {
+	WalletNumber string `json:"wallet_number"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Description  string `json:"description"`
+	// Frequency is ONCE, DAILY, WEEKLY or MONTHLY, a monthly schedule started on the 31st runs on the last
+	// day of shorter months
+	Frequency Frequency `json:"frequency"`
+	// StartAt is the first run, it defaults to now for a recurring schedule and is required for a single one
+	StartAt *time.Time `json:"start_at"`
+	EndAt   *time.Time `json:"end_at"`
+	Pin     string     `json:"pin"`
+}
+
+// CreateSchedule sets up a transfer that runs on a date or repeats, the PIN given here authorizes every run
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CreateScheduleRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := wallet.ResolveCurrency(w, req.Currency)
+	if !ok || !h.Wallets.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	now := time.Now()
+	switch req.Frequency {
+	case FrequencyOnce:
+		if req.StartAt == nil {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "start_at is required for a one-off transfer", nil)
+			return
+		}
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+		if req.StartAt == nil {
+			req.StartAt = &now
+		}
+	default:
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "frequency must be ONCE, DAILY, WEEKLY or MONTHLY", nil)
+		return
+	}
+
+	// a minute of grace lets a client send the current time as start_at
+	if req.StartAt.Before(now.Add(-time.Minute)) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "start_at can't be in the past", nil)
+		return
+	}
+	if req.EndAt != nil && req.EndAt.Before(*req.StartAt) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "end_at can't be before start_at", nil)
+		return
+	}
+
+	sender, ok := h.Wallets.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	if !h.Wallets.VerifyPin(w, sender, req.Pin) {
+		return
+	}
+
+	if _, ok := h.Wallets.TransferRecipient(w, sender, req.WalletNumber); !ok {
+		return
+	}
+
+	startAt := *req.StartAt
+	schedule := Schedule{
+		UserID:                usr.ID,
+		WalletID:              sender.ID,
+		RecipientWalletNumber: req.WalletNumber,
+		Amount:                req.Amount,
+		Currency:              sender.Currency,
+		Description:           req.Description,
+		Frequency:             req.Frequency,
+		StartAt:               startAt,
+		EndAt:                 req.EndAt,
+		NextRunAt:             &startAt,
+		Status:                StatusActive,
+		AuthorizedAt:          now,
+	}
+
+	if err := h.Repo.Create(&schedule); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create schedule", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Schedule created", schedule)
+}
+
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	schedules, err := h.Repo.List(usr.ID.String(), Status(r.URL.Query().Get("status")), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch schedules", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Schedules", map[string]interface{}{
+		"schedules": schedules,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	schedule, ok := h.ownSchedule(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Schedule", schedule)
+}
+
+type UpdateScheduleRequest struct {
+	Amount      *int64     `json:"amount"`
+	Description *string    `json:"description"`
+	EndAt       *time.Time `json:"end_at"`
+	// Paused stops runs without cancelling, a run missed while paused is made as soon as it is resumed
+	Paused *bool  `json:"paused"`
+	Pin    string `json:"pin"`
+}
+
+// UpdateSchedule changes an open schedule, every change is authorized again with the PIN
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req UpdateScheduleRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	schedule, ok := h.ownSchedule(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if schedule.Closed() {
+		utils.BuildErrorResponse(w, http.StatusConflict, "Schedule is "+string(schedule.Status), nil)
+		return
+	}
+
+	if req.Amount != nil {
+		if !h.Wallets.CheckAmount(w, *req.Amount, schedule.Currency) {
+			return
+		}
+		schedule.Amount = *req.Amount
+	}
+	if req.Description != nil {
+		schedule.Description = *req.Description
+	}
+	if req.EndAt != nil {
+		if req.EndAt.Before(schedule.StartAt) {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "end_at can't be before start_at", nil)
+			return
+		}
+		schedule.EndAt = req.EndAt
+	}
+	if req.Paused != nil {
+		schedule.Status = StatusActive
+		if *req.Paused {
+			schedule.Status = StatusPaused
+		}
+	}
+
+	if !h.verifyOwner(w, schedule, req.Pin) {
+		return
+	}
+	schedule.AuthorizedAt = time.Now()
+
+	if err := h.Repo.Update(schedule); err != nil {
+		h.scheduleError(w, err, schedule)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Schedule updated", schedule)
+}
+
+type CancelScheduleRequest struct {
+	Pin string `json:"pin"`
+}
+
+// CancelSchedule stops a schedule for good, transfers it already made stay made. Like any other change it
+// needs the PIN.
+func (h *Handler) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CancelScheduleRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	schedule, ok := h.ownSchedule(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	if !h.verifyOwner(w, schedule, req.Pin) {
+		return
+	}
+
+	if err := h.Repo.Cancel(schedule.ID.String()); err != nil {
+		h.scheduleError(w, err, schedule)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Schedule cancelled", nil)
+}
+
+// ownSchedule loads one of the user's schedules, anyone else's schedule reads as not found
+func (h *Handler) ownSchedule(w http.ResponseWriter, usr user.User, scheduleID string) (*Schedule, bool) {
+	schedule, err := h.Repo.Get(scheduleID)
+	if err != nil || schedule.UserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Schedule not found", nil)
+		return nil, false
+	}
+	return schedule, true
+}
+
+// verifyOwner checks pin against the wallet the schedule pays from
+func (h *Handler) verifyOwner(w http.ResponseWriter, schedule *Schedule, pin string) bool {
+	sender, err := h.Wallets.Repo.GetWalletByID(schedule.WalletID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return false
+	}
+	return h.Wallets.VerifyPin(w, sender, pin)
+}
+
+func (h *Handler) scheduleError(w http.ResponseWriter, err error, schedule *Schedule) {
+	if errors.Is(err, ErrClosed) {
+		utils.BuildErrorResponse(w, http.StatusConflict, "Schedule is completed or cancelled", nil)
+		return
+	}
+	logger.Error("Schedule operation failed", logger.Fields{"error": err.Error(), "schedule_id": schedule.ID.String()})
+	utils.BuildErrorResponse(w, http.StatusInternalServerError, "Schedule operation failed", nil)
+}
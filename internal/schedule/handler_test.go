@@ -0,0 +1,164 @@
+package schedule
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository
+type memoryRepo struct {
+	mu        sync.Mutex
+	schedules map[uuid.UUID]*Schedule
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{schedules: make(map[uuid.UUID]*Schedule)}
+}
+
+func (m *memoryRepo) Create(schedule *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	schedule.ID = uuid.New()
+	cp := *schedule
+	m.schedules[schedule.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) Get(scheduleID string) (*Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, ok := m.schedules[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *schedule
+	return &cp, nil
+}
+
+func (m *memoryRepo) List(userID string, status Status, limit, offset int) ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Schedule
+	for _, s := range m.schedules {
+		if s.UserID.String() == userID && (status == "" || s.Status == status) {
+			list = append(list, *s)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryRepo) Update(schedule *Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.schedules[schedule.ID]
+	if stored.Closed() {
+		return ErrClosed
+	}
+	stored.Amount, stored.Description, stored.EndAt, stored.Status, stored.AuthorizedAt = schedule.Amount, schedule.Description, schedule.EndAt, schedule.Status, schedule.AuthorizedAt
+	return nil
+}
+
+func (m *memoryRepo) Cancel(scheduleID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.schedules[uuid.MustParse(scheduleID)]
+	if stored.Closed() {
+		return ErrClosed
+	}
+	stored.Status = StatusCancelled
+	stored.NextRunAt = nil
+	return nil
+}
+
+func (m *memoryRepo) GetDue(now time.Time, limit int) ([]Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Schedule
+	for _, s := range m.schedules {
+		if s.Status == StatusActive && !s.NextRunAt.After(now) && len(due) < limit {
+			due = append(due, *s)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryRepo) RecordRun(schedule *Schedule, dueAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.schedules[schedule.ID]
+	if stored.Status != StatusActive || !stored.NextRunAt.Equal(dueAt) {
+		return false, nil
+	}
+	cp := *schedule
+	m.schedules[schedule.ID] = &cp
+	return true, nil
+}
+
+func TestSchedules(t *testing.T) {
+	env := wallettest.NewEnv(t, 100000)
+	repo := newMemoryRepo()
+	h := NewHandler(repo, env.Handler)
+	landlord := env.AddWallet(t, "4444444444", "NGN")
+
+	start := time.Now().Add(time.Hour)
+	create := CreateScheduleRequest{WalletNumber: landlord.WalletNumber, Amount: 50000, Description: "Rent", Frequency: FrequencyMonthly, StartAt: &start, Pin: "0000"}
+	rr := env.Do(h.CreateSchedule, "POST", "/wallet/schedules", create, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	create.Frequency = "YEARLY"
+	create.Pin = wallettest.Pin
+	rr = env.Do(h.CreateSchedule, "POST", "/wallet/schedules", create, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	past := time.Now().Add(-time.Hour)
+	rr = env.Do(h.CreateSchedule, "POST", "/wallet/schedules", CreateScheduleRequest{WalletNumber: landlord.WalletNumber, Amount: 50000, Frequency: FrequencyOnce, StartAt: &past, Pin: wallettest.Pin}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	create.Frequency = FrequencyMonthly
+	rr = env.Do(h.CreateSchedule, "POST", "/wallet/schedules", create, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	data := wallettest.DecodeData(t, rr)
+	id := data["id"].(string)
+	assert.Equal(t, "ACTIVE", data["status"])
+	assert.Equal(t, "NGN", data["currency"])
+
+	// pausing is a change like any other, so it needs the PIN
+	paused := true
+	vars := map[string]string{"id": id}
+	rr = env.Do(h.UpdateSchedule, "PUT", "/wallet/schedules/"+id, UpdateScheduleRequest{Paused: &paused}, vars)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	amount := int64(60000)
+	rr = env.Do(h.UpdateSchedule, "PUT", "/wallet/schedules/"+id, UpdateScheduleRequest{Amount: &amount, Paused: &paused, Pin: wallettest.Pin}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	stored := repo.schedules[uuid.MustParse(id)]
+	assert.Equal(t, StatusPaused, stored.Status)
+	assert.Equal(t, int64(60000), stored.Amount)
+
+	// so is cancelling
+	rr = env.Do(h.CancelSchedule, "DELETE", "/wallet/schedules/"+id, CancelScheduleRequest{}, vars)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, StatusPaused, repo.schedules[uuid.MustParse(id)].Status)
+
+	rr = env.Do(h.CancelSchedule, "DELETE", "/wallet/schedules/"+id, CancelScheduleRequest{Pin: wallettest.Pin}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = env.Do(h.CancelSchedule, "DELETE", "/wallet/schedules/"+id, CancelScheduleRequest{Pin: wallettest.Pin}, vars)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// someone else's schedule reads as not found
+	env.User.ID = landlord.UserID
+	rr = env.Do(h.GetSchedule, "GET", "/wallet/schedules/"+id, nil, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
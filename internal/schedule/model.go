@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Frequency string
+
+const (
+	FrequencyOnce    Frequency = "ONCE"
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+)
+
+type Status string
+
+const (
+	StatusActive    Status = "ACTIVE"
+	StatusPaused    Status = "PAUSED"
+	StatusCompleted Status = "COMPLETED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+// Schedule is a transfer the owner authorized with their PIN up front, made by the Runner at NextRunAt.
+// Occurrence counts the runs since StartAt so each one gets its own transfer reference, Attempts counts
+// retries of the current occurrence after it met an insufficient balance.
+type Schedule struct {
+	ID                    uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID                uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	WalletID              uuid.UUID  `gorm:"type:uuid;not null" json:"wallet_id"`
+	RecipientWalletNumber string     `gorm:"not null" json:"recipient_wallet_number"`
+	Amount                int64      `gorm:"not null" json:"amount"`
+	Currency              string     `gorm:"not null" json:"currency"`
+	Description           string     `json:"description"`
+	Frequency             Frequency  `gorm:"not null" json:"frequency"`
+	StartAt               time.Time  `gorm:"not null" json:"start_at"`
+	EndAt                 *time.Time `json:"end_at,omitempty"`
+	NextRunAt             *time.Time `json:"next_run_at,omitempty"`
+	Occurrence            int        `gorm:"not null;default:0" json:"-"`
+	Attempts              int        `gorm:"not null;default:0" json:"attempts"`
+	Status                Status     `gorm:"not null" json:"status"`
+	RunCount              int        `gorm:"not null;default:0" json:"run_count"`
+	FailureCount          int        `gorm:"not null;default:0" json:"failure_count"`
+	LastRunAt             *time.Time `json:"last_run_at,omitempty"`
+	LastReference         string     `json:"last_reference,omitempty"`
+	LastError             string     `json:"last_error,omitempty"`
+	AuthorizedAt          time.Time  `gorm:"not null" json:"authorized_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// Closed reports whether the schedule will never run again
+func (s *Schedule) Closed() bool {
+	return s.Status == StatusCompleted || s.Status == StatusCancelled
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClosed = errors.New("schedule is completed or cancelled")
+	ErrStale  = errors.New("the PIN has changed since the schedule was authorized")
+)
+
+type Repository interface {
+	Create(schedule *Schedule) error
+	Get(scheduleID string) (*Schedule, error)
+	List(userID string, status Status, limit, offset int) ([]Schedule, error)
+	Update(schedule *Schedule) error
+	Cancel(scheduleID string) error
+	GetDue(now time.Time, limit int) ([]Schedule, error)
+	RecordRun(schedule *Schedule, dueAt time.Time) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(schedule *Schedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *repository) Get(scheduleID string) (*Schedule, error) {
+	var schedule Schedule
+	if err := r.db.Where("id = ?", scheduleID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *repository) List(userID string, status Status, limit, offset int) ([]Schedule, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var schedules []Schedule
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&schedules).Error
+	return schedules, err
+}
+
+var openStatuses = []Status{StatusActive, StatusPaused}
+
+// Update saves what the owner can change, the run state belongs to the Runner
+func (r *repository) Update(schedule *Schedule) error {
+	res := r.db.Model(&Schedule{}).Where("id = ? AND status IN ?", schedule.ID, openStatuses).Updates(map[string]interface{}{
+		"amount":        schedule.Amount,
+		"description":   schedule.Description,
+		"end_at":        schedule.EndAt,
+		"status":        schedule.Status,
+		"authorized_at": schedule.AuthorizedAt,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (r *repository) Cancel(scheduleID string) error {
+	res := r.db.Model(&Schedule{}).Where("id = ? AND status IN ?", scheduleID, openStatuses).Updates(map[string]interface{}{
+		"status":      StatusCancelled,
+		"next_run_at": nil,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (r *repository) GetDue(now time.Time, limit int) ([]Schedule, error) {
+	var schedules []Schedule
+	err := r.db.Where("status = ? AND next_run_at <= ?", StatusActive, now).
+		Order("next_run_at asc").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// RecordRun saves the outcome of the run that was due at dueAt. It reports false when the schedule was
+// changed or run by someone else since it was read, and then saves nothing.
+func (r *repository) RecordRun(schedule *Schedule, dueAt time.Time) (bool, error) {
+	res := r.db.Model(&Schedule{}).Where("id = ? AND status = ? AND next_run_at = ?", schedule.ID, StatusActive, dueAt).Updates(map[string]interface{}{
+		"next_run_at":    schedule.NextRunAt,
+		"occurrence":     schedule.Occurrence,
+		"attempts":       schedule.Attempts,
+		"status":         schedule.Status,
+		"run_count":      schedule.RunCount,
+		"failure_count":  schedule.FailureCount,
+		"last_run_at":    schedule.LastRunAt,
+		"last_reference": schedule.LastReference,
+		"last_error":     schedule.LastError,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,282 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+)
+
+const runBatchSize = 100
+
+// Runner makes the transfers of due schedules through wallet.SendTransfer, the same path a user's transfer
+// takes, priced for the owner's tier when it runs. A run that meets an insufficient balance, fee included, is
+// retried ScheduleMaxRetries times, ScheduleRetryInterval apart, before that occurrence is given up on. The
+// owner is notified of every failed attempt. A schedule authorized before the owner's PIN last changed is
+// paused instead of run, until the owner authorizes it again with the new PIN.
+type Runner struct {
+	Config        config.Config
+	Repo          Repository
+	Wallets       wallet.Repository
+	Fees          fee.Repository
+	Users         user.Repository
+	Notifications notification.Repository
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type Result struct {
+	Due       int
+	Succeeded int
+	Retrying  int
+	Failed    int
+	Paused    int
+}
+
+func NewRunner(cfg config.Config, repo Repository, wallets wallet.Repository, fees fee.Repository, users user.Repository, notifications notification.Repository) *Runner {
+	return &Runner{Config: cfg, Repo: repo, Wallets: wallets, Fees: fees, Users: users, Notifications: notifications, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (s *Runner) Start() {
+	logger.Info("Starting schedule runner...", logger.Fields{"interval": s.Config.ScheduleInterval.String()})
+	go s.run()
+}
+
+// Stop waits for a sweep in progress to finish
+func (s *Runner) Stop(ctx context.Context) error {
+	close(s.stop)
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Runner) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.Config.ScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.RunDue(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("ScheduleRunner: Sweep failed", logger.Fields{"error": err.Error()})
+		} else if result.Due > 0 {
+			logger.Info("ScheduleRunner: Sweep finished", logger.Fields{
+				"due":       result.Due,
+				"succeeded": result.Succeeded,
+				"retrying":  result.Retrying,
+				"failed":    result.Failed,
+				"paused":    result.Paused,
+			})
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue makes one batch of the transfers due at now
+func (s *Runner) RunDue(ctx context.Context, now time.Time) (*Result, error) {
+	schedules, err := s.Repo.GetDue(now, runBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Due++
+		s.runSchedule(schedule, now, result)
+	}
+
+	return result, nil
+}
+
+func (s *Runner) runSchedule(schedule Schedule, now time.Time, result *Result) {
+	dueAt := *schedule.NextRunAt
+	reference := fmt.Sprintf("sch-%s-%d", schedule.ID, schedule.Occurrence)
+
+	err := s.transfer(&schedule, reference)
+	schedule.LastRunAt = &now
+	switch {
+	case err == nil:
+		result.Succeeded++
+		schedule.RunCount++
+		schedule.LastReference = reference
+		schedule.LastError = ""
+		advance(&schedule, now)
+	case err.Error() == "insufficient balance" && schedule.Attempts < s.Config.ScheduleMaxRetries:
+		result.Retrying++
+		schedule.Attempts++
+		retryAt := now.Add(s.Config.ScheduleRetryInterval)
+		schedule.NextRunAt = &retryAt
+		schedule.LastError = err.Error()
+	case errors.Is(err, ErrStale):
+		// NextRunAt stays put, so the run is made as soon as the owner resumes the schedule
+		result.Paused++
+		schedule.Status = StatusPaused
+		schedule.LastError = err.Error()
+	default:
+		result.Failed++
+		schedule.FailureCount++
+		schedule.LastError = err.Error()
+		advance(&schedule, now)
+	}
+
+	saved, saveErr := s.Repo.RecordRun(&schedule, dueAt)
+	if saveErr != nil {
+		logger.Error("ScheduleRunner: Failed to record run", logger.Fields{"error": saveErr.Error(), "schedule_id": schedule.ID.String(), "reference": reference})
+		return
+	}
+	// paused, cancelled or run by another instance since it was read, the transfer reference keeps a rerun
+	// of this occurrence from paying twice
+	if !saved {
+		logger.Info("ScheduleRunner: Schedule changed while running", logger.Fields{"schedule_id": schedule.ID.String()})
+		return
+	}
+
+	if errors.Is(err, ErrStale) {
+		logger.Warn("ScheduleRunner: Schedule paused, PIN changed since it was authorized", logger.Fields{"schedule_id": schedule.ID.String()})
+		s.notifyPaused(schedule)
+	} else if err != nil {
+		logger.Warn("ScheduleRunner: Scheduled transfer failed", logger.Fields{"error": err.Error(), "schedule_id": schedule.ID.String(), "attempts": schedule.Attempts})
+		s.notifyFailure(schedule, err)
+	}
+}
+
+func (s *Runner) transfer(schedule *Schedule, reference string) error {
+	// a run whose outcome was never recorded, e.g. the process stopped in between, has already paid
+	if _, err := s.Wallets.GetTransactionByReference(reference + "-debit"); err == nil {
+		return nil
+	}
+
+	sender, err := s.Wallets.GetWalletByID(schedule.WalletID.String())
+	if err != nil {
+		return fmt.Errorf("wallet not found: %w", err)
+	}
+	if sender.PinChangedAt != nil && sender.PinChangedAt.After(schedule.AuthorizedAt) {
+		return ErrStale
+	}
+	recipient, err := s.Wallets.GetWalletByNumber(schedule.RecipientWalletNumber)
+	if err != nil {
+		return fmt.Errorf("recipient wallet %s not found", schedule.RecipientWalletNumber)
+	}
+
+	charge, err := s.charge(schedule)
+	if err != nil {
+		return fmt.Errorf("could not work out the fee: %w", err)
+	}
+
+	description := schedule.Description
+	if description == "" {
+		description = "Scheduled transfer"
+	}
+	return wallet.SendTransfer(s.Wallets, sender, recipient, reference, schedule.Amount, charge, description)
+}
+
+// charge prices a run for the owner's tier as it is now, a schedule has no API key to match on
+func (s *Runner) charge(schedule *Schedule) (fee.Charge, error) {
+	owner, err := s.Users.FindByID(schedule.UserID.String())
+	if err != nil {
+		return fee.Charge{}, err
+	}
+	tier := owner.Tier
+	if tier == "" {
+		tier = fee.DefaultTier
+	}
+	return s.Fees.Charge(fee.CategoryTransfer, schedule.Currency, schedule.Amount, nil, tier)
+}
+
+func (s *Runner) notifyFailure(schedule Schedule, err error) {
+	message := fmt.Sprintf("Your scheduled transfer of %d %s to wallet %s failed: %s.", schedule.Amount, schedule.Currency, schedule.RecipientWalletNumber, err.Error())
+	switch {
+	case schedule.Attempts > 0:
+		message += fmt.Sprintf(" It will be tried again at %s.", schedule.NextRunAt.Format(time.RFC3339))
+	case schedule.Closed():
+		message += " The schedule has ended."
+	default:
+		message += fmt.Sprintf(" The next transfer is due at %s.", schedule.NextRunAt.Format(time.RFC3339))
+	}
+
+	entry := notification.Notification{
+		UserID:    schedule.UserID,
+		Kind:      notification.KindScheduledTransferFailed,
+		Title:     "Scheduled transfer failed",
+		Message:   message,
+		Reference: schedule.ID.String(),
+	}
+	if err := s.Notifications.Create(&entry); err != nil {
+		logger.Error("ScheduleRunner: Failed to notify owner", logger.Fields{"error": err.Error(), "schedule_id": schedule.ID.String()})
+	}
+}
+
+func (s *Runner) notifyPaused(schedule Schedule) {
+	entry := notification.Notification{
+		UserID:    schedule.UserID,
+		Kind:      notification.KindScheduledTransferPaused,
+		Title:     "Scheduled transfer paused",
+		Message:   fmt.Sprintf("Your scheduled transfer of %d %s to wallet %s was paused because your PIN changed after you set it up. Resume it with your new PIN to carry on.", schedule.Amount, schedule.Currency, schedule.RecipientWalletNumber),
+		Reference: schedule.ID.String(),
+	}
+	if err := s.Notifications.Create(&entry); err != nil {
+		logger.Error("ScheduleRunner: Failed to notify owner", logger.Fields{"error": err.Error(), "schedule_id": schedule.ID.String()})
+	}
+}
+
+// advance moves a schedule to its first occurrence after now, occurrences missed while it was paused or
+// retrying are skipped rather than paid all at once. A schedule with no occurrence left is completed.
+func advance(schedule *Schedule, now time.Time) {
+	schedule.Attempts = 0
+	if schedule.Frequency == FrequencyOnce {
+		schedule.Status = StatusCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+
+	next := occurrence(schedule.StartAt, schedule.Frequency, schedule.Occurrence)
+	for !next.After(now) {
+		schedule.Occurrence++
+		next = occurrence(schedule.StartAt, schedule.Frequency, schedule.Occurrence)
+	}
+
+	if schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		schedule.Status = StatusCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+	schedule.NextRunAt = &next
+}
+
+// occurrence is the nth run of a schedule started at start. Monthly runs keep start's day of the month, or
+// the last day of a month too short to have it.
+func occurrence(start time.Time, frequency Frequency, n int) time.Time {
+	switch frequency {
+	case FrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(start.Day(), lastDay)-1)
+	default:
+		return start
+	}
+}
@@ -0,0 +1,199 @@
+package schedule
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet/wallettest"
+	"gorm.io/gorm"
+)
+
+type memoryUsers struct {
+	user.Repository
+
+	users map[string]user.User
+}
+
+func (m *memoryUsers) FindByID(id string) (*user.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &u, nil
+}
+
+// newRunner runs repo's schedules out of env's wallets, priced for env's user
+func newRunner(env *wallettest.Env, repo Repository, notifications notification.Repository) *Runner {
+	users := &memoryUsers{users: map[string]user.User{env.User.ID.String(): env.User}}
+	return NewRunner(env.Handler.Config, repo, env.Repo, env.Fees, users, notifications)
+}
+
+func TestRunnerCharged(t *testing.T) {
+	env := wallettest.NewEnv(t, 30500)
+	env.Handler.Config.ScheduleMaxRetries = 1
+	env.Handler.Config.ScheduleRetryInterval = time.Hour
+	landlord := env.AddWallet(t, "4444444444", "NGN")
+	env.Fees.Add(fee.Schedule{Name: "Transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindFlat, Flat: 1000})
+	env.Fees.Add(fee.Schedule{Name: "Gold transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindFlat, Flat: 200, UserTier: "GOLD"})
+
+	repo := newMemoryRepo()
+	start := time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC)
+	schedule := &Schedule{
+		UserID:                env.User.ID,
+		WalletID:              env.Wallet.ID,
+		RecipientWalletNumber: landlord.WalletNumber,
+		Amount:                30000,
+		Currency:              "NGN",
+		Frequency:             FrequencyMonthly,
+		StartAt:               start,
+		NextRunAt:             &start,
+		Status:                StatusActive,
+	}
+	require.NoError(t, repo.Create(schedule))
+
+	// the balance covers the amount but not the fee, so the run is retried
+	runner := newRunner(env, repo, &wallettest.Notifications{})
+	result, err := runner.RunDue(context.Background(), start)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Retrying)
+	assert.Equal(t, int64(30500), env.Wallet.Balance)
+
+	// the owner's tier is looked up when the run is priced
+	env.User.Tier = "GOLD"
+	runner = newRunner(env, repo, &wallettest.Notifications{})
+	result, err = runner.RunDue(context.Background(), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Succeeded)
+	assert.Equal(t, int64(300), env.Wallet.Balance)
+	assert.Equal(t, int64(30000), landlord.Balance)
+
+	debit, err := env.Repo.GetTransactionByReference(repo.schedules[schedule.ID].LastReference + "-debit")
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), debit.Fee)
+}
+
+func TestRunner(t *testing.T) {
+	env := wallettest.NewEnv(t, 50000)
+	env.Handler.Config.ScheduleMaxRetries = 2
+	env.Handler.Config.ScheduleRetryInterval = time.Hour
+	landlord := env.AddWallet(t, "4444444444", "NGN")
+
+	repo := newMemoryRepo()
+	start := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	schedule := &Schedule{
+		UserID:                env.User.ID,
+		WalletID:              env.Wallet.ID,
+		RecipientWalletNumber: landlord.WalletNumber,
+		Amount:                30000,
+		Currency:              "NGN",
+		Frequency:             FrequencyMonthly,
+		StartAt:               start,
+		NextRunAt:             &start,
+		Status:                StatusActive,
+	}
+	require.NoError(t, repo.Create(schedule))
+
+	notifications := &wallettest.Notifications{}
+	runner := newRunner(env, repo, notifications)
+
+	result, err := runner.RunDue(context.Background(), start)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Due: 1, Succeeded: 1}, result)
+	stored := repo.schedules[schedule.ID]
+	assert.Equal(t, time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC), *stored.NextRunAt, "a month without the 31st runs on its last day")
+	assert.Equal(t, 1, stored.RunCount)
+	assert.Contains(t, env.Repo.Transactions(), stored.LastReference+"-debit")
+
+	// 20000 left cannot cover the second month, so it is retried before being given up on
+	feb := *stored.NextRunAt
+	result, err = runner.RunDue(context.Background(), feb)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Retrying)
+	stored = repo.schedules[schedule.ID]
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, feb.Add(time.Hour), *stored.NextRunAt)
+	sent := notifications.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, notification.KindScheduledTransferFailed, sent[0].Kind)
+	assert.Equal(t, env.User.ID, sent[0].UserID)
+
+	_, err = runner.RunDue(context.Background(), feb.Add(time.Hour))
+	require.NoError(t, err)
+	result, err = runner.RunDue(context.Background(), feb.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Failed)
+	stored = repo.schedules[schedule.ID]
+	assert.Equal(t, 0, stored.Attempts)
+	assert.Equal(t, 1, stored.FailureCount)
+	assert.Equal(t, time.Date(2026, time.March, 31, 9, 0, 0, 0, time.UTC), *stored.NextRunAt)
+	assert.Len(t, notifications.Sent(), 3)
+
+	// a run paid before its outcome was recorded is not paid again
+	env.Wallet.Balance = 100000
+	mar := *stored.NextRunAt
+	_, err = runner.RunDue(context.Background(), mar)
+	require.NoError(t, err)
+	repo.schedules[schedule.ID].NextRunAt = &mar
+	repo.schedules[schedule.ID].Occurrence--
+	_, err = runner.RunDue(context.Background(), mar)
+	require.NoError(t, err)
+	assert.Equal(t, int64(70000), env.Wallet.Balance)
+	assert.Equal(t, int64(60000), landlord.Balance)
+
+	// a one-off transfer completes after it runs
+	once := &Schedule{UserID: env.User.ID, WalletID: env.Wallet.ID, RecipientWalletNumber: landlord.WalletNumber, Amount: 10000, Currency: "NGN", Frequency: FrequencyOnce, StartAt: mar, NextRunAt: &mar, Status: StatusActive}
+	require.NoError(t, repo.Create(once))
+	_, err = runner.RunDue(context.Background(), mar.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, repo.schedules[once.ID].Status)
+	assert.Nil(t, repo.schedules[once.ID].NextRunAt)
+}
+
+func TestRunnerPausesStaleSchedule(t *testing.T) {
+	env := wallettest.NewEnv(t, 50000)
+	landlord := env.AddWallet(t, "4444444444", "NGN")
+	repo := newMemoryRepo()
+	h := NewHandler(repo, env.Handler)
+
+	start := time.Now().Add(time.Hour)
+	rr := env.Do(h.CreateSchedule, "POST", "/wallet/schedules", CreateScheduleRequest{WalletNumber: landlord.WalletNumber, Amount: 10000, Frequency: FrequencyMonthly, StartAt: &start, Pin: wallettest.Pin}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	id := wallettest.DecodeData(t, rr)["id"].(string)
+
+	// a PIN changed after the schedule was set up no longer vouches for it
+	rr = env.Do(env.Handler.ChangePin, "PUT", "/wallet/pin", wallet.ChangePinRequest{CurrentPin: wallettest.Pin, NewPin: "4321"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	notifications := &wallettest.Notifications{}
+	runner := newRunner(env, repo, notifications)
+	result, err := runner.RunDue(context.Background(), start)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Due: 1, Paused: 1}, result)
+	stored := repo.schedules[uuid.MustParse(id)]
+	assert.Equal(t, StatusPaused, stored.Status)
+	assert.True(t, start.Equal(*stored.NextRunAt))
+	assert.Equal(t, int64(50000), env.Wallet.Balance)
+	sent := notifications.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, notification.KindScheduledTransferPaused, sent[0].Kind)
+
+	// resuming with the new PIN authorizes it again and the missed run is made
+	resume := false
+	vars := map[string]string{"id": id}
+	rr = env.Do(h.UpdateSchedule, "PUT", "/wallet/schedules/"+id, UpdateScheduleRequest{Paused: &resume, Pin: "4321"}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	result, err = runner.RunDue(context.Background(), start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Due: 1, Succeeded: 1}, result)
+	assert.Equal(t, int64(40000), env.Wallet.Balance)
+}
@@ -6,6 +6,9 @@ type Repository interface {
 	FindByGoogleID(googleID string) (*User, error)
 	CreateUser(user *User) error
 	FindByID(id string) (*User, error)
+	FindByEmail(email string) (*User, error)
+	SetAdmin(id string, isAdmin bool) error
+	SetTier(id, tier string) error
 }
 
 type repository struct {
@@ -31,3 +34,24 @@ func (r *repository) FindByID(id string) (*User, error) {
 	err := r.db.Where("id = ?", id).First(&user).Error
 	return &user, err
 }
+
+func (r *repository) FindByEmail(email string) (*User, error) {
+	var user User
+	err := r.db.Where("email = ?", email).First(&user).Error
+	return &user, err
+}
+
+func (r *repository) SetAdmin(id string, isAdmin bool) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Update("is_admin", isAdmin).Error
+}
+
+func (r *repository) SetTier(id, tier string) error {
+	res := r.db.Model(&User{}).Where("id = ?", id).Update("tier", tier)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
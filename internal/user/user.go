@@ -7,10 +7,13 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `gorm:"uniqueIndex" json:"email"`
-	GoogleID  string    `gorm:"uniqueIndex" json:"google_id"`
+	ID       uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `gorm:"uniqueIndex" json:"email"`
+	GoogleID string    `gorm:"uniqueIndex" json:"google_id"`
+	IsAdmin  bool      `gorm:"not null;default:false" json:"is_admin"`
+	// Tier picks the fee schedules that apply to the user, admins move users between tiers
+	Tier      string    `gorm:"not null;default:STANDARD" json:"tier"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+const (
+	callbackSuccess = "success"
+	callbackFailed  = "failed"
+	callbackPending = "pending"
+)
+
+// DepositCallback is where Paystack sends the user after checkout. The webhook may not have
+// arrived yet, so the reference is verified here and settled through the same path the worker uses.
+func (h *Handler) DepositCallback(w http.ResponseWriter, r *http.Request) {
+	reference := r.URL.Query().Get("reference")
+	if reference == "" {
+		reference = r.URL.Query().Get("trxref")
+	}
+
+	if !strings.HasPrefix(reference, "dep-") {
+		h.finishCallback(w, r, reference, callbackFailed)
+		return
+	}
+
+	tx, err := h.Repo.GetTransactionByReference(reference)
+	if err != nil {
+		logger.Warn("DepositCallback: Transaction not found", logger.Fields{"reference": reference})
+		h.finishCallback(w, r, reference, callbackFailed)
+		return
+	}
+
+	switch tx.Status {
+	case TransactionSuccess:
+		h.finishCallback(w, r, reference, callbackSuccess)
+		return
+	case TransactionFailed:
+		h.finishCallback(w, r, reference, callbackFailed)
+		return
+	case TransactionReview:
+		h.finishCallback(w, r, reference, callbackPending)
+		return
+	}
+
+	result, err := h.Paystack.VerifyTransaction(r.Context(), reference)
+	if err != nil {
+		logger.Error("DepositCallback: Failed to verify transaction", logger.Fields{"error": err.Error(), "reference": reference})
+		h.finishCallback(w, r, reference, callbackPending)
+		return
+	}
+
+	switch result.Status {
+	case "success":
+		status, err := settleDeposit(r.Context(), h.Repo, h.Paystack, tx, reportFromVerify(result), false)
+		if err != nil {
+			logger.Error("DepositCallback: Failed to settle deposit", logger.Fields{"error": err.Error(), "reference": reference})
+			h.finishCallback(w, r, reference, callbackPending)
+			return
+		}
+		if status != TransactionSuccess {
+			h.finishCallback(w, r, reference, callbackPending)
+			return
+		}
+		h.finishCallback(w, r, reference, callbackSuccess)
+
+	case "failed", "reversed":
+		if err := h.Repo.ProcessFailedTransaction(reference); err != nil {
+			logger.Error("DepositCallback: Failed to mark deposit failed", logger.Fields{"error": err.Error(), "reference": reference})
+		}
+		h.finishCallback(w, r, reference, callbackFailed)
+
+	default:
+		// abandoned or still processing, the webhook settles it if the payment completes
+		h.finishCallback(w, r, reference, callbackPending)
+	}
+}
+
+// finishCallback redirects to the configured frontend page, or answers with JSON when none is set
+func (h *Handler) finishCallback(w http.ResponseWriter, r *http.Request, reference, status string) {
+	target := h.Config.DepositFailureURL
+	if status == callbackSuccess {
+		target = h.Config.DepositSuccessURL
+	}
+
+	if target == "" {
+		data := map[string]string{"reference": reference, "status": status}
+		if status == callbackFailed {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Deposit failed", data)
+		} else {
+			utils.BuildSuccessResponse(w, http.StatusOK, "Deposit "+status, data)
+		}
+		return
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		logger.Error("DepositCallback: Invalid redirect URL", logger.Fields{"error": err.Error(), "url": target})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Invalid redirect URL", nil)
+		return
+	}
+
+	query := u.Query()
+	query.Set("reference", reference)
+	query.Set("status", status)
+	u.RawQuery = query.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
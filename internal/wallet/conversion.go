@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+type QuoteRequest struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+	Amount       int64  `json:"amount"` // debited from the source wallet, in its minor unit
+}
+
+// CreateQuote prices a conversion between two of the user's wallets and holds the rate for FXQuoteTTL
+func (h *Handler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req QuoteRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.ToCurrency == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "to_currency is required", nil)
+		return
+	}
+
+	from, ok := ResolveCurrency(w, req.FromCurrency)
+	if !ok {
+		return
+	}
+	to, ok := ResolveCurrency(w, req.ToCurrency)
+	if !ok {
+		return
+	}
+
+	if from == to {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Cannot convert a currency into itself", nil)
+		return
+	}
+
+	if !h.CheckAmount(w, req.Amount, from) {
+		return
+	}
+
+	fromWallet, ok := h.WalletFor(w, usr, from)
+	if !ok {
+		return
+	}
+	toWallet, ok := h.WalletFor(w, usr, to)
+	if !ok {
+		return
+	}
+
+	marketRate, err := h.Rates.Rate(r.Context(), from, to)
+	if err != nil {
+		logger.Error("Failed to fetch exchange rate", logger.Fields{"error": err.Error(), "from": from, "to": to})
+		utils.BuildErrorResponse(w, http.StatusServiceUnavailable, "Exchange rate unavailable", nil)
+		return
+	}
+
+	fee, rate, converted := priceQuote(req.Amount, marketRate, h.Config.FXSpreadBps, h.Config.FXFeeBps)
+	if converted <= 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Amount is too small to convert", nil)
+		return
+	}
+
+	quote := Quote{
+		UserID:          usr.ID,
+		FromWalletID:    fromWallet.ID,
+		ToWalletID:      toWallet.ID,
+		FromCurrency:    from,
+		ToCurrency:      to,
+		Amount:          req.Amount,
+		Fee:             fee,
+		ConvertedAmount: converted,
+		MarketRate:      marketRate,
+		Rate:            rate,
+		SpreadBps:       h.Config.FXSpreadBps,
+		ExpiresAt:       time.Now().Add(h.Config.FXQuoteTTL),
+	}
+
+	if err := h.Repo.CreateQuote(&quote); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to create quote", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Quote created", quote)
+}
+
+type ConvertRequest struct {
+	QuoteID string `json:"quote_id"`
+	Pin     string `json:"pin"`
+}
+
+// Convert executes a quote while it is still valid, each quote converts at most once
+func (h *Handler) Convert(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req ConvertRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, err := uuid.Parse(req.QuoteID); err != nil {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid quote_id", nil)
+		return
+	}
+
+	quote, err := h.Repo.GetQuote(req.QuoteID, usr.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Quote not found", nil)
+		return
+	}
+
+	if quote.ExecutedAt != nil {
+		utils.BuildErrorResponse(w, http.StatusConflict, "Quote has already been executed", nil)
+		return
+	}
+
+	fromWallet, err := h.Repo.GetWalletByID(quote.FromWalletID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return
+	}
+
+	if !h.VerifyPin(w, fromWallet, req.Pin) {
+		return
+	}
+
+	executed, err := h.Repo.ExecuteConversion(req.QuoteID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrQuoteExpired):
+			utils.BuildErrorResponse(w, http.StatusGone, "Quote has expired, request a new one", nil)
+		case errors.Is(err, ErrQuoteExecuted):
+			utils.BuildErrorResponse(w, http.StatusConflict, "Quote has already been executed", nil)
+		case err.Error() == "insufficient balance":
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
+		default:
+			logger.Error("Conversion failed", logger.Fields{"error": err.Error(), "quote_id": req.QuoteID})
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Conversion failed", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Conversion completed", executed)
+}
+
+// priceQuote keeps feeBps of amount as the fee and converts the rest at the market rate less spreadBps,
+// the converted amount is rounded down so the wallet never pays out more than the rate allows
+func priceQuote(amount int64, marketRate float64, spreadBps, feeBps int64) (fee int64, rate float64, converted int64) {
+	fee = amount * feeBps / 10000
+	rate = marketRate * float64(10000-spreadBps) / 10000
+	converted = int64(math.Floor(float64(amount-fee) * rate))
+	return fee, rate, converted
+}
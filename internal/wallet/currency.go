@@ -0,0 +1,59 @@
+package wallet
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// requestCurrency normalises the currency a request names, an empty one means the default currency
+func requestCurrency(currency string) (string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return config.DefaultCurrency, true
+	}
+	return currency, config.IsSupportedCurrency(currency)
+}
+
+// ResolveCurrency is requestCurrency that writes the error response itself
+func ResolveCurrency(w http.ResponseWriter, currency string) (string, bool) {
+	currency, ok := requestCurrency(currency)
+	if !ok {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Unsupported currency", map[string]interface{}{
+			"supported": config.SupportedCurrencies,
+		})
+	}
+	return currency, ok
+}
+
+// CheckAmount refuses amounts below the minimum for currency, amounts are in the currency's minor unit
+func (h *Handler) CheckAmount(w http.ResponseWriter, amount int64, currency string) bool {
+	if min := h.Config.MinTransactionAmount(currency); amount < min {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid amount, can't be less than %d %s minor units", min, currency), nil)
+		return false
+	}
+	return true
+}
+
+// WalletFor loads the user's wallet in currency and writes the error response itself when it cannot
+func (h *Handler) WalletFor(w http.ResponseWriter, usr user.User, currency string) (*Wallet, bool) {
+	wallet, err := h.Repo.GetWalletByUserID(usr.ID.String(), currency)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, currency+" wallet not found", nil)
+		return nil, false
+	}
+	return wallet, true
+}
+
+// QueryWallet loads the wallet named by the ?currency= query parameter
+func (h *Handler) QueryWallet(w http.ResponseWriter, r *http.Request, usr user.User) (*Wallet, bool) {
+	currency, ok := ResolveCurrency(w, r.URL.Query().Get("currency"))
+	if !ok {
+		return nil, false
+	}
+	return h.WalletFor(w, usr, currency)
+}
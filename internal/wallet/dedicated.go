@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// dedicatedAccountChannel is the charge channel paystack reports for transfers into a dedicated account
+const dedicatedAccountChannel = "dedicated_nuban"
+
+// RequestDedicatedAccount provisions a Paystack dedicated virtual account for the wallet. Paystack
+// can assign the account number later, in which case it arrives on dedicatedaccount.assign.success.
+func (h *Handler) RequestDedicatedAccount(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
+		return
+	}
+
+	switch wallet.DedicatedAccount.Status {
+	case DedicatedAccountActive:
+		utils.BuildSuccessResponse(w, http.StatusOK, "Dedicated account already assigned", wallet.DedicatedAccount)
+		return
+	case DedicatedAccountPending:
+		utils.BuildSuccessResponse(w, http.StatusAccepted, "Dedicated account assignment in progress", wallet.DedicatedAccount)
+		return
+	}
+
+	if wallet.Currency != "NGN" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Dedicated accounts are only available for NGN wallets", nil)
+		return
+	}
+
+	if wallet.PaystackCustomerCode == "" {
+		firstName, lastName, _ := strings.Cut(strings.TrimSpace(usr.Name), " ")
+		customer, err := h.Paystack.CreateCustomer(r.Context(), paystack.CustomerRequest{
+			Email:     usr.Email,
+			FirstName: firstName,
+			LastName:  strings.TrimSpace(lastName),
+		})
+		if err != nil {
+			logger.Error("DedicatedAccount: Failed to create Paystack customer", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+			utils.BuildErrorResponse(w, http.StatusBadGateway, "Paystack error", nil)
+			return
+		}
+
+		if err := h.Repo.SetPaystackCustomer(wallet.ID.String(), customer.CustomerCode); err != nil {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to save Paystack customer", nil)
+			return
+		}
+		wallet.PaystackCustomerCode = customer.CustomerCode
+	}
+
+	remote, err := h.Paystack.CreateDedicatedAccount(r.Context(), paystack.DedicatedAccountRequest{
+		Customer:      wallet.PaystackCustomerCode,
+		PreferredBank: h.Config.DedicatedAccountBank,
+	})
+	if err != nil {
+		logger.Error("DedicatedAccount: Failed to create dedicated account", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusBadGateway, "Paystack error", nil)
+		return
+	}
+
+	account := DedicatedAccount{Status: DedicatedAccountPending}
+	if remote.Assigned && remote.AccountNumber != "" {
+		account = DedicatedAccount{
+			Status:        DedicatedAccountActive,
+			AccountNumber: remote.AccountNumber,
+			AccountName:   remote.AccountName,
+			BankName:      remote.Bank.Name,
+			BankSlug:      remote.Bank.Slug,
+			PaystackID:    remote.ID,
+		}
+	}
+
+	if err := h.Repo.UpdateDedicatedAccount(wallet.ID.String(), account); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to save dedicated account", nil)
+		return
+	}
+
+	if account.Status == DedicatedAccountPending {
+		utils.BuildSuccessResponse(w, http.StatusAccepted, "Dedicated account assignment in progress", account)
+		return
+	}
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Dedicated account assigned", account)
+}
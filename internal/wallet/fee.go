@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+type FeeQuoteRequest struct {
+	Category fee.Category `json:"category"`
+	Amount   int64        `json:"amount"`
+	Currency string       `json:"currency"`
+}
+
+// FeeQuote is what a transaction would cost, Debited leaves the paying side and Credited reaches the other.
+// Transfers and withdrawals pay the fee on top of Amount, deposits have it taken out of Amount.
+type FeeQuote struct {
+	Category   fee.Category `json:"category"`
+	Currency   string       `json:"currency"`
+	Amount     int64        `json:"amount"`
+	Fee        int64        `json:"fee"`
+	ScheduleID *uuid.UUID   `json:"fee_schedule_id,omitempty"`
+	Debited    int64        `json:"debited"`
+	Credited   int64        `json:"credited"`
+}
+
+// QuoteFee prices a transaction for the caller without making it, the fee is worked out the same way the
+// transaction would work it out
+func (h *Handler) QuoteFee(w http.ResponseWriter, r *http.Request) {
+	var req FeeQuoteRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Category != fee.CategoryTransfer && req.Category != fee.CategoryWithdrawal && req.Category != fee.CategoryDeposit {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "category must be TRANSFER, WITHDRAWAL or DEPOSIT", nil)
+		return
+	}
+
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok || !h.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	charge, ok := h.Charge(w, r, req.Category, currency, req.Amount)
+	if !ok {
+		return
+	}
+
+	quote := FeeQuote{
+		Category:   req.Category,
+		Currency:   currency,
+		Amount:     req.Amount,
+		Fee:        charge.Amount,
+		ScheduleID: charge.ScheduleID,
+		Debited:    req.Amount + charge.Amount,
+		Credited:   req.Amount,
+	}
+	if req.Category == fee.CategoryDeposit {
+		quote.Debited, quote.Credited = req.Amount, req.Amount-charge.Amount
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Fee quote", quote)
+}
+
+// Charge prices a transaction for the caller, matching on the API key the request came with and the user's
+// tier, and writes the error response itself when it cannot
+func (h *Handler) Charge(w http.ResponseWriter, r *http.Request, category fee.Category, currency string, amount int64) (fee.Charge, bool) {
+	apiKeyID, tier := PricedFor(r)
+
+	charge, err := h.Fees.Charge(category, currency, amount, apiKeyID, tier)
+	if err != nil {
+		logger.Error("Failed to price transaction", logger.Fields{"error": err.Error(), "category": string(category), "currency": currency})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to work out the fee", nil)
+		return fee.Charge{}, false
+	}
+	return charge, true
+}
+
+// PricedFor is what a request's fees are matched on, the API key it came with if any and the user's tier
+func PricedFor(r *http.Request) (*uuid.UUID, string) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var apiKeyID *uuid.UUID
+	if id, ok := r.Context().Value(utils.APIKeyIDKey).(uuid.UUID); ok {
+		apiKeyID = &id
+	}
+	tier := usr.Tier
+	if tier == "" {
+		tier = fee.DefaultTier
+	}
+	return apiKeyID, tier
+}
@@ -1,10 +1,9 @@
 package wallet
 
 import (
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -13,27 +12,43 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/beneficiary"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
 	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
 	"github.com/zjoart/go-paystack-wallet/pkg/config"
 	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/fx"
 	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
 	"github.com/zjoart/go-paystack-wallet/pkg/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Handler struct {
-	Config      config.Config
-	Repo        Repository
-	RedisClient *events.RedisClient
+	Config        config.Config
+	Repo          Repository
+	Beneficiaries beneficiary.Repository
+	Audit         audit.Repository
+	Deliveries    webhook.Repository
+	Notifications notification.Repository
+	Fees          fee.Repository
+	RedisClient   *events.RedisClient
+	Paystack      paystack.Client
+	Rates         fx.RateProvider
 }
 
-func NewHandler(cfg config.Config, repo Repository, redisClient *events.RedisClient) *Handler {
-	return &Handler{Config: cfg, Repo: repo, RedisClient: redisClient}
+func NewHandler(cfg config.Config, repo Repository, beneficiaries beneficiary.Repository, auditRepo audit.Repository, deliveries webhook.Repository, notifications notification.Repository, fees fee.Repository, redisClient *events.RedisClient, paystackClient paystack.Client, rates fx.RateProvider) *Handler {
+	return &Handler{Config: cfg, Repo: repo, Beneficiaries: beneficiaries, Audit: auditRepo, Deliveries: deliveries, Notifications: notifications, Fees: fees, RedisClient: redisClient, Paystack: paystackClient, Rates: rates}
 }
 
 type CreateWalletRequest struct {
-	Pin string `json:"pin"`
+	Pin      string `json:"pin"`
+	Currency string `json:"currency"`
 }
 
 func (h *Handler) CreateWallet(w http.ResponseWriter, r *http.Request) {
@@ -49,30 +64,50 @@ func (h *Handler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.Pin) != 4 {
+	if !validPin(req.Pin) {
 		utils.BuildErrorResponse(w, http.StatusBadRequest, "PIN must be 4 digits", nil)
 		return
 	}
 
-	// check if wallet exists
-	existingWallet, _ := h.Repo.GetWalletByUserID(usr.ID.String())
-	if existingWallet != nil {
-		utils.BuildErrorResponse(w, http.StatusConflict, "User already has a wallet", nil)
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok {
 		return
 	}
 
-	hashedPin, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+	existing, err := h.Repo.GetWalletsByUserID(usr.ID.String())
 	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to secure PIN", nil)
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch wallets", nil)
 		return
 	}
+	for _, e := range existing {
+		if e.Currency == currency {
+			utils.BuildErrorResponse(w, http.StatusConflict, "User already has a "+currency+" wallet", nil)
+			return
+		}
+	}
+
+	// the PIN is shared by all of a user's wallets, so a further wallet must be opened with it
+	var pinHash string
+	if len(existing) > 0 {
+		if !h.VerifyPin(w, &existing[0], req.Pin) {
+			return
+		}
+		pinHash = existing[0].PinHash
+	} else {
+		hashedPin, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to secure PIN", nil)
+			return
+		}
+		pinHash = string(hashedPin)
+	}
 
 	wallet := Wallet{
 		UserID:       usr.ID,
 		WalletNumber: generateWalletNumber(),
-		PinHash:      string(hashedPin),
+		PinHash:      pinHash,
 		Balance:      0,
-		Currency:     "NGN",
+		Currency:     currency,
 	}
 
 	if err := h.Repo.CreateWallet(&wallet); err != nil {
@@ -88,7 +123,8 @@ func (h *Handler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 }
 
 type DepositRequest struct {
-	Amount int64 `json:"amount"` // in Kobo
+	Amount   int64  `json:"amount"` // in the currency's minor unit, e.g. Kobo
+	Currency string `json:"currency"`
 }
 
 func (h *Handler) WalletDeposit(w http.ResponseWriter, r *http.Request) {
@@ -101,82 +137,60 @@ func (h *Handler) WalletDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Amount < h.Config.MinTransactionAmount {
-		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount, can't be less than 100 Naira (10000 Kobo)", nil)
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok || !h.CheckAmount(w, req.Amount, currency) {
 		return
 	}
 
-	wallet, err := h.Repo.GetWalletByUserID(usr.ID.String())
-	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+	wallet, ok := h.WalletFor(w, usr, currency)
+	if !ok {
 		return
 	}
 
-	paystackUrl := "https://api.paystack.co/transaction/initialize"
-	reference := fmt.Sprintf("dep-%s-%d", usr.ID.String(), time.Now().UnixNano())
-
-	payload := map[string]interface{}{
-		"email":        usr.Email,
-		"amount":       req.Amount,
-		"reference":    reference,
-		"currency":     "NGN",
-		"channels":     h.Config.PaystackChannels,
-		"callback_url": fmt.Sprintf("%s/wallet/deposit/callback", h.Config.Host),
-		"metadata":     map[string]interface{}{"wallet_id": wallet.ID.String()},
-	}
-
-	jsonPayload, _ := json.Marshal(payload)
-
-	paystackReq, _ := http.NewRequest("POST", paystackUrl, strings.NewReader(string(jsonPayload)))
-	paystackReq.Header.Set("Authorization", "Bearer "+h.Config.PaystackSecret)
-	paystackReq.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(paystackReq)
-	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to reach Paystack", nil)
+	charge, ok := h.Charge(w, r, fee.CategoryDeposit, wallet.Currency, req.Amount)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		logger.Error("Paystack error", logger.Fields{
-			"status_code": resp.StatusCode,
-			"body":        string(respBody),
-			"payload":     payload,
-		})
-		utils.BuildErrorResponse(w, http.StatusBadGateway, "Paystack error", nil)
+	if charge.Amount >= req.Amount {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid amount, the deposit fee is %d", charge.Amount), nil)
 		return
 	}
 
-	var paystackResp struct {
-		Status  bool   `json:"status"`
-		Message string `json:"message"`
-		Data    struct {
-			AuthorizationURL string `json:"authorization_url"`
-			Reference        string `json:"reference"`
-		} `json:"data"`
-	}
+	reference := fmt.Sprintf("dep-%s-%d", usr.ID.String(), time.Now().UnixNano())
 
-	if err := json.NewDecoder(resp.Body).Decode(&paystackResp); err != nil {
-		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to parse Paystack response", nil)
-		return
+	initReq := paystack.InitializeRequest{
+		Email:       usr.Email,
+		Amount:      req.Amount,
+		Reference:   reference,
+		Currency:    wallet.Currency,
+		Channels:    h.Config.PaystackChannels,
+		CallbackURL: fmt.Sprintf("%s/wallet/deposit/callback", h.Config.Host),
+		Metadata:    map[string]interface{}{"wallet_id": wallet.ID.String()},
 	}
 
-	if !paystackResp.Status {
-		utils.BuildErrorResponse(w, http.StatusBadRequest, "Paystack initialization failed: "+paystackResp.Message, nil)
+	initResp, err := h.Paystack.InitializeTransaction(r.Context(), initReq)
+	if err != nil {
+		logger.Error("Paystack error", logger.Fields{"error": err.Error(), "reference": reference})
+
+		var apiErr *paystack.APIError
+		if errors.As(err, &apiErr) && !apiErr.Temporary() {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Paystack initialization failed: "+apiErr.Message, nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusBadGateway, "Paystack error", nil)
+		}
 		return
 	}
 
 	tx := Transaction{
-		WalletID:    wallet.ID,
-		Reference:   reference,
-		Category:    CategoryDeposit,
-		Type:        TransactionCredit,
-		Amount:      req.Amount,
-		Status:      TransactionPending,
-		Description: "Wallet Deposit via Paystack",
+		WalletID:      wallet.ID,
+		Reference:     reference,
+		Category:      CategoryDeposit,
+		Type:          TransactionCredit,
+		Amount:        req.Amount,
+		Status:        TransactionPending,
+		Description:   "Wallet Deposit via Paystack",
+		Fee:           charge.Amount,
+		FeeScheduleID: charge.ScheduleID,
 	}
 
 	if err := h.Repo.CreateTransaction(&tx); err != nil {
@@ -184,11 +198,10 @@ func (h *Handler) WalletDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.BuildSuccessResponse(w, http.StatusOK, "Deposit initialized", paystackResp.Data)
+	utils.BuildSuccessResponse(w, http.StatusOK, "Deposit initialized", initResp)
 }
 
 func (h *Handler) PaystackWebhook(w http.ResponseWriter, r *http.Request) {
-	secret := h.Config.PaystackSecret
 	signature := r.Header.Get("x-paystack-signature")
 
 	logger.Info("Webhook received", logger.Fields{"remote_addr": r.RemoteAddr})
@@ -200,98 +213,198 @@ func (h *Handler) PaystackWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hash := hmac.New(sha512.New, []byte(secret))
-	hash.Write(body)
-	expectedSig := hex.EncodeToString(hash.Sum(nil))
+	headers, _ := json.Marshal(r.Header)
+	delivery := webhook.Delivery{
+		Headers:        string(headers),
+		Body:           string(body),
+		SignatureValid: webhook.ValidSignature(h.Config.PaystackSecret, body, signature),
+		Status:         webhook.DeliveryReceived,
+		RemoteAddr:     r.RemoteAddr,
+	}
 
-	if signature != expectedSig {
-		logger.Error("Webhook: Signature mismatch", logger.Fields{
-			"received": signature,
-			"expected": expectedSig,
-		})
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+	payload, parseErr := webhook.ParsePayload(body)
+	if parseErr == nil {
+		delivery.Event = payload.Event
+		delivery.Reference = payload.TransactionRef()
+	}
+	delivery.EventID = webhook.EventID(payload, body)
+
+	if err := h.Deliveries.Create(&delivery); err != nil {
+		logger.Error("Webhook: Failed to store delivery", logger.Fields{"error": err.Error(), "event_id": delivery.EventID})
 	}
 
-	var event struct {
-		Event string `json:"event"`
-		Data  struct {
-			Reference string `json:"reference"`
-			Status    string `json:"status"`
-			Amount    int64  `json:"amount"`
-		} `json:"data"`
+	if !delivery.SignatureValid {
+		logger.Error("Webhook: Signature mismatch", logger.Fields{"received": signature, "delivery_id": delivery.ID.String()})
+		h.finishDelivery(delivery, webhook.DeliveryRejected, "invalid signature")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
 
-	if err := json.Unmarshal(body, &event); err != nil {
+	if parseErr != nil {
+		h.finishDelivery(delivery, webhook.DeliveryRejected, "invalid payload: "+parseErr.Error())
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	tx, err := h.Repo.GetTransactionByReference(event.Data.Reference)
-	if err != nil {
-		logger.Warn("Webhook: Transaction not found", logger.Fields{"reference": event.Data.Reference})
+	// paystack retries until it gets a 2xx, so the same event can arrive more than once
+	if earlier, err := h.Deliveries.FindAccepted(delivery.EventID, delivery.ID.String()); err == nil {
+		logger.Info("Webhook: Duplicate delivery", logger.Fields{"event_id": delivery.EventID, "first_delivery_id": earlier.ID.String()})
+		h.finishDelivery(delivery, webhook.DeliveryDuplicate, "duplicate of "+earlier.ID.String())
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if tx.Status != TransactionPending {
-		logger.Info("Webhook: Transaction already processed", logger.Fields{"reference": event.Data.Reference, "status": tx.Status})
+	if reason := h.skipReason(payload); reason != "" {
+		logger.Info("Webhook: Event not queued", logger.Fields{"event": payload.Event, "reference": payload.TransactionRef(), "reason": reason})
+		h.finishDelivery(delivery, webhook.DeliveryIgnored, reason)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	webhookEvent := events.WebhookEvent{
-		Event:     event.Event,
-		Reference: event.Data.Reference,
-		Status:    event.Data.Status,
-		Amount:    event.Data.Amount,
-		Timestamp: time.Now(),
+	if err := h.RedisClient.PublishEvent(r.Context(), payload.ToEvent(delivery.ID.String())); err != nil {
+		logger.Error("Webhook: Failed to publish event", logger.Fields{"error": err.Error(), "reference": payload.TransactionRef()})
+		// left as RECEIVED so paystack's retry is not mistaken for a duplicate
+		h.finishDelivery(delivery, webhook.DeliveryReceived, "failed to queue: "+err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+	h.finishDelivery(delivery, webhook.DeliveryQueued, "")
+	logger.Info("Webhook: Event queued", logger.Fields{"reference": payload.TransactionRef(), "event": payload.Event})
 
-	if event.Event == "charge.success" || event.Event == "charge.failed" {
+	w.WriteHeader(http.StatusOK)
+}
 
-		if err := h.RedisClient.PublishEvent(r.Context(), webhookEvent); err != nil {
-			logger.Error("Webhook: Failed to publish event", logger.Fields{"error": err.Error(), "reference": event.Data.Reference})
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+// skipReason says why an event should not reach the worker, it is empty when the event should be queued
+func (h *Handler) skipReason(payload *webhook.Payload) string {
+	// transfers into a dedicated account have no pending transaction, paystack makes up the reference
+	if payload.Event == "charge.success" && payload.Data.Channel == dedicatedAccountChannel {
+		if _, err := h.Repo.GetTransactionByReference(payload.Data.Reference); err == nil {
+			return "bank transfer already recorded"
 		}
-		logger.Info("Webhook: Event queued", logger.Fields{"reference": event.Data.Reference, "event": event.Event})
+		if payload.Data.Customer == nil || payload.Data.Customer.CustomerCode == "" {
+			return "bank transfer has no customer"
+		}
+		return ""
 	}
 
-	w.WriteHeader(http.StatusOK)
+	switch payload.Event {
+	case "charge.success", "charge.failed", "transfer.success", "transfer.failed", "transfer.reversed":
+		tx, err := h.Repo.GetTransactionByReference(payload.Data.Reference)
+		if err != nil {
+			return "transaction not found"
+		}
+
+		// a reversal can follow a successful transfer, so settled withdrawals still accept it
+		reversible := payload.Event == "transfer.reversed" && tx.Category == CategoryWithdrawal && tx.Status == TransactionSuccess
+		// an expired deposit can still be paid if the customer finishes an old checkout
+		latePayment := payload.Event == "charge.success" && tx.Status == TransactionExpired
+		if tx.Status != TransactionPending && !reversible && !latePayment {
+			return "transaction already " + string(tx.Status)
+		}
+		return ""
+
+	case "refund.processed", "refund.failed", "charge.dispute.create", "charge.dispute.resolve":
+		// these follow a deposit that already settled, so only its existence is checked
+		if _, err := h.Repo.GetTransactionByReference(payload.TransactionRef()); err != nil {
+			return "transaction not found"
+		}
+		return ""
+
+	case "dedicatedaccount.assign.success", "dedicatedaccount.assign.failed":
+		return ""
+
+	default:
+		return "unhandled event type"
+	}
+}
+
+func (h *Handler) finishDelivery(delivery webhook.Delivery, status webhook.DeliveryStatus, outcome string) {
+	if delivery.ID == uuid.Nil {
+		return
+	}
+	if err := h.Deliveries.UpdateStatus(delivery.ID.String(), status, outcome); err != nil {
+		logger.Error("Webhook: Failed to update delivery", logger.Fields{"error": err.Error(), "delivery_id": delivery.ID.String()})
+	}
 }
 
 func (h *Handler) GetWalletBalance(w http.ResponseWriter, r *http.Request) {
 	usr, _ := r.Context().Value(utils.UserKey).(user.User)
 
-	wallet, err := h.Repo.GetWalletByUserID(usr.ID.String())
-	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
 		return
 	}
 
+	// balance is everything in the wallet, the main balance and the pockets add up to it
 	utils.BuildSuccessResponse(w, http.StatusOK, "Wallet Balance", map[string]any{
-		"balance": wallet.Balance,
+		"balance":           wallet.Balance,
+		"main_balance":      wallet.MainBalance(),
+		"pocket_balance":    wallet.PocketBalance,
+		"available_balance": wallet.AvailableBalance(),
+		"held_balance":      wallet.HeldBalance,
+		"currency":          wallet.Currency,
 	})
 }
 
-func (h *Handler) GetWallet(w http.ResponseWriter, r *http.Request) {
+// VerifyWalletBalance compares the stored balance with the ledger account and its postings
+func (h *Handler) VerifyWalletBalance(w http.ResponseWriter, r *http.Request) {
 	usr, _ := r.Context().Value(utils.UserKey).(user.User)
 
-	wallet, err := h.Repo.GetWalletByUserID(usr.ID.String())
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
+		return
+	}
+
+	check, err := h.Repo.VerifyWalletBalance(wallet.ID.String())
 	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to verify balance", nil)
+		return
+	}
+
+	if !check.Balanced {
+		logger.Error("Wallet balance does not match ledger", logger.Fields{
+			"wallet_id":      wallet.ID.String(),
+			"wallet_balance": check.WalletBalance,
+			"ledger_balance": check.LedgerBalance,
+			"posted_balance": check.PostedBalance,
+		})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Balance verification", check)
+}
+
+func (h *Handler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
 		return
 	}
 
 	utils.BuildSuccessResponse(w, http.StatusOK, "Wallet Details", wallet)
 }
 
+// ListWallets returns every wallet the user holds, one per currency
+func (h *Handler) ListWallets(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	wallets, err := h.Repo.GetWalletsByUserID(usr.ID.String())
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch wallets", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Wallets", wallets)
+}
+
 type TransferRequest struct {
-	WalletNumber string `json:"wallet_number"`
-	Amount       int64  `json:"amount"`
-	Pin          string `json:"pin"`
-	Description  string `json:"description"`
+	WalletNumber  string `json:"wallet_number"`
+	BeneficiaryID string `json:"beneficiary_id"`
+	Amount        int64  `json:"amount"`
+	Pin           string `json:"pin"`
+	Description   string `json:"description"`
+	// Currency picks the sender wallet, the recipient wallet must hold the same currency
+	Currency string `json:"currency"`
 }
 
 func (h *Handler) TransferFunds(w http.ResponseWriter, r *http.Request) {
@@ -303,35 +416,46 @@ func (h *Handler) TransferFunds(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Amount < h.Config.MinTransactionAmount {
-		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount, can't be less than 100 Naira (10000 Kobo)", nil)
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok || !h.CheckAmount(w, req.Amount, currency) {
 		return
 	}
 
-	senderWallet, err := h.Repo.GetWalletByUserID(usr.ID.String())
+	if req.BeneficiaryID != "" {
+		b, err := h.Beneficiaries.GetByID(req.BeneficiaryID, usr.ID.String())
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Beneficiary not found", nil)
+			return
+		}
+		if b.Type != beneficiary.TypeWallet {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Beneficiary is not a wallet beneficiary", nil)
+			return
+		}
+		req.WalletNumber = b.WalletNumber
+	}
+
+	senderWallet, err := h.Repo.GetWalletByUserID(usr.ID.String(), currency)
 	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Sender wallet not found", nil)
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Sender "+currency+" wallet not found", nil)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(senderWallet.PinHash), []byte(req.Pin)); err != nil {
-		utils.BuildErrorResponse(w, http.StatusUnauthorized, "Invalid PIN", nil)
+	if !h.VerifyPin(w, senderWallet, req.Pin) {
 		return
 	}
 
-	if senderWallet.WalletNumber == req.WalletNumber {
-		utils.BuildErrorResponse(w, http.StatusBadRequest, "Cannot transfer to self", nil)
+	recipientWallet, ok := h.TransferRecipient(w, senderWallet, req.WalletNumber)
+	if !ok {
 		return
 	}
 
-	recipientWallet, err := h.Repo.GetWalletByNumber(req.WalletNumber)
-	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Recipient wallet not found", nil)
+	charge, ok := h.Charge(w, r, fee.CategoryTransfer, senderWallet.Currency, req.Amount)
+	if !ok {
 		return
 	}
 
 	reference := fmt.Sprintf("trf-%d", time.Now().UnixNano())
-	if err := h.Repo.TransferFunds(senderWallet.ID.String(), recipientWallet.ID.String(), senderWallet.WalletNumber, recipientWallet.WalletNumber, reference, req.Amount, req.Description); err != nil {
+	if err := SendTransfer(h.Repo, senderWallet, recipientWallet, reference, req.Amount, charge, req.Description); err != nil {
 		if err.Error() == "insufficient balance" {
 			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
 		} else {
@@ -343,12 +467,44 @@ func (h *Handler) TransferFunds(w http.ResponseWriter, r *http.Request) {
 	utils.BuildSuccessResponse(w, http.StatusOK, "Transfer completed", nil)
 }
 
+// TransferRecipient loads the wallet a transfer from sender would pay into and writes the error response
+// itself when it cannot receive one
+func (h *Handler) TransferRecipient(w http.ResponseWriter, sender *Wallet, walletNumber string) (*Wallet, bool) {
+	if sender.WalletNumber == walletNumber {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Cannot transfer to self", nil)
+		return nil, false
+	}
+
+	recipient, err := h.Repo.GetWalletByNumber(walletNumber)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Recipient wallet not found", nil)
+		return nil, false
+	}
+
+	if recipient.Currency != sender.Currency {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Currency mismatch, recipient wallet holds %s not %s", recipient.Currency, sender.Currency), nil)
+		return nil, false
+	}
+	return recipient, true
+}
+
+// SendTransfer is the one path wallet-to-wallet transfers take, whether a user makes them or a worker
+// makes them on a user's behalf. The sender pays charge on top of amount.
+func SendTransfer(repo Repository, sender, recipient *Wallet, reference string, amount int64, charge fee.Charge, description string) error {
+	if sender.ID == recipient.ID {
+		return ErrSelfTransfer
+	}
+	if sender.Currency != recipient.Currency {
+		return ErrCurrencyMismatch
+	}
+	return repo.TransferFunds(sender.ID.String(), recipient.ID.String(), sender.WalletNumber, recipient.WalletNumber, reference, amount, charge, description)
+}
+
 func (h *Handler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	usr, _ := r.Context().Value(utils.UserKey).(user.User)
 
-	wallet, err := h.Repo.GetWalletByUserID(usr.ID.String())
-	if err != nil {
-		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
 		return
 	}
 
@@ -395,7 +551,7 @@ func (h *Handler) GetDepositStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if tx.Status == TransactionPending {
-		paystackStatus, err := h.verifyPaystackStatus(reference)
+		paystackStatus, err := h.verifyPaystackStatus(r.Context(), reference)
 		if err == nil {
 			response["paystack_status"] = paystackStatus
 		} else {
@@ -409,42 +565,12 @@ func (h *Handler) GetDepositStatus(w http.ResponseWriter, r *http.Request) {
 	utils.BuildSuccessResponse(w, http.StatusOK, "Transaction status retrieved", response)
 }
 
-func (h *Handler) verifyPaystackStatus(reference string) (string, error) {
-	url := fmt.Sprintf("https://api.paystack.co/transaction/verify/%s", reference)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+h.Config.PaystackSecret)
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+func (h *Handler) verifyPaystackStatus(ctx context.Context, reference string) (string, error) {
+	result, err := h.Paystack.VerifyTransaction(ctx, reference)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("paystack returned status %d", resp.StatusCode)
-	}
-
-	var result struct {
-		Status  bool   `json:"status"`
-		Message string `json:"message"`
-		Data    struct {
-			Status string `json:"status"`
-		} `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-
-	if !result.Status {
-		return "", fmt.Errorf("paystack verification failed: %s", result.Message)
-	}
-
-	return result.Data.Status, nil
+	return result.Status, nil
 }
 
 func generateWalletNumber() string {
@@ -0,0 +1,1580 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/fx"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack/paystacktest"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// memoryRepo is an in-memory Repository, methods a test does not need panic through the nil embed
+type memoryRepo struct {
+	Repository
+
+	mu           sync.Mutex
+	wallets      map[uuid.UUID]*Wallet
+	transactions map[string]*Transaction
+	disputes     map[int64]*Dispute
+	quotes       map[uuid.UUID]*Quote
+	holds        map[uuid.UUID]*Hold
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		wallets:      make(map[uuid.UUID]*Wallet),
+		transactions: make(map[string]*Transaction),
+		disputes:     make(map[int64]*Dispute),
+		quotes:       make(map[uuid.UUID]*Quote),
+		holds:        make(map[uuid.UUID]*Hold),
+	}
+}
+
+func (m *memoryRepo) CreateWallet(w *Wallet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now()
+	}
+	m.wallets[w.ID] = w
+	return nil
+}
+
+func (m *memoryRepo) GetWalletByID(walletID string) (*Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wallets[uuid.MustParse(walletID)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (m *memoryRepo) GetWalletByCustomerCode(code string) (*Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.wallets {
+		if w.PaystackCustomerCode == code {
+			cp := *w
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryRepo) SetPaystackCustomer(walletID, customerCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[uuid.MustParse(walletID)].PaystackCustomerCode = customerCode
+	return nil
+}
+
+func (m *memoryRepo) UpdateDedicatedAccount(walletID string, account DedicatedAccount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[uuid.MustParse(walletID)].DedicatedAccount = account
+	return nil
+}
+
+func (m *memoryRepo) GetWalletByUserID(userID, currency string) (*Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.wallets {
+		if w.UserID.String() == userID && w.Currency == currency {
+			cp := *w
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryRepo) GetWalletsByUserID(userID string) ([]Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var wallets []Wallet
+	for _, w := range m.wallets {
+		if w.UserID.String() == userID {
+			wallets = append(wallets, *w)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].CreatedAt.Before(wallets[j].CreatedAt) })
+	return wallets, nil
+}
+
+func (m *memoryRepo) GetWalletByNumber(number string) (*Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.wallets {
+		if w.WalletNumber == number {
+			cp := *w
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// userWallets mirrors the repository applying PIN state to every wallet of the same user
+func (m *memoryRepo) userWallets(walletID string) []*Wallet {
+	owner := m.wallets[uuid.MustParse(walletID)].UserID
+	var wallets []*Wallet
+	for _, w := range m.wallets {
+		if w.UserID == owner {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets
+}
+
+func (m *memoryRepo) CreateTransaction(tx *Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transactions[tx.Reference] = tx
+	return nil
+}
+
+func (m *memoryRepo) GetTransactionByReference(ref string) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.transactions[ref]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+func (m *memoryRepo) ProcessDeposit(reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := m.transactions[reference]
+	if tx.Status == TransactionSuccess || tx.Status == TransactionReview {
+		return nil
+	}
+	m.wallets[tx.WalletID].Balance += tx.Amount - tx.Fee
+	tx.Status = TransactionSuccess
+	return nil
+}
+
+func (m *memoryRepo) RecordBankTransferDeposit(deposit *Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.transactions[deposit.Reference]; ok {
+		return nil
+	}
+	m.transactions[deposit.Reference] = deposit
+	if deposit.Status == TransactionSuccess {
+		m.wallets[deposit.WalletID].Balance += deposit.Amount
+	}
+	return nil
+}
+
+func (m *memoryRepo) FlagForReview(reference, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx := m.transactions[reference]; tx.Status == TransactionPending || tx.Status == TransactionExpired {
+		tx.Status = TransactionReview
+		tx.ReviewReason = reason
+	}
+	return nil
+}
+
+func (m *memoryRepo) ResolveReview(reference string, approve bool) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.transactions[reference]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if tx.Category != CategoryDeposit || tx.Status != TransactionReview {
+		return nil, ErrNotInReview
+	}
+	if approve {
+		m.wallets[tx.WalletID].Balance += tx.Amount - tx.Fee
+		tx.Status = TransactionSuccess
+	} else {
+		tx.Status = TransactionFailed
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+func (m *memoryRepo) ProcessFailedTransaction(reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := m.transactions[reference]
+	if tx.Status == TransactionPending {
+		tx.Status = TransactionFailed
+	}
+	return nil
+}
+
+func (m *memoryRepo) GetStalePendingDeposits(createdBefore time.Time, limit int) ([]Transaction, error) {
+	return m.claimStale(CategoryDeposit, createdBefore, limit)
+}
+
+func (m *memoryRepo) claimStale(category TransactionCategory, createdBefore time.Time, limit int) ([]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stale []*Transaction
+	for _, tx := range m.transactions {
+		if tx.Category == category && tx.Status == TransactionPending && tx.CreatedAt.Before(createdBefore) {
+			stale = append(stale, tx)
+		}
+	}
+	checked := func(tx *Transaction) time.Time {
+		if tx.CheckedAt == nil {
+			return time.Time{}
+		}
+		return *tx.CheckedAt
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if !checked(stale[i]).Equal(checked(stale[j])) {
+			return checked(stale[i]).Before(checked(stale[j]))
+		}
+		return stale[i].CreatedAt.Before(stale[j].CreatedAt)
+	})
+	if len(stale) > limit {
+		stale = stale[:limit]
+	}
+	now := time.Now()
+	txs := make([]Transaction, len(stale))
+	for i, tx := range stale {
+		tx.CheckedAt = &now
+		txs[i] = *tx
+	}
+	return txs, nil
+}
+
+func (m *memoryRepo) ExpireDeposit(reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx := m.transactions[reference]; tx.Status == TransactionPending {
+		tx.Status = TransactionExpired
+	}
+	return nil
+}
+
+func (m *memoryRepo) InitiateWithdrawal(walletID, reference string, amount int64, charge fee.Charge, description string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	if w.AvailableBalance() < amount+charge.Amount {
+		return errors.New("insufficient balance")
+	}
+	w.Balance -= amount + charge.Amount
+	m.transactions[reference] = &Transaction{
+		WalletID:      w.ID,
+		Reference:     reference,
+		Category:      CategoryWithdrawal,
+		Type:          TransactionDebit,
+		Amount:        amount,
+		Fee:           charge.Amount,
+		FeeScheduleID: charge.ScheduleID,
+		Status:        TransactionPending,
+		Description:   description,
+	}
+	return nil
+}
+
+func (m *memoryRepo) GetStalePendingWithdrawals(createdBefore time.Time, limit int) ([]Transaction, error) {
+	return m.claimStale(CategoryWithdrawal, createdBefore, limit)
+}
+
+func (m *memoryRepo) CompleteWithdrawal(reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx := m.transactions[reference]; tx.Status == TransactionPending {
+		tx.Status = TransactionSuccess
+	}
+	return nil
+}
+
+func (m *memoryRepo) ReverseWithdrawal(reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := m.transactions[reference]
+	if tx.Status == TransactionFailed {
+		return nil
+	}
+	m.wallets[tx.WalletID].Balance += tx.Amount + tx.Fee
+	tx.Status = TransactionFailed
+	return nil
+}
+
+func (m *memoryRepo) ProcessRefund(depositReference, refundReference string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deposit, ok := m.transactions[depositReference]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if deposit.Status != TransactionSuccess {
+		return errors.New("deposit not settled")
+	}
+	if pending := m.pendingRefund(depositReference, refundReference, amount); pending != nil {
+		if pending.Status == TransactionPending {
+			m.rename(pending, refundReference)
+			pending.Status = TransactionSuccess
+		}
+		return nil
+	}
+	if amount <= 0 || amount > m.refundable(deposit) {
+		return ErrRefundTooLarge
+	}
+	w := m.wallets[deposit.WalletID]
+	if w.AvailableBalance() < amount {
+		return errors.New("insufficient balance")
+	}
+	w.Balance -= amount
+	m.transactions[refundReference] = &Transaction{
+		WalletID:  deposit.WalletID,
+		Reference: refundReference,
+		Category:  CategoryRefund,
+		Type:      TransactionDebit,
+		Amount:    amount,
+		Status:    TransactionSuccess,
+		RefundOf:  &deposit.Reference,
+	}
+	return nil
+}
+
+func (m *memoryRepo) refundable(deposit *Transaction) int64 {
+	remaining := deposit.Amount - deposit.Fee
+	for _, tx := range m.transactions {
+		if tx.RefundOf != nil && *tx.RefundOf == deposit.Reference && tx.Status != TransactionFailed {
+			remaining -= tx.Amount
+		}
+	}
+	return remaining
+}
+
+func (m *memoryRepo) InitiateRefund(depositReference, reference string, amount int64, description string) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deposit, ok := m.transactions[depositReference]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if deposit.Status != TransactionSuccess {
+		return nil, ErrNotRefundable
+	}
+	remaining := m.refundable(deposit)
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return nil, ErrRefundTooLarge
+	}
+	w := m.wallets[deposit.WalletID]
+	if w.AvailableBalance() < amount {
+		return nil, errors.New("insufficient balance")
+	}
+	w.Balance -= amount
+	refund := &Transaction{
+		WalletID:    w.ID,
+		Reference:   reference,
+		Category:    CategoryRefund,
+		Type:        TransactionDebit,
+		Amount:      amount,
+		Status:      TransactionPending,
+		Description: description,
+		RefundOf:    &deposit.Reference,
+	}
+	m.transactions[reference] = refund
+	cp := *refund
+	return &cp, nil
+}
+
+func (m *memoryRepo) AttachRefund(reference, refundReference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx, ok := m.transactions[reference]; ok && tx.Status == TransactionPending {
+		m.rename(tx, refundReference)
+	}
+	return nil
+}
+
+func (m *memoryRepo) FailRefund(depositReference, refundReference string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := m.pendingRefund(depositReference, refundReference, amount)
+	if pending == nil || pending.Status != TransactionPending {
+		return nil
+	}
+	m.wallets[pending.WalletID].Balance += pending.Amount
+	m.rename(pending, refundReference)
+	pending.Status = TransactionFailed
+	return nil
+}
+
+func (m *memoryRepo) pendingRefund(depositReference, refundReference string, amount int64) *Transaction {
+	if tx, ok := m.transactions[refundReference]; ok {
+		return tx
+	}
+	for ref, tx := range m.transactions {
+		if tx.RefundOf != nil && *tx.RefundOf == depositReference && tx.Status == TransactionPending && tx.Amount == amount && strings.HasPrefix(ref, pendingRefundPrefix) {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepo) rename(tx *Transaction, reference string) {
+	delete(m.transactions, tx.Reference)
+	tx.Reference = reference
+	m.transactions[reference] = tx
+}
+
+func (m *memoryRepo) ReverseTransfer(reference string, amount int64, force bool, reason string) ([]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent, received := m.transactions[reference+"-debit"], m.transactions[reference+"-credit"]
+	if sent == nil || received == nil || sent.Category != CategoryTransfer {
+		return nil, ErrNotReversible
+	}
+	remaining := sent.Amount - sent.ReversedAmount
+	switch {
+	case remaining <= 0:
+		return nil, ErrAlreadyReversed
+	case amount == 0:
+		amount = remaining
+	case amount > remaining:
+		return nil, ErrReversalTooLarge
+	}
+	recipient := m.wallets[received.WalletID]
+	if !force && recipient.AvailableBalance() < amount {
+		return nil, errors.New("insufficient balance")
+	}
+	recipient.Balance -= amount
+	m.wallets[sent.WalletID].Balance += amount
+	legs := []Transaction{
+		{WalletID: received.WalletID, Reference: "rev-" + reference + "-debit", Category: CategoryTransfer, Type: TransactionDebit, Amount: amount, Status: TransactionSuccess, Description: reason, ReversalOf: &received.Reference},
+		{WalletID: sent.WalletID, Reference: "rev-" + reference + "-credit", Category: CategoryTransfer, Type: TransactionCredit, Amount: amount, Status: TransactionSuccess, Description: reason, ReversalOf: &sent.Reference},
+	}
+	for _, leg := range []*Transaction{sent, received} {
+		leg.ReversedAmount += amount
+		leg.Status = TransactionReversed
+	}
+	return legs, nil
+}
+
+func (m *memoryRepo) RecordDispute(dispute *Dispute) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *dispute
+	m.disputes[dispute.PaystackID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) CreateQuote(quote *Quote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	quote.ID = uuid.New()
+	cp := *quote
+	m.quotes[quote.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) GetQuote(quoteID, userID string) (*Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.quotes[uuid.MustParse(quoteID)]
+	if !ok || q.UserID.String() != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *q
+	return &cp, nil
+}
+
+func (m *memoryRepo) ExecuteConversion(quoteID string, now time.Time) (*Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := m.quotes[uuid.MustParse(quoteID)]
+	if q.ExecutedAt != nil {
+		return nil, ErrQuoteExecuted
+	}
+	if now.After(q.ExpiresAt) {
+		return nil, ErrQuoteExpired
+	}
+	from := m.wallets[q.FromWalletID]
+	if from.Balance < q.Amount {
+		return nil, errors.New("insufficient balance")
+	}
+	from.Balance -= q.Amount
+	m.wallets[q.ToWalletID].Balance += q.ConvertedAmount
+
+	reference := "fx-" + q.ID.String()
+	conversion := Conversion{QuoteID: &q.ID, FromCurrency: q.FromCurrency, ToCurrency: q.ToCurrency, Rate: q.Rate, MarketRate: q.MarketRate, SpreadBps: q.SpreadBps, Fee: q.Fee}
+	m.transactions[reference+"-debit"] = &Transaction{WalletID: q.FromWalletID, Reference: reference + "-debit", Category: CategoryConversion, Type: TransactionDebit, Amount: q.Amount, Status: TransactionSuccess, Conversion: conversion}
+	m.transactions[reference+"-credit"] = &Transaction{WalletID: q.ToWalletID, Reference: reference + "-credit", Category: CategoryConversion, Type: TransactionCredit, Amount: q.ConvertedAmount, Status: TransactionSuccess, Conversion: conversion}
+
+	q.ExecutedAt = &now
+	q.Reference = reference
+	cp := *q
+	return &cp, nil
+}
+
+func (m *memoryRepo) PlaceHold(hold *Hold) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[hold.WalletID]
+	if w.AvailableBalance() < hold.Amount {
+		return errors.New("insufficient balance")
+	}
+	w.HeldBalance += hold.Amount
+	hold.ID = uuid.New()
+	hold.Status = HoldActive
+	cp := *hold
+	m.holds[hold.ID] = &cp
+	return nil
+}
+
+func (m *memoryRepo) GetHold(holdID string) (*Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, err := uuid.Parse(holdID)
+	if err != nil {
+		return nil, err
+	}
+	hold, ok := m.holds[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *hold
+	return &cp, nil
+}
+
+func (m *memoryRepo) CaptureHold(holdID string, amount int64, now time.Time) (*Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hold := m.holds[uuid.MustParse(holdID)]
+	switch {
+	case hold.Status != HoldActive:
+		return nil, ErrHoldNotActive
+	case now.After(hold.ExpiresAt):
+		return nil, ErrHoldExpired
+	case amount > hold.Amount:
+		return nil, ErrCaptureTooLarge
+	}
+	payer := m.wallets[hold.WalletID]
+	payer.Balance -= amount
+	payer.HeldBalance -= hold.Amount
+	m.wallets[*hold.RecipientWalletID].Balance += amount
+	hold.Status = HoldCaptured
+	hold.CapturedAmount = amount
+	hold.CapturedAt = &now
+	cp := *hold
+	return &cp, nil
+}
+
+func (m *memoryRepo) ReleaseHold(holdID string, now time.Time) (*Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hold := m.holds[uuid.MustParse(holdID)]
+	if hold.Status != HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	m.wallets[hold.WalletID].HeldBalance -= hold.Amount
+	hold.Status = HoldReleased
+	hold.ReleasedAt = &now
+	cp := *hold
+	return &cp, nil
+}
+
+func (m *memoryRepo) TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := m.wallets[uuid.MustParse(fromID)]
+	if from.AvailableBalance() < amount+charge.Amount {
+		return errors.New("insufficient balance")
+	}
+	to := m.wallets[uuid.MustParse(toID)]
+	from.Balance -= amount + charge.Amount
+	to.Balance += amount
+	m.transactions[reference+"-debit"] = &Transaction{WalletID: from.ID, Reference: reference + "-debit", Category: CategoryTransfer, Type: TransactionDebit, Amount: amount, Fee: charge.Amount, FeeScheduleID: charge.ScheduleID, Status: TransactionSuccess, SenderWalletNumber: &senderNumber, RecipientWalletNumber: &recipientNumber, Description: description}
+	m.transactions[reference+"-credit"] = &Transaction{WalletID: to.ID, Reference: reference + "-credit", Category: CategoryTransfer, Type: TransactionCredit, Amount: amount, Status: TransactionSuccess, SenderWalletNumber: &senderNumber, RecipientWalletNumber: &recipientNumber, Description: description}
+	return nil
+}
+
+func (m *memoryRepo) ClaimPinAttempt(walletID string, maxAttempts int, now time.Time) (*Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	if w.PinLocked(now) || w.FailedPinAttempts >= maxAttempts {
+		cp := *w
+		return &cp, ErrPinLocked
+	}
+	for _, uw := range m.userWallets(walletID) {
+		uw.FailedPinAttempts++
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (m *memoryRepo) RecordFailedPinAttempt(walletID string, maxAttempts int, lockFor time.Duration) (*Wallet, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	locked := w.FailedPinAttempts >= maxAttempts
+	if locked {
+		lockedUntil := time.Now().Add(lockFor)
+		for _, uw := range m.userWallets(walletID) {
+			uw.PinLockedUntil = &lockedUntil
+			uw.FailedPinAttempts = 0
+		}
+	}
+	cp := *w
+	return &cp, locked, nil
+}
+
+func (m *memoryRepo) ResetPinAttempts(walletID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.userWallets(walletID) {
+		w.FailedPinAttempts = 0
+		w.PinLockedUntil = nil
+	}
+	return nil
+}
+
+func (m *memoryRepo) UpdatePin(walletID, pinHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.userWallets(walletID) {
+		w.PinHash = pinHash
+		w.FailedPinAttempts = 0
+		w.PinLockedUntil = nil
+		now := time.Now()
+		w.PinChangedAt = &now
+	}
+	return nil
+}
+
+type memoryAudit struct {
+	mu   sync.Mutex
+	logs []audit.Log
+}
+
+func (m *memoryAudit) Record(entry *audit.Log) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs = append(m.logs, *entry)
+	return nil
+}
+
+func (m *memoryAudit) actions() []audit.Action {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var actions []audit.Action
+	for _, l := range m.logs {
+		actions = append(actions, l.Action)
+	}
+	return actions
+}
+
+type memoryNotifications struct {
+	notification.Repository
+
+	mu   sync.Mutex
+	sent []notification.Notification
+}
+
+func (m *memoryNotifications) Create(n *notification.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, *n)
+	return nil
+}
+
+// memoryFees prices with fee.Match over its schedules, like the database repository does over active ones
+type memoryFees struct {
+	fee.Repository
+
+	mu        sync.Mutex
+	schedules []fee.Schedule
+}
+
+func (m *memoryFees) add(s fee.Schedule) uuid.UUID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID, s.Active, s.CreatedAt = uuid.New(), true, time.Now()
+	m.schedules = append(m.schedules, s)
+	return s.ID
+}
+
+func (m *memoryFees) Charge(category fee.Category, currency string, amount int64, apiKeyID *uuid.UUID, userTier string) (fee.Charge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var candidates []fee.Schedule
+	for _, s := range m.schedules {
+		if s.Category == category && s.Currency == currency && s.Active {
+			candidates = append(candidates, s)
+		}
+	}
+	schedule := fee.Match(candidates, apiKeyID, userTier)
+	if schedule == nil {
+		return fee.Charge{}, nil
+	}
+	return fee.Charge{ScheduleID: &schedule.ID, Amount: schedule.Compute(amount)}, nil
+}
+
+type testEnv struct {
+	handler  *Handler
+	repo     *memoryRepo
+	audit    *memoryAudit
+	fees     *memoryFees
+	notified *memoryNotifications
+	paystack *paystacktest.Server
+	user     user.User
+	wallet   *Wallet
+}
+
+func newTestEnv(t *testing.T, balance int64) *testEnv {
+	t.Helper()
+
+	srv := paystacktest.NewServer()
+	t.Cleanup(srv.Close)
+
+	repo := newMemoryRepo()
+	usr := user.User{ID: uuid.New(), Email: "user@example.com", Name: "Test User"}
+
+	pinHash, err := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	w := &Wallet{UserID: usr.ID, WalletNumber: "0123456789", Balance: balance, Currency: "NGN", PinHash: string(pinHash)}
+	require.NoError(t, repo.CreateWallet(w))
+
+	cfg := config.Config{
+		PaystackSecret:        srv.Secret,
+		PaystackChannels:      []string{"card"},
+		MinTransactionAmounts: map[string]int64{"NGN": 10000, "GHS": 100},
+		Host:                  "http://localhost:8080",
+		PinMaxAttempts:        3,
+		PinLockDuration:       time.Minute,
+		FXQuoteTTL:            time.Minute,
+		FXSpreadBps:           100,
+		FXFeeBps:              50,
+		BatchMaxItems:         3,
+		RequestDefaultExpiry:  time.Hour,
+		RequestMaxExpiry:      24 * time.Hour,
+		EscrowDefaultRelease:  24 * time.Hour,
+		EscrowMaxRelease:      7 * 24 * time.Hour,
+	}
+	client := paystack.NewClient(srv.Secret, srv.URL, paystack.WithRetries(1, time.Millisecond))
+	auditLog := &memoryAudit{}
+	notifications := &memoryNotifications{}
+	fees := &memoryFees{}
+
+	return &testEnv{
+		handler:  NewHandler(cfg, repo, nil, auditLog, nil, notifications, fees, nil, client, fx.NewStaticProvider("USD", map[string]float64{"NGN": 1500, "GHS": 15})),
+		repo:     repo,
+		audit:    auditLog,
+		fees:     fees,
+		notified: notifications,
+		paystack: srv,
+		user:     usr,
+		wallet:   w,
+	}
+}
+
+func (e *testEnv) do(handler http.HandlerFunc, method, path string, body interface{}, vars map[string]string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, e.user))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func decodeData(t *testing.T, rr *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestWalletDepositAndStatus(t *testing.T) {
+	env := newTestEnv(t, 0)
+
+	rr := env.do(env.handler.WalletDeposit, "POST", "/wallet/deposit", DepositRequest{Amount: 50000}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	reference := decodeData(t, rr)["reference"].(string)
+	tx, err := env.repo.GetTransactionByReference(reference)
+	require.NoError(t, err)
+	assert.Equal(t, TransactionPending, tx.Status)
+	assert.Equal(t, int64(50000), tx.Amount)
+
+	remote, ok := env.paystack.Transaction(reference)
+	require.True(t, ok)
+	assert.Equal(t, env.wallet.ID.String(), remote.Metadata["wallet_id"])
+
+	env.paystack.SetTransactionStatus(reference, "success")
+
+	rr = env.do(env.handler.GetDepositStatus, "GET", "/wallet/deposit/"+reference+"/status", nil, map[string]string{"reference": reference})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "success", decodeData(t, rr)["paystack_status"])
+}
+
+func TestWalletDepositBelowMinimum(t *testing.T) {
+	env := newTestEnv(t, 0)
+
+	rr := env.do(env.handler.WalletDeposit, "POST", "/wallet/deposit", DepositRequest{Amount: 100}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, 0, env.paystack.Hits("/transaction/initialize"))
+}
+
+func TestDepositCallback(t *testing.T) {
+	env := newTestEnv(t, 0)
+	env.handler.Config.DepositSuccessURL = "https://app.example.com/deposit/success"
+	env.handler.Config.DepositFailureURL = "https://app.example.com/deposit/failed"
+
+	rr := env.do(env.handler.WalletDeposit, "POST", "/wallet/deposit", DepositRequest{Amount: 50000}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	reference := decodeData(t, rr)["reference"].(string)
+
+	// returning before payment completes leaves the deposit pending
+	rr = env.do(env.handler.DepositCallback, "GET", "/wallet/deposit/callback?reference="+reference, nil, nil)
+	require.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://app.example.com/deposit/failed?reference="+reference+"&status=pending", rr.Header().Get("Location"))
+
+	env.paystack.SetTransactionStatus(reference, "success")
+
+	for i := 0; i < 2; i++ {
+		rr = env.do(env.handler.DepositCallback, "GET", "/wallet/deposit/callback?trxref="+reference+"&reference="+reference, nil, nil)
+		require.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://app.example.com/deposit/success?reference="+reference+"&status=success", rr.Header().Get("Location"))
+	}
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(50000), w.Balance)
+}
+
+func TestDepositCallbackUnknownReference(t *testing.T) {
+	env := newTestEnv(t, 0)
+
+	rr := env.do(env.handler.DepositCallback, "GET", "/wallet/deposit/callback?reference=dep-missing", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, 0, env.paystack.Hits("/transaction/verify/dep-missing"))
+}
+
+func TestWithdraw(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+
+	rr := env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{
+		Amount:        40000,
+		AccountNumber: "0001234567",
+		AccountName:   "John Doe",
+		BankCode:      "058",
+		Pin:           "1234",
+	}, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+
+	reference := decodeData(t, rr)["reference"].(string)
+	transfer, ok := env.paystack.Transfer(reference)
+	require.True(t, ok)
+	assert.Equal(t, int64(40000), transfer.Amount)
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(60000), w.Balance)
+}
+
+func TestWithdrawReleasesHoldWhenTransferFails(t *testing.T) {
+	env := newTestEnv(t, 100000)
+
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+	env.handler.Paystack = failingTransfers{env.handler.Paystack}
+
+	rr := env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{
+		Amount:        40000,
+		AccountNumber: "0001234567",
+		AccountName:   "John Doe",
+		BankCode:      "058",
+		Pin:           "1234",
+	}, nil)
+	require.Equal(t, http.StatusBadGateway, rr.Code, rr.Body.String())
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(100000), w.Balance)
+}
+
+// unansweredTransfers loses every transfer request before it reaches paystack, the client only sees a timeout
+type unansweredTransfers struct {
+	paystack.Client
+}
+
+func (u unansweredTransfers) InitiateTransfer(ctx context.Context, req paystack.TransferRequest) (*paystack.Transfer, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func TestWithdrawLeftPendingWhenTransferOutcomeUnknown(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	env.handler.Config.ReconcileStaleAfter = 0
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+	env.handler.Paystack = paystack.NewClient(env.paystack.Secret, env.paystack.URL,
+		paystack.WithRetries(1, time.Millisecond),
+		paystack.WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+	withdraw := WithdrawRequest{Amount: 40000, AccountNumber: "0001234567", AccountName: "John Doe", BankCode: "058", Pin: "1234"}
+
+	// paystack accepts the transfer but neither attempt gets an answer back in time
+	env.paystack.StallNext("/transfer", 2, time.Second)
+	rr := env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", withdraw, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	accepted := decodeData(t, rr)["reference"].(string)
+	_, ok := env.paystack.Transfer(accepted)
+	require.True(t, ok)
+	assert.Equal(t, TransactionPending, env.repo.transactions[accepted].Status)
+	assert.Equal(t, int64(60000), env.wallet.Balance, "the hold must stay until paystack says how the transfer went")
+
+	// the request never reaches paystack
+	client := env.handler.Paystack
+	env.handler.Paystack = unansweredTransfers{client}
+	rr = env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", withdraw, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	lost := decodeData(t, rr)["reference"].(string)
+	assert.Equal(t, int64(20000), env.wallet.Balance)
+
+	env.paystack.SetTransferStatus(accepted, "success")
+	reconciler := NewDepositReconciler(env.handler.Config, env.repo, client)
+	settled, err := reconciler.ReconcileWithdrawals(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, settled)
+
+	assert.Equal(t, TransactionSuccess, env.repo.transactions[accepted].Status)
+	assert.Equal(t, TransactionFailed, env.repo.transactions[lost].Status)
+	assert.Equal(t, int64(60000), env.wallet.Balance)
+}
+
+func TestWithdrawReversedOnce(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+
+	rr := env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{Amount: 40000, AccountNumber: "0001234567", AccountName: "John Doe", BankCode: "058", Pin: "1234"}, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	reference := decodeData(t, rr)["reference"].(string)
+	assert.Equal(t, int64(60000), env.wallet.Balance)
+
+	// transfer.failed and transfer.reversed for the same transfer are handled side by side
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, env.repo.ReverseWithdrawal(reference))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, TransactionFailed, env.repo.transactions[reference].Status)
+	assert.Equal(t, int64(100000), env.wallet.Balance, "the withdrawal is given back exactly once")
+}
+
+func TestWithdrawInvalidPin(t *testing.T) {
+	env := newTestEnv(t, 100000)
+
+	rr := env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{
+		Amount:        40000,
+		AccountNumber: "0001234567",
+		AccountName:   "John Doe",
+		BankCode:      "058",
+		Pin:           "0000",
+	}, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, 0, env.paystack.Hits("/transferrecipient"))
+}
+
+func TestPinLockout(t *testing.T) {
+	env := newTestEnv(t, 100000)
+
+	transfer := TransferRequest{WalletNumber: "9999999999", Amount: 20000, Pin: "0000"}
+	for i := 0; i < 2; i++ {
+		rr := env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", transfer, nil)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	rr := env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", transfer, nil)
+	require.Equal(t, http.StatusLocked, rr.Code, rr.Body.String())
+
+	// the correct PIN is refused while the lock is active
+	transfer.Pin = "1234"
+	rr = env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", transfer, nil)
+	assert.Equal(t, http.StatusLocked, rr.Code)
+
+	assert.Equal(t, []audit.Action{
+		audit.ActionPinFailedAttempt,
+		audit.ActionPinFailedAttempt,
+		audit.ActionPinFailedAttempt,
+		audit.ActionPinLocked,
+	}, env.audit.actions())
+
+	// a reset lifts the lock
+	rr = env.do(env.handler.ResetPin, "POST", "/wallet/pin/reset", ResetPinRequest{NewPin: "5678"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Nil(t, w.PinLockedUntil)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(w.PinHash), []byte("5678")))
+}
+
+func TestPinLockoutConcurrentGuesses(t *testing.T) {
+	env := newTestEnv(t, 100000)
+
+	const guesses = 10
+	var wg sync.WaitGroup
+	codes := make(chan *httptest.ResponseRecorder, guesses)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes <- env.do(env.handler.ChangePin, "PUT", "/wallet/pin", ChangePinRequest{CurrentPin: fmt.Sprintf("%04d", i), NewPin: "4321"}, nil)
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	// only PinMaxAttempts guesses get compared, the rest are turned away before the PIN is looked at
+	refused := 0
+	for rr := range codes {
+		require.Contains(t, []int{http.StatusUnauthorized, http.StatusLocked}, rr.Code, rr.Body.String())
+		if strings.Contains(rr.Body.String(), "Wallet is locked after too many failed PIN attempts") {
+			refused++
+		}
+	}
+	assert.Equal(t, guesses-env.handler.Config.PinMaxAttempts, refused)
+	assert.True(t, env.wallet.PinLocked(time.Now()))
+
+	var failed int
+	for _, a := range env.audit.actions() {
+		if a == audit.ActionPinFailedAttempt {
+			failed++
+		}
+	}
+	assert.Equal(t, env.handler.Config.PinMaxAttempts, failed)
+}
+
+func TestChangePin(t *testing.T) {
+	env := newTestEnv(t, 0)
+
+	rr := env.do(env.handler.ChangePin, "PUT", "/wallet/pin", ChangePinRequest{CurrentPin: "0000", NewPin: "4321"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = env.do(env.handler.ChangePin, "PUT", "/wallet/pin", ChangePinRequest{CurrentPin: "1234", NewPin: "12a4"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = env.do(env.handler.ChangePin, "PUT", "/wallet/pin", ChangePinRequest{CurrentPin: "1234", NewPin: "4321"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(w.PinHash), []byte("4321")))
+	assert.Equal(t, 0, w.FailedPinAttempts)
+	assert.Contains(t, env.audit.actions(), audit.ActionPinChanged)
+}
+
+type failingTransfers struct {
+	paystack.Client
+}
+
+func (f failingTransfers) InitiateTransfer(ctx context.Context, req paystack.TransferRequest) (*paystack.Transfer, error) {
+	return nil, &paystack.APIError{StatusCode: http.StatusBadRequest, Message: "Your balance is not enough to fulfil this request"}
+}
+
+func TestRequestDedicatedAccount(t *testing.T) {
+	env := newTestEnv(t, 0)
+	env.handler.Config.DedicatedAccountBank = "test-bank"
+
+	rr := env.do(env.handler.RequestDedicatedAccount, "POST", "/wallet/dedicated-account", nil, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	data := decodeData(t, rr)
+	assert.Equal(t, "ACTIVE", data["status"])
+	assert.NotEmpty(t, data["account_number"])
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, data["account_number"], w.DedicatedAccount.AccountNumber)
+	assert.NotEmpty(t, w.PaystackCustomerCode)
+
+	// asking again returns the stored account without another paystack round trip
+	rr = env.do(env.handler.RequestDedicatedAccount, "POST", "/wallet/dedicated-account", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 1, env.paystack.Hits("/customer"))
+	assert.Equal(t, 1, env.paystack.Hits("/dedicated_account"))
+}
+
+func TestMultiCurrencyWallets(t *testing.T) {
+	env := newTestEnv(t, 100000)
+
+	// further wallets are opened with the PIN the user already has
+	rr := env.do(env.handler.CreateWallet, "POST", "/wallet/create", CreateWalletRequest{Pin: "0000", Currency: "ghs"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = env.do(env.handler.CreateWallet, "POST", "/wallet/create", CreateWalletRequest{Pin: "1234", Currency: "ghs"}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "GHS", decodeData(t, rr)["currency"])
+
+	rr = env.do(env.handler.CreateWallet, "POST", "/wallet/create", CreateWalletRequest{Pin: "1234", Currency: "GHS"}, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = env.do(env.handler.CreateWallet, "POST", "/wallet/create", CreateWalletRequest{Pin: "1234", Currency: "EUR"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	wallets, _ := env.repo.GetWalletsByUserID(env.user.ID.String())
+	require.Len(t, wallets, 2)
+	assert.Equal(t, []string{"NGN", "GHS"}, []string{wallets[0].Currency, wallets[1].Currency})
+	assert.Equal(t, 0, wallets[0].FailedPinAttempts, "a correct PIN clears the earlier failure on every wallet")
+
+	rr = env.do(env.handler.GetWalletBalance, "GET", "/wallet/balance?currency=GHS", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "GHS", decodeData(t, rr)["currency"])
+
+	// minimums are per currency, 500 pesewas clears GHS but not NGN
+	rr = env.do(env.handler.WalletDeposit, "POST", "/wallet/deposit", DepositRequest{Amount: 500}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = env.do(env.handler.WalletDeposit, "POST", "/wallet/deposit", DepositRequest{Amount: 500, Currency: "GHS"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	remote, ok := env.paystack.Transaction(decodeData(t, rr)["reference"].(string))
+	require.True(t, ok)
+	assert.Equal(t, "GHS", remote.Currency)
+
+	other := &Wallet{UserID: uuid.New(), WalletNumber: "5555555555", Currency: "GHS"}
+	require.NoError(t, env.repo.CreateWallet(other))
+
+	rr = env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", TransferRequest{WalletNumber: other.WalletNumber, Amount: 20000, Pin: "1234"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Currency mismatch")
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(100000), w.Balance)
+}
+
+func TestCurrencyConversion(t *testing.T) {
+	env := newTestEnv(t, 1000000)
+	ghs := &Wallet{UserID: env.user.ID, WalletNumber: "1111111111", Currency: "GHS", PinHash: env.wallet.PinHash}
+	require.NoError(t, env.repo.CreateWallet(ghs))
+
+	rr := env.do(env.handler.CreateQuote, "POST", "/wallet/convert/quote", QuoteRequest{FromCurrency: "NGN", ToCurrency: "NGN", Amount: 100000}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = env.do(env.handler.CreateQuote, "POST", "/wallet/convert/quote", QuoteRequest{FromCurrency: "NGN", ToCurrency: "KES", Amount: 100000}, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the user has no KES wallet")
+
+	// 100000 kobo less a 50bps fee, converted at 0.01 less a 100bps spread
+	rr = env.do(env.handler.CreateQuote, "POST", "/wallet/convert/quote", QuoteRequest{FromCurrency: "NGN", ToCurrency: "GHS", Amount: 100000}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	quote := decodeData(t, rr)
+	assert.Equal(t, float64(500), quote["fee"])
+	assert.InDelta(t, 0.0099, quote["rate"], 1e-12)
+	assert.Equal(t, float64(985), quote["converted_amount"])
+	quoteID := quote["id"].(string)
+
+	rr = env.do(env.handler.Convert, "POST", "/wallet/convert", ConvertRequest{QuoteID: quoteID, Pin: "0000"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = env.do(env.handler.Convert, "POST", "/wallet/convert", ConvertRequest{QuoteID: quoteID, Pin: "1234"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	ngn, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(900000), ngn.Balance)
+	gh, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "GHS")
+	assert.Equal(t, int64(985), gh.Balance)
+
+	credit, err := env.repo.GetTransactionByReference("fx-" + quoteID + "-credit")
+	require.NoError(t, err)
+	assert.Equal(t, CategoryConversion, credit.Category)
+	assert.InDelta(t, 0.0099, credit.Conversion.Rate, 1e-12)
+	assert.Equal(t, int64(100), credit.Conversion.SpreadBps)
+	assert.Equal(t, int64(500), credit.Conversion.Fee)
+
+	rr = env.do(env.handler.Convert, "POST", "/wallet/convert", ConvertRequest{QuoteID: quoteID, Pin: "1234"}, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// a quote past its lock is refused
+	rr = env.do(env.handler.CreateQuote, "POST", "/wallet/convert/quote", QuoteRequest{FromCurrency: "NGN", ToCurrency: "GHS", Amount: 100000}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	expired := uuid.MustParse(decodeData(t, rr)["id"].(string))
+	env.repo.quotes[expired].ExpiresAt = time.Now().Add(-time.Second)
+
+	rr = env.do(env.handler.Convert, "POST", "/wallet/convert", ConvertRequest{QuoteID: expired.String(), Pin: "1234"}, nil)
+	assert.Equal(t, http.StatusGone, rr.Code)
+	ngn, _ = env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(900000), ngn.Balance)
+}
+
+func TestHolds(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	env.handler.Config.HoldDefaultExpiry = time.Hour
+	env.handler.Config.HoldMaxExpiry = 24 * time.Hour
+	merchant := &Wallet{UserID: uuid.New(), WalletNumber: "2222222222", Currency: "NGN"}
+	require.NoError(t, env.repo.CreateWallet(merchant))
+
+	place := HoldRequest{Amount: 60000, RecipientWalletNumber: merchant.WalletNumber, Description: "Pre-auth", Pin: "1234"}
+	rr := env.do(env.handler.PlaceHold, "POST", "/wallet/holds", place, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	holdID := decodeData(t, rr)["id"].(string)
+
+	rr = env.do(env.handler.GetWalletBalance, "GET", "/wallet/balance", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	balance := decodeData(t, rr)
+	assert.Equal(t, float64(100000), balance["balance"])
+	assert.Equal(t, float64(40000), balance["available_balance"])
+
+	// held funds cannot be spent elsewhere
+	rr = env.do(env.handler.PlaceHold, "POST", "/wallet/holds", place, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+	rr = env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{
+		Amount:        50000,
+		AccountNumber: "0001234567",
+		AccountName:   "John Doe",
+		BankCode:      "058",
+		Pin:           "1234",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = env.do(env.handler.CaptureHold, "POST", "/wallet/holds/"+holdID+"/capture", CaptureHoldRequest{Amount: 70000}, map[string]string{"id": holdID})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// a partial capture pays the merchant and frees the rest
+	rr = env.do(env.handler.CaptureHold, "POST", "/wallet/holds/"+holdID+"/capture", CaptureHoldRequest{Amount: 45000}, map[string]string{"id": holdID})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "CAPTURED", decodeData(t, rr)["status"])
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(55000), w.Balance)
+	assert.Equal(t, int64(0), w.HeldBalance)
+	m, _ := env.repo.GetWalletByID(merchant.ID.String())
+	assert.Equal(t, int64(45000), m.Balance)
+
+	rr = env.do(env.handler.ReleaseHold, "POST", "/wallet/holds/"+holdID+"/release", nil, map[string]string{"id": holdID})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	place.Amount = 20000
+	rr = env.do(env.handler.PlaceHold, "POST", "/wallet/holds", place, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	second := decodeData(t, rr)["id"].(string)
+
+	rr = env.do(env.handler.ReleaseHold, "POST", "/wallet/holds/"+second+"/release", nil, map[string]string{"id": second})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	w, _ = env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(55000), w.AvailableBalance())
+
+	// someone else's hold reads as not found
+	other := env.repo.holds[uuid.MustParse(second)]
+	other.WalletID = merchant.ID
+	rr = env.do(env.handler.GetHold, "GET", "/wallet/holds/"+second, nil, map[string]string{"id": second})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestHoldRoles(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	env.handler.Config.HoldDefaultExpiry = time.Hour
+	env.handler.Config.HoldMaxExpiry = 24 * time.Hour
+	payer := env.user
+	merchant := user.User{ID: uuid.New()}
+	merchantWallet := &Wallet{UserID: merchant.ID, WalletNumber: "2222222222", Currency: "NGN"}
+	require.NoError(t, env.repo.CreateWallet(merchantWallet))
+
+	place := HoldRequest{Amount: 40000, RecipientWalletNumber: merchantWallet.WalletNumber, Pin: "1234"}
+	rr := env.do(env.handler.PlaceHold, "POST", "/wallet/holds", place, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	first := decodeData(t, rr)["id"].(string)
+	rr = env.do(env.handler.PlaceHold, "POST", "/wallet/holds", place, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	second := decodeData(t, rr)["id"].(string)
+
+	// the recipient sees the hold and captures it but can't release it
+	env.user = merchant
+	vars := map[string]string{"id": first}
+	rr = env.do(env.handler.GetHold, "GET", "/wallet/holds/"+first, nil, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = env.do(env.handler.ReleaseHold, "POST", "/wallet/holds/"+first+"/release", nil, vars)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = env.do(env.handler.CaptureHold, "POST", "/wallet/holds/"+first+"/capture", CaptureHoldRequest{Amount: 30000}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(30000), merchantWallet.Balance)
+	assert.Equal(t, int64(70000), env.wallet.Balance)
+
+	// anyone else can't see it
+	env.user = user.User{ID: uuid.New()}
+	rr = env.do(env.handler.GetHold, "GET", "/wallet/holds/"+first, nil, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// the payer can release a hold only until it expires, the reconciler releases it after that
+	env.user = payer
+	vars = map[string]string{"id": second}
+	env.repo.holds[uuid.MustParse(second)].ExpiresAt = time.Now().Add(-time.Minute)
+	rr = env.do(env.handler.ReleaseHold, "POST", "/wallet/holds/"+second+"/release", nil, vars)
+	assert.Equal(t, http.StatusGone, rr.Code)
+
+	env.repo.holds[uuid.MustParse(second)].ExpiresAt = time.Now().Add(time.Minute)
+	rr = env.do(env.handler.ReleaseHold, "POST", "/wallet/holds/"+second+"/release", nil, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(0), env.wallet.HeldBalance)
+}
+
+func TestReverseTransfer(t *testing.T) {
+	env := newTestEnv(t, 10000)
+	sender := &Wallet{UserID: uuid.New(), WalletNumber: "3333333333", Currency: "NGN"}
+	require.NoError(t, env.repo.CreateWallet(sender))
+
+	// sender paid the test user 50000, who has since spent most of it
+	env.repo.transactions["tr-1-debit"] = &Transaction{WalletID: sender.ID, Reference: "tr-1-debit", Category: CategoryTransfer, Type: TransactionDebit, Amount: 50000, Status: TransactionSuccess}
+	env.repo.transactions["tr-1-credit"] = &Transaction{WalletID: env.wallet.ID, Reference: "tr-1-credit", Category: CategoryTransfer, Type: TransactionCredit, Amount: 50000, Status: TransactionSuccess}
+	path := "/transactions/tr-1-credit/reverse"
+	vars := map[string]string{"reference": "tr-1-credit"}
+
+	rr := env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Amount: 20000, Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Amount: 8000, Reason: "Overpaid", Pin: "1234"}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	w, _ := env.repo.GetWalletByID(env.wallet.ID.String())
+	assert.Equal(t, int64(2000), w.Balance)
+	s, _ := env.repo.GetWalletByID(sender.ID.String())
+	assert.Equal(t, int64(8000), s.Balance)
+	assert.Equal(t, TransactionReversed, env.repo.transactions["tr-1-debit"].Status)
+	assert.Equal(t, int64(8000), env.repo.transactions["tr-1-debit"].ReversedAmount)
+	assert.Contains(t, env.audit.actions(), audit.ActionTransferReversed)
+
+	rr = env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Amount: 50000, Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// the remainder can be reversed once the recipient can cover it
+	env.repo.wallets[env.wallet.ID].Balance = 42000
+	rr = env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Pin: "1234"}, vars)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	s, _ = env.repo.GetWalletByID(sender.ID.String())
+	assert.Equal(t, int64(50000), s.Balance)
+
+	rr = env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// the sender cannot pull the money back
+	env.user.ID = sender.UserID
+	rr = env.do(env.handler.ReverseTransfer, "POST", path, ReverseTransferRequest{Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestRefundDeposit(t *testing.T) {
+	env := newTestEnv(t, 50000)
+	worker := &WebhookWorker{Repo: env.repo}
+	require.NoError(t, env.repo.CreateTransaction(&Transaction{
+		WalletID:  env.wallet.ID,
+		Reference: "dep-r1",
+		Category:  CategoryDeposit,
+		Type:      TransactionCredit,
+		Amount:    50000,
+		Status:    TransactionSuccess,
+	}))
+	env.paystack.AddTransaction(paystack.Transaction{Reference: "dep-r1", Status: "success", Amount: 50000, Currency: "NGN"})
+	path := "/wallet/deposit/dep-r1/refund"
+	vars := map[string]string{"reference": "dep-r1"}
+	balance := func() int64 {
+		w, _ := env.repo.GetWalletByID(env.wallet.ID.String())
+		return w.Balance
+	}
+	handle := func(body string) {
+		t.Helper()
+		payload, err := webhook.ParsePayload([]byte(body))
+		require.NoError(t, err)
+		require.True(t, worker.handleEvent(payload.ToEvent(""), []byte(body)))
+	}
+
+	rr := env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Amount: 20000, Reason: "Wrong amount", Pin: "1234"}, vars)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	first := decodeData(t, rr)["reference"].(string)
+	assert.Equal(t, int64(30000), balance())
+	require.Len(t, env.paystack.Refunds(), 1)
+	assert.Equal(t, int64(20000), env.paystack.Refunds()[0].Amount)
+
+	rr = env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Amount: 40000, Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// refund.processed settles the refund without debiting the wallet again
+	handle(`{"event":"refund.processed","data":{"id":` + strings.TrimPrefix(first, "rfd-") + `,"transaction_reference":"dep-r1","amount":"20000","status":"processed"}}`)
+	assert.Equal(t, TransactionSuccess, env.repo.transactions[first].Status)
+	assert.Equal(t, "dep-r1", *env.repo.transactions[first].RefundOf)
+	assert.Equal(t, int64(30000), balance())
+
+	// paystack refusing the refund gives the money straight back
+	client := env.handler.Paystack
+	env.handler.Paystack = refusedRefunds{client}
+	rr = env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Equal(t, int64(30000), balance())
+	env.handler.Paystack = client
+
+	rr = env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Pin: "1234"}, vars)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	second := decodeData(t, rr)["reference"].(string)
+	assert.Equal(t, float64(30000), decodeData(t, rr)["amount"])
+	assert.Equal(t, int64(0), balance())
+
+	handle(`{"event":"refund.failed","data":{"id":` + strings.TrimPrefix(second, "rfd-") + `,"transaction_reference":"dep-r1","amount":"30000","status":"failed"}}`)
+	assert.Equal(t, TransactionFailed, env.repo.transactions[second].Status)
+	assert.Equal(t, int64(30000), balance())
+
+	rr = env.do(env.handler.RefundDeposit, "POST", "/wallet/deposit/VA-123/refund", RefundDepositRequest{Pin: "1234"}, map[string]string{"reference": "VA-123"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// refusedRefunds has paystack turn every refund down
+type refusedRefunds struct {
+	paystack.Client
+}
+
+func (f refusedRefunds) CreateRefund(ctx context.Context, req paystack.RefundRequest) (*paystack.Refund, error) {
+	return nil, &paystack.APIError{StatusCode: http.StatusBadRequest, Message: "Transaction has been fully reversed"}
+}
+
+func TestRefundDepositLeftPendingWhenOutcomeUnknown(t *testing.T) {
+	env := newTestEnv(t, 50000)
+	worker := &WebhookWorker{Repo: env.repo}
+	require.NoError(t, env.repo.CreateTransaction(&Transaction{
+		WalletID:  env.wallet.ID,
+		Reference: "dep-r3",
+		Category:  CategoryDeposit,
+		Type:      TransactionCredit,
+		Amount:    50000,
+		Status:    TransactionSuccess,
+	}))
+	env.paystack.AddTransaction(paystack.Transaction{Reference: "dep-r3", Status: "success", Amount: 50000, Currency: "NGN"})
+
+	// a 5xx doesn't say whether paystack took the refund, so the wallet stays debited
+	env.paystack.FailNext(1)
+	rr := env.do(env.handler.RefundDeposit, "POST", "/wallet/deposit/dep-r3/refund", RefundDepositRequest{Amount: 20000, Pin: "1234"}, map[string]string{"reference": "dep-r3"})
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	reference := decodeData(t, rr)["reference"].(string)
+	assert.Equal(t, TransactionPending, env.repo.transactions[reference].Status)
+	assert.Equal(t, int64(30000), env.wallet.Balance)
+
+	// refund.processed finds the pending refund by deposit and amount and settles it
+	body := `{"event":"refund.processed","data":{"id":77,"transaction_reference":"dep-r3","amount":"20000","status":"processed"}}`
+	payload, err := webhook.ParsePayload([]byte(body))
+	require.NoError(t, err)
+	require.True(t, worker.handleEvent(payload.ToEvent(""), []byte(body)))
+	assert.Equal(t, TransactionSuccess, env.repo.transactions["rfd-77"].Status)
+	assert.Equal(t, int64(30000), env.wallet.Balance)
+}
+
+func TestRefundDepositKeepsFee(t *testing.T) {
+	env := newTestEnv(t, 49000)
+	require.NoError(t, env.repo.CreateTransaction(&Transaction{
+		WalletID:  env.wallet.ID,
+		Reference: "dep-r2",
+		Category:  CategoryDeposit,
+		Type:      TransactionCredit,
+		Amount:    50000,
+		Fee:       1000,
+		Status:    TransactionSuccess,
+	}))
+	env.paystack.AddTransaction(paystack.Transaction{Reference: "dep-r2", Status: "success", Amount: 50000, Currency: "NGN"})
+	path := "/wallet/deposit/dep-r2/refund"
+	vars := map[string]string{"reference": "dep-r2"}
+
+	// the wallet was only credited the deposit less its fee, that is all it can send back
+	rr := env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Amount: 50000, Pin: "1234"}, vars)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = env.do(env.handler.RefundDeposit, "POST", path, RefundDepositRequest{Pin: "1234"}, vars)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.Equal(t, float64(49000), decodeData(t, rr)["amount"])
+	require.Len(t, env.paystack.Refunds(), 1)
+	assert.Equal(t, int64(49000), env.paystack.Refunds()[0].Amount)
+	assert.Equal(t, int64(0), env.wallet.Balance)
+}
+
+func TestFees(t *testing.T) {
+	env := newTestEnv(t, 100000)
+	recipient := &Wallet{UserID: uuid.New(), WalletNumber: "4444444444", Currency: "NGN"}
+	require.NoError(t, env.repo.CreateWallet(recipient))
+
+	general := env.fees.add(fee.Schedule{Name: "Transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindPercentage, Flat: 100, Bps: 100, MaxFee: 1000})
+	env.fees.add(fee.Schedule{Name: "Gold transfers", Category: fee.CategoryTransfer, Currency: "NGN", Kind: fee.KindFlat, UserTier: "GOLD"})
+	env.fees.add(fee.Schedule{Name: "Withdrawals", Category: fee.CategoryWithdrawal, Currency: "NGN", Kind: fee.KindFlat, Flat: 5000})
+
+	rr := env.do(env.handler.QuoteFee, "POST", "/wallet/fees/quote", FeeQuoteRequest{Category: fee.CategoryTransfer, Amount: 20000}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	quote := decodeData(t, rr)
+	assert.Equal(t, float64(300), quote["fee"])
+	assert.Equal(t, float64(20300), quote["debited"])
+	assert.Equal(t, float64(20000), quote["credited"])
+	assert.Equal(t, general.String(), quote["fee_schedule_id"])
+
+	rr = env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", TransferRequest{WalletNumber: "4444444444", Amount: 20000, Pin: "1234"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(79700), env.wallet.Balance)
+	assert.Equal(t, int64(20000), recipient.Balance)
+
+	// the fee is on top, so it has to be covered along with the amount
+	rr = env.do(env.handler.TransferFunds, "POST", "/wallet/transfer", TransferRequest{WalletNumber: "4444444444", Amount: 79000, Pin: "1234"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// a schedule for the user's tier beats the general one
+	env.user.Tier = "GOLD"
+	rr = env.do(env.handler.QuoteFee, "POST", "/wallet/fees/quote", FeeQuoteRequest{Category: fee.CategoryTransfer, Amount: 20000}, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, float64(0), decodeData(t, rr)["fee"])
+
+	env.paystack.AddAccount("0001234567", "058", "JOHN DOE")
+	rr = env.do(env.handler.Withdraw, "POST", "/wallet/withdraw", WithdrawRequest{Amount: 40000, AccountNumber: "0001234567", AccountName: "John Doe", BankCode: "058", Pin: "1234"}, nil)
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.Equal(t, float64(5000), decodeData(t, rr)["fee"])
+	assert.Equal(t, int64(34700), env.wallet.Balance)
+
+	// only the amount goes out to the bank
+	reference := decodeData(t, rr)["reference"].(string)
+	transfer, ok := env.paystack.Transfer(reference)
+	require.True(t, ok)
+	assert.Equal(t, int64(40000), transfer.Amount)
+
+	// a failed withdrawal refunds the fee with the amount
+	require.NoError(t, env.repo.ReverseWithdrawal(reference))
+	assert.Equal(t, int64(79700), env.wallet.Balance)
+
+	rr = env.do(env.handler.QuoteFee, "POST", "/wallet/fees/quote", FeeQuoteRequest{Category: "REFUND", Amount: 20000}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+type HoldRequest struct {
+	Amount                int64  `json:"amount"`
+	Currency              string `json:"currency"`
+	RecipientWalletNumber string `json:"recipient_wallet_number"`
+	Description           string `json:"description"`
+	// ExpiresIn is in seconds, HoldDefaultExpiry applies when it is left out
+	ExpiresIn int64  `json:"expires_in"`
+	Pin       string `json:"pin"`
+}
+
+// PlaceHold reserves funds for a later payment to another wallet, they stay in the balance but cannot be spent
+func (h *Handler) PlaceHold(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req HoldRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok || !h.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	expiresIn := h.Config.HoldDefaultExpiry
+	if req.ExpiresIn != 0 {
+		expiresIn = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiresIn <= 0 || expiresIn > h.Config.HoldMaxExpiry {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("expires_in must be between 1 and %d seconds", int64(h.Config.HoldMaxExpiry.Seconds())), nil)
+		return
+	}
+
+	if req.RecipientWalletNumber == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "recipient_wallet_number is required", nil)
+		return
+	}
+
+	wallet, ok := h.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	if !h.VerifyPin(w, wallet, req.Pin) {
+		return
+	}
+
+	recipient, err := h.Repo.GetWalletByNumber(req.RecipientWalletNumber)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Recipient wallet not found", nil)
+		return
+	}
+	if recipient.ID == wallet.ID {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Cannot hold funds for the same wallet", nil)
+		return
+	}
+	if recipient.Currency != wallet.Currency {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Currency mismatch, recipient wallet holds %s not %s", recipient.Currency, wallet.Currency), nil)
+		return
+	}
+
+	hold := Hold{
+		WalletID:          wallet.ID,
+		RecipientWalletID: &recipient.ID,
+		Reference:         fmt.Sprintf("hold-%d", time.Now().UnixNano()),
+		Amount:            req.Amount,
+		Description:       req.Description,
+		ExpiresAt:         time.Now().Add(expiresIn),
+	}
+
+	if err := h.Repo.PlaceHold(&hold); err != nil {
+		if err.Error() == "insufficient balance" {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient available balance", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to place hold", nil)
+		}
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusCreated, "Hold placed", hold)
+}
+
+func (h *Handler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	wallet, ok := h.QueryWallet(w, r, usr)
+	if !ok {
+		return
+	}
+
+	limit, offset, page := utils.GetPaginationDetails(r)
+	holds, err := h.Repo.ListHolds(wallet.ID.String(), HoldStatus(r.URL.Query().Get("status")), limit, offset)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to fetch holds", nil)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Holds", map[string]interface{}{
+		"holds": holds,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"limit":        limit,
+		},
+	})
+}
+
+func (h *Handler) GetHold(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	hold, _, ok := h.holdFor(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Hold", hold)
+}
+
+type CaptureHoldRequest struct {
+	// Amount of zero captures the whole hold
+	Amount int64 `json:"amount"`
+}
+
+// CaptureHold pays all or part of a hold to its recipient, a partial capture releases the rest. The recipient
+// captures what it is owed, the payer can also capture to pay it.
+func (h *Handler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req CaptureHoldRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	hold, _, ok := h.holdFor(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount < 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount", nil)
+		return
+	}
+
+	captured, err := h.Repo.CaptureHold(hold.ID.String(), amount, time.Now())
+	if err != nil {
+		WriteHoldError(w, err, hold)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Hold captured", captured)
+}
+
+// ReleaseHold lets the payer give up a hold before it expires, after that the reconciler releases it. The
+// recipient can't release a hold, an admin can release any hold.
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	hold, payer, ok := h.holdFor(w, usr, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if !payer {
+		utils.BuildErrorResponse(w, http.StatusForbidden, "Only the payer can release a hold", nil)
+		return
+	}
+	if hold.Status == HoldActive && time.Now().After(hold.ExpiresAt) {
+		utils.BuildErrorResponse(w, http.StatusGone, "Hold has expired", nil)
+		return
+	}
+
+	released, err := h.Repo.ReleaseHold(hold.ID.String(), time.Now())
+	if err != nil {
+		WriteHoldError(w, err, hold)
+		return
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusOK, "Hold released", released)
+}
+
+// holdFor loads a hold the user pays or receives and reports whether they are its payer, anyone else's hold
+// reads as not found
+func (h *Handler) holdFor(w http.ResponseWriter, usr user.User, holdID string) (*Hold, bool, bool) {
+	hold, err := h.Repo.GetHold(holdID)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Hold not found", nil)
+		return nil, false, false
+	}
+
+	if wallet, err := h.Repo.GetWalletByID(hold.WalletID.String()); err == nil && wallet.UserID == usr.ID {
+		return hold, true, true
+	}
+	if hold.RecipientWalletID != nil {
+		if recipient, err := h.Repo.GetWalletByID(hold.RecipientWalletID.String()); err == nil && recipient.UserID == usr.ID {
+			return hold, false, true
+		}
+	}
+
+	utils.BuildErrorResponse(w, http.StatusNotFound, "Hold not found", nil)
+	return nil, false, false
+}
+
+// WriteHoldError maps the hold repository's errors onto responses, the admin release shares it
+func WriteHoldError(w http.ResponseWriter, err error, hold *Hold) {
+	switch {
+	case errors.Is(err, ErrHoldNotActive):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Hold is no longer active", nil)
+	case errors.Is(err, ErrHoldExpired):
+		utils.BuildErrorResponse(w, http.StatusGone, "Hold has expired", nil)
+	case errors.Is(err, ErrCaptureTooLarge):
+		utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Capture can't exceed the held %d", hold.Amount), nil)
+	default:
+		logger.Error("Hold operation failed", logger.Fields{"error": err.Error(), "hold_id": hold.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Hold operation failed", nil)
+	}
+}
@@ -7,14 +7,55 @@ import (
 )
 
 type Wallet struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	UserID       uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	WalletNumber string    `gorm:"uniqueIndex;not null" json:"wallet_number"`
-	Balance      int64     `gorm:"not null;default:0" json:"balance"`
-	Currency     string    `gorm:"not null;default:NGN" json:"currency"`
-	PinHash      string    `gorm:"not null" json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                   uuid.UUID        `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID               uuid.UUID        `gorm:"type:uuid;not null" json:"user_id"`
+	WalletNumber         string           `gorm:"uniqueIndex;not null" json:"wallet_number"`
+	Balance              int64            `gorm:"not null;default:0" json:"balance"`
+	HeldBalance          int64            `gorm:"not null;default:0" json:"held_balance"`
+	PocketBalance        int64            `gorm:"not null;default:0" json:"pocket_balance"`
+	Currency             string           `gorm:"not null;default:NGN" json:"currency"`
+	PinHash              string           `gorm:"not null" json:"-"`
+	FailedPinAttempts    int              `gorm:"not null;default:0" json:"-"`
+	PinLockedUntil       *time.Time       `json:"pin_locked_until,omitempty"`
+	PinChangedAt         *time.Time       `json:"-"`
+	PaystackCustomerCode string           `json:"-"`
+	DedicatedAccount     DedicatedAccount `gorm:"embedded;embeddedPrefix:dedicated_" json:"dedicated_account,omitzero"`
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
+}
+
+type DedicatedAccountStatus string
+
+const (
+	DedicatedAccountPending DedicatedAccountStatus = "PENDING"
+	DedicatedAccountActive  DedicatedAccountStatus = "ACTIVE"
+	DedicatedAccountFailed  DedicatedAccountStatus = "FAILED"
+)
+
+// DedicatedAccount is the Paystack virtual bank account that funds the wallet by bank transfer
+type DedicatedAccount struct {
+	Status        DedicatedAccountStatus `json:"status"`
+	AccountNumber string                 `json:"account_number,omitempty"`
+	AccountName   string                 `json:"account_name,omitempty"`
+	BankName      string                 `json:"bank_name,omitempty"`
+	BankSlug      string                 `json:"bank_slug,omitempty"`
+	PaystackID    int64                  `json:"-"`
+}
+
+// AvailableBalance is what the wallet can spend, Balance is the ledger balance and still includes held funds
+// and the money put aside in pockets
+func (w *Wallet) AvailableBalance() int64 {
+	return w.Balance - w.HeldBalance - w.PocketBalance
+}
+
+// MainBalance is the wallet's balance outside its pockets, held funds included
+func (w *Wallet) MainBalance() int64 {
+	return w.Balance - w.PocketBalance
+}
+
+// PinLocked reports whether too many failed PIN attempts have locked the wallet at now
+func (w *Wallet) PinLocked(now time.Time) bool {
+	return w.PinLockedUntil != nil && now.Before(*w.PinLockedUntil)
 }
 
 type TransactionCategory string
@@ -23,6 +64,12 @@ const (
 	CategoryDeposit    TransactionCategory = "DEPOSIT"
 	CategoryWithdrawal TransactionCategory = "WITHDRAWAL"
 	CategoryTransfer   TransactionCategory = "TRANSFER"
+	CategoryRefund     TransactionCategory = "REFUND"
+	CategoryConversion TransactionCategory = "CONVERSION"
+	CategoryEscrow     TransactionCategory = "ESCROW"
+	// CategoryPocket moves money between a wallet's main balance and one of its pockets, the wallet's Balance
+	// does not change so nothing is posted to the ledger
+	CategoryPocket TransactionCategory = "POCKET"
 )
 
 type TransactionType string
@@ -38,6 +85,13 @@ const (
 	TransactionPending TransactionStatus = "PENDING"
 	TransactionSuccess TransactionStatus = "SUCCESS"
 	TransactionFailed  TransactionStatus = "FAILED"
+	// TransactionExpired marks a deposit that was never paid, a late charge.success still settles it
+	TransactionExpired TransactionStatus = "EXPIRED"
+	// TransactionReview holds a deposit whose payment did not match what was asked for, it is never credited automatically
+	TransactionReview TransactionStatus = "REVIEW"
+	// TransactionReversed marks both legs of a transfer that was sent back in whole or in part, ReversedAmount
+	// says how much and the compensating legs point back through ReversalOf
+	TransactionReversed TransactionStatus = "REVERSED"
 )
 
 type Transaction struct {
@@ -51,6 +105,106 @@ type Transaction struct {
 	SenderWalletNumber    *string             `json:"sender_wallet_number,omitempty"`
 	RecipientWalletNumber *string             `json:"recipient_wallet_number,omitempty"`
 	Description           string              `json:"description"`
-	CreatedAt             time.Time           `json:"created_at"`
-	UpdatedAt             time.Time           `json:"updated_at"`
+	ReviewReason          string              `json:"review_reason,omitempty"`
+	ReversedAmount        int64               `gorm:"not null;default:0" json:"reversed_amount,omitempty"`
+	ReversalOf            *string             `json:"reversal_of,omitempty"`
+	RefundOf              *string             `json:"refund_of,omitempty"`
+	Conversion            Conversion          `gorm:"embedded;embeddedPrefix:fx_" json:"conversion,omitzero"`
+	Escrow                EscrowLeg           `gorm:"embedded;embeddedPrefix:escrow_" json:"escrow,omitzero"`
+	PocketID              *uuid.UUID          `gorm:"type:uuid" json:"pocket_id,omitempty"`
+	// Fee is charged on top of Amount for transfers and withdrawals and taken out of it for deposits,
+	// FeeScheduleID is the schedule that priced it
+	Fee           int64      `gorm:"not null;default:0" json:"fee,omitempty"`
+	FeeScheduleID *uuid.UUID `gorm:"type:uuid" json:"fee_schedule_id,omitempty"`
+	// CheckedAt is when the reconciler last asked paystack about a pending transaction
+	CheckedAt *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Conversion is carried by both legs of a currency conversion, Fee is in FromCurrency
+type Conversion struct {
+	QuoteID      *uuid.UUID `gorm:"type:uuid" json:"quote_id,omitempty"`
+	FromCurrency string     `json:"from_currency,omitempty"`
+	ToCurrency   string     `json:"to_currency,omitempty"`
+	Rate         float64    `json:"rate,omitempty"`
+	MarketRate   float64    `json:"market_rate,omitempty"`
+	SpreadBps    int64      `json:"spread_bps,omitempty"`
+	Fee          int64      `json:"fee,omitempty"`
+}
+
+// EscrowLeg is carried by the legs an escrow writes on both wallets at every state change. The leg's Amount
+// is what moved in or out of its wallet at that change, 0 when nothing did, and Amount here is the escrow's.
+type EscrowLeg struct {
+	ID     *uuid.UUID `gorm:"type:uuid" json:"id,omitempty"`
+	Status string     `json:"status,omitempty"`
+	Amount int64      `json:"amount,omitempty"`
+}
+
+// Quote locks a conversion rate between two of a user's wallets until ExpiresAt. Amount is debited from the
+// source wallet, Fee is kept out of it and the rest converts at Rate into ConvertedAmount.
+type Quote struct {
+	ID              uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	FromWalletID    uuid.UUID  `gorm:"type:uuid;not null" json:"from_wallet_id"`
+	ToWalletID      uuid.UUID  `gorm:"type:uuid;not null" json:"to_wallet_id"`
+	FromCurrency    string     `gorm:"not null" json:"from_currency"`
+	ToCurrency      string     `gorm:"not null" json:"to_currency"`
+	Amount          int64      `gorm:"not null" json:"amount"`
+	Fee             int64      `gorm:"not null;default:0" json:"fee"`
+	ConvertedAmount int64      `gorm:"not null" json:"converted_amount"`
+	MarketRate      float64    `gorm:"not null" json:"market_rate"`
+	Rate            float64    `gorm:"not null" json:"rate"`
+	SpreadBps       int64      `gorm:"not null;default:0" json:"spread_bps"`
+	ExpiresAt       time.Time  `gorm:"not null" json:"expires_at"`
+	ExecutedAt      *time.Time `json:"executed_at,omitempty"`
+	Reference       string     `json:"reference,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (Quote) TableName() string { return "fx_quotes" }
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldReleased HoldStatus = "RELEASED"
+	HoldExpired  HoldStatus = "EXPIRED"
+)
+
+// Hold reserves part of a wallet's balance until it is captured, released or expires. The wallet's
+// HeldBalance is the sum of its active holds, captures pay into RecipientWalletID.
+type Hold struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	WalletID          uuid.UUID  `gorm:"type:uuid;not null" json:"wallet_id"`
+	RecipientWalletID *uuid.UUID `gorm:"type:uuid" json:"recipient_wallet_id,omitempty"`
+	Reference         string     `gorm:"uniqueIndex;not null" json:"reference"`
+	Amount            int64      `gorm:"not null" json:"amount"`
+	CapturedAmount    int64      `gorm:"not null;default:0" json:"captured_amount"`
+	Status            HoldStatus `gorm:"not null" json:"status"`
+	Description       string     `json:"description"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	CapturedAt        *time.Time `json:"captured_at,omitempty"`
+	ReleasedAt        *time.Time `json:"released_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Dispute is a chargeback raised against a deposit, it is kept in step with paystack's charge.dispute events
+type Dispute struct {
+	ID                   uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	PaystackID           int64      `gorm:"uniqueIndex;not null" json:"paystack_id"`
+	WalletID             *uuid.UUID `gorm:"type:uuid" json:"wallet_id,omitempty"`
+	TransactionReference string     `gorm:"index" json:"transaction_reference"`
+	Amount               int64      `gorm:"not null" json:"amount"`
+	RefundAmount         int64      `gorm:"not null;default:0" json:"refund_amount"`
+	Currency             string     `json:"currency"`
+	Status               string     `gorm:"not null" json:"status"`
+	Category             string     `json:"category"`
+	Resolution           string     `json:"resolution"`
+	DueAt                *time.Time `json:"due_at,omitempty"`
+	ResolvedAt           *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,183 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ChangePinRequest struct {
+	CurrentPin string `json:"current_pin"`
+	NewPin     string `json:"new_pin"`
+}
+
+func (h *Handler) ChangePin(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req ChangePinRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !validPin(req.NewPin) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "PIN must be 4 digits", nil)
+		return
+	}
+
+	wallet, ok := h.pinWallet(w, usr)
+	if !ok {
+		return
+	}
+
+	if !h.VerifyPin(w, wallet, req.CurrentPin) {
+		return
+	}
+
+	if req.CurrentPin == req.NewPin {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "New PIN must be different from the current PIN", nil)
+		return
+	}
+
+	if !h.setPin(w, wallet, req.NewPin) {
+		return
+	}
+
+	h.recordAudit(wallet, audit.ActionPinChanged, "PIN changed")
+	utils.BuildSuccessResponse(w, http.StatusOK, "PIN changed", nil)
+}
+
+type ResetPinRequest struct {
+	NewPin string `json:"new_pin"`
+}
+
+// ResetPin sets a new PIN without the old one. The route only admits a freshly signed-in session,
+// so the Google login stands in as identity verification, and it also lifts any lockout.
+func (h *Handler) ResetPin(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req ResetPinRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !validPin(req.NewPin) {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "PIN must be 4 digits", nil)
+		return
+	}
+
+	wallet, ok := h.pinWallet(w, usr)
+	if !ok {
+		return
+	}
+
+	if !h.setPin(w, wallet, req.NewPin) {
+		return
+	}
+
+	h.recordAudit(wallet, audit.ActionPinReset, "PIN reset after re-authentication")
+	utils.BuildSuccessResponse(w, http.StatusOK, "PIN reset", nil)
+}
+
+// VerifyPin checks pin against the wallet, counting failures towards a lockout, and writes the
+// error response itself when the check does not pass. The attempt is counted before the PIN is compared,
+// so the lockout holds against parallel guesses and not just the wallet as it was loaded.
+func (h *Handler) VerifyPin(w http.ResponseWriter, wallet *Wallet, pin string) bool {
+	claimed, err := h.Repo.ClaimPinAttempt(wallet.ID.String(), h.Config.PinMaxAttempts, time.Now())
+	if errors.Is(err, ErrPinLocked) {
+		utils.BuildErrorResponse(w, http.StatusLocked, "Wallet is locked after too many failed PIN attempts", map[string]interface{}{
+			"locked_until": claimed.PinLockedUntil,
+		})
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to claim PIN attempt", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to verify PIN", nil)
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(claimed.PinHash), []byte(pin)); err == nil {
+		if err := h.Repo.ResetPinAttempts(wallet.ID.String()); err != nil {
+			logger.Warn("Failed to reset PIN attempts", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+		}
+		return true
+	}
+
+	updated, locked, err := h.Repo.RecordFailedPinAttempt(wallet.ID.String(), h.Config.PinMaxAttempts, h.Config.PinLockDuration)
+	if err != nil {
+		logger.Error("Failed to record PIN attempt", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+		utils.BuildErrorResponse(w, http.StatusUnauthorized, "Invalid PIN", nil)
+		return false
+	}
+
+	if locked {
+		h.recordAudit(wallet, audit.ActionPinFailedAttempt, fmt.Sprintf("failed attempt %d of %d", h.Config.PinMaxAttempts, h.Config.PinMaxAttempts))
+		h.recordAudit(wallet, audit.ActionPinLocked, "locked until "+updated.PinLockedUntil.Format(time.RFC3339))
+		utils.BuildErrorResponse(w, http.StatusLocked, "Too many failed PIN attempts, wallet is locked", map[string]interface{}{
+			"locked_until": updated.PinLockedUntil,
+		})
+		return false
+	}
+
+	h.recordAudit(wallet, audit.ActionPinFailedAttempt, fmt.Sprintf("failed attempt %d of %d", updated.FailedPinAttempts, h.Config.PinMaxAttempts))
+	utils.BuildErrorResponse(w, http.StatusUnauthorized, "Invalid PIN", map[string]interface{}{
+		"attempts_remaining": h.Config.PinMaxAttempts - updated.FailedPinAttempts,
+	})
+	return false
+}
+
+// pinWallet picks the wallet PIN changes go through, the repository applies them to all of the user's wallets
+func (h *Handler) pinWallet(w http.ResponseWriter, usr user.User) (*Wallet, bool) {
+	wallets, err := h.Repo.GetWalletsByUserID(usr.ID.String())
+	if err != nil || len(wallets) == 0 {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Wallet not found", nil)
+		return nil, false
+	}
+	return &wallets[0], true
+}
+
+func (h *Handler) setPin(w http.ResponseWriter, wallet *Wallet, pin string) bool {
+	hashedPin, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to secure PIN", nil)
+		return false
+	}
+
+	if err := h.Repo.UpdatePin(wallet.ID.String(), string(hashedPin)); err != nil {
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Failed to update PIN", nil)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) recordAudit(wallet *Wallet, action audit.Action, details string) {
+	entry := audit.Log{
+		UserID:   &wallet.UserID,
+		WalletID: &wallet.ID,
+		Action:   action,
+		Details:  details,
+	}
+	if err := h.Audit.Record(&entry); err != nil {
+		logger.Error("Failed to record audit log", logger.Fields{"error": err.Error(), "action": string(action), "wallet_id": wallet.ID.String()})
+	}
+}
+
+func validPin(pin string) bool {
+	if len(pin) != 4 {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+)
+
+const reconcileBatchSize = 100
+
+// DepositReconciler settles deposits and withdrawals whose webhook never arrived by asking Paystack directly,
+// each sweep also releases holds past their expiry
+type DepositReconciler struct {
+	Config   config.Config
+	Repo     Repository
+	Paystack paystack.Client
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type ReconcileResult struct {
+	Checked  int
+	Credited int
+	Failed   int
+	Expired  int
+	Review   int
+}
+
+func NewDepositReconciler(cfg config.Config, repo Repository, paystackClient paystack.Client) *DepositReconciler {
+	return &DepositReconciler{Config: cfg, Repo: repo, Paystack: paystackClient, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (d *DepositReconciler) Start() {
+	logger.Info("Starting deposit reconciler...", logger.Fields{"interval": d.Config.ReconcileInterval.String()})
+	go d.run()
+}
+
+// Stop waits for a sweep in progress to finish
+func (d *DepositReconciler) Stop(ctx context.Context) error {
+	close(d.stop)
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *DepositReconciler) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.Config.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := d.Reconcile(context.Background())
+		if err != nil {
+			logger.Error("DepositReconciler: Sweep failed", logger.Fields{"error": err.Error()})
+		} else if result.Checked > 0 {
+			logger.Info("DepositReconciler: Sweep finished", logger.Fields{
+				"checked":  result.Checked,
+				"credited": result.Credited,
+				"failed":   result.Failed,
+				"expired":  result.Expired,
+				"review":   result.Review,
+			})
+		}
+
+		if settled, err := d.ReconcileWithdrawals(context.Background()); err != nil {
+			logger.Error("DepositReconciler: Withdrawal sweep failed", logger.Fields{"error": err.Error()})
+		} else if settled > 0 {
+			logger.Info("DepositReconciler: Settled pending withdrawals", logger.Fields{"count": settled})
+		}
+
+		if expired, err := d.Repo.ExpireHolds(time.Now(), reconcileBatchSize); err != nil {
+			logger.Error("DepositReconciler: Failed to expire holds", logger.Fields{"error": err.Error()})
+		} else if expired > 0 {
+			logger.Info("DepositReconciler: Expired holds", logger.Fields{"count": expired})
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile verifies one batch of pending deposits older than ReconcileStaleAfter
+func (d *DepositReconciler) Reconcile(ctx context.Context) (*ReconcileResult, error) {
+	now := time.Now()
+	txs, err := d.Repo.GetStalePendingDeposits(now.Add(-d.Config.ReconcileStaleAfter), reconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	for _, tx := range txs {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Checked++
+		d.reconcileDeposit(ctx, tx, now, result)
+	}
+
+	return result, nil
+}
+
+func (d *DepositReconciler) reconcileDeposit(ctx context.Context, tx Transaction, now time.Time, result *ReconcileResult) {
+	expired := now.Sub(tx.CreatedAt) > d.Config.DepositExpiry
+
+	remote, err := d.Paystack.VerifyTransaction(ctx, tx.Reference)
+	if err != nil {
+		// paystack has no record when initialization never completed, nothing can settle it
+		if paystack.IsNotFound(err) && expired {
+			d.expire(tx, result)
+			return
+		}
+		logger.Warn("DepositReconciler: Failed to verify deposit", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+		return
+	}
+
+	switch remote.Status {
+	case "success":
+		status, err := settleDeposit(ctx, d.Repo, d.Paystack, &tx, reportFromVerify(remote), false)
+		if err != nil {
+			logger.Error("DepositReconciler: Failed to credit deposit", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+			return
+		}
+		if status == TransactionReview {
+			result.Review++
+			return
+		}
+		result.Credited++
+
+	case "failed", "reversed":
+		if err := d.Repo.ProcessFailedTransaction(tx.Reference); err != nil {
+			logger.Error("DepositReconciler: Failed to fail deposit", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+			return
+		}
+		result.Failed++
+
+	default:
+		// abandoned or still in progress, give the customer until DepositExpiry to finish paying
+		if expired {
+			d.expire(tx, result)
+		}
+	}
+}
+
+func (d *DepositReconciler) expire(tx Transaction, result *ReconcileResult) {
+	if err := d.Repo.ExpireDeposit(tx.Reference); err != nil {
+		logger.Error("DepositReconciler: Failed to expire deposit", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+		return
+	}
+	result.Expired++
+}
+
+// ReconcileWithdrawals settles one batch of pending withdrawals older than ReconcileStaleAfter, these are the
+// ones whose transfer outcome was unknown when they were made and whose webhook never came
+func (d *DepositReconciler) ReconcileWithdrawals(ctx context.Context) (int, error) {
+	txs, err := d.Repo.GetStalePendingWithdrawals(time.Now().Add(-d.Config.ReconcileStaleAfter), reconcileBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, tx := range txs {
+		if ctx.Err() != nil {
+			return settled, ctx.Err()
+		}
+
+		remote, err := d.Paystack.VerifyTransfer(ctx, tx.Reference)
+		switch {
+		case paystack.IsNotFound(err):
+			// the transfer never reached paystack, so nothing was paid out
+			err = d.Repo.ReverseWithdrawal(tx.Reference)
+		case err != nil:
+			logger.Warn("DepositReconciler: Failed to verify withdrawal", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+			continue
+		case remote.Status == "success":
+			err = d.Repo.CompleteWithdrawal(tx.Reference)
+		case remote.Status == "failed" || remote.Status == "reversed":
+			err = d.Repo.ReverseWithdrawal(tx.Reference)
+		default:
+			// still being processed by paystack
+			continue
+		}
+
+		if err != nil {
+			logger.Error("DepositReconciler: Failed to settle withdrawal", logger.Fields{"error": err.Error(), "reference": tx.Reference})
+			continue
+		}
+		settled++
+	}
+
+	return settled, nil
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+)
+
+func TestDepositReconciler(t *testing.T) {
+	env := newTestEnv(t, 0)
+	env.handler.Config.ReconcileStaleAfter = 10 * time.Minute
+	env.handler.Config.DepositExpiry = 24 * time.Hour
+
+	deposits := []struct {
+		reference    string
+		age          time.Duration
+		remoteStatus string
+		remoteAmount int64
+		wantStatus   TransactionStatus
+	}{
+		{"dep-paid", time.Hour, "success", 50000, TransactionSuccess},
+		{"dep-underpaid", time.Hour, "success", 5000, TransactionReview},
+		{"dep-declined", time.Hour, "failed", 50000, TransactionFailed},
+		{"dep-abandoned", 48 * time.Hour, "abandoned", 50000, TransactionExpired},
+		{"dep-in-progress", 20 * time.Minute, "abandoned", 50000, TransactionPending},
+		{"dep-unknown", 48 * time.Hour, "", 0, TransactionExpired},
+		{"dep-fresh", time.Minute, "success", 50000, TransactionPending},
+	}
+
+	for _, d := range deposits {
+		require.NoError(t, env.repo.CreateTransaction(&Transaction{
+			WalletID:  env.wallet.ID,
+			Reference: d.reference,
+			Category:  CategoryDeposit,
+			Type:      TransactionCredit,
+			Amount:    50000,
+			Status:    TransactionPending,
+			CreatedAt: time.Now().Add(-d.age),
+		}))
+		if d.remoteStatus != "" {
+			env.paystack.AddTransaction(paystack.Transaction{
+				Reference: d.reference,
+				Status:    d.remoteStatus,
+				Amount:    d.remoteAmount,
+				Currency:  "NGN",
+				Metadata:  map[string]interface{}{"wallet_id": env.wallet.ID.String()},
+			})
+		}
+	}
+
+	reconciler := NewDepositReconciler(env.handler.Config, env.repo, env.handler.Paystack)
+	result, err := reconciler.Reconcile(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, &ReconcileResult{Checked: 6, Credited: 1, Failed: 1, Expired: 2, Review: 1}, result)
+	for _, d := range deposits {
+		tx, err := env.repo.GetTransactionByReference(d.reference)
+		require.NoError(t, err)
+		assert.Equal(t, d.wantStatus, tx.Status, d.reference)
+	}
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, int64(50000), w.Balance)
+}
+
+func TestDepositReconcilerMovesOnFromUnsettledDeposits(t *testing.T) {
+	env := newTestEnv(t, 0)
+	env.handler.Config.ReconcileStaleAfter = 10 * time.Minute
+	env.handler.Config.DepositExpiry = 24 * time.Hour
+
+	// a full batch of deposits paystack still has in progress, and one newer than all of them
+	for i := 0; i <= reconcileBatchSize; i++ {
+		reference := fmt.Sprintf("dep-wait-%d", i)
+		require.NoError(t, env.repo.CreateTransaction(&Transaction{
+			WalletID:  env.wallet.ID,
+			Reference: reference,
+			Category:  CategoryDeposit,
+			Type:      TransactionCredit,
+			Amount:    50000,
+			Status:    TransactionPending,
+			CreatedAt: time.Now().Add(-2*time.Hour + time.Duration(i)*time.Second),
+		}))
+		env.paystack.AddTransaction(paystack.Transaction{Reference: reference, Status: "ongoing", Amount: 50000, Currency: "NGN"})
+	}
+	newest := fmt.Sprintf("dep-wait-%d", reconcileBatchSize)
+
+	reconciler := NewDepositReconciler(env.handler.Config, env.repo, env.handler.Paystack)
+	result, err := reconciler.Reconcile(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, reconcileBatchSize, result.Checked)
+	assert.Nil(t, env.repo.transactions[newest].CheckedAt)
+
+	// the next sweep starts with the deposit the first one never got to
+	_, err = reconciler.Reconcile(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, env.repo.transactions[newest].CheckedAt)
+}
@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type RefundDepositRequest struct {
+	// Amount of zero refunds whatever is left of the deposit
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+	Pin    string `json:"pin"`
+}
+
+// RefundDeposit sends a card deposit back to the card it came from. The wallet is debited straight away and
+// the refund settles on refund.processed, or is given back on refund.failed or when paystack refuses it.
+func (h *Handler) RefundDeposit(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	depositReference := mux.Vars(r)["reference"]
+
+	// bank transfers into dedicated accounts carry paystack's reference, only checkouts we started can be refunded
+	if !strings.HasPrefix(depositReference, "dep-") {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Only card deposits can be refunded", nil)
+		return
+	}
+
+	var req RefundDepositRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Amount < 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount", nil)
+		return
+	}
+
+	deposit, err := h.Repo.GetTransactionByReference(depositReference)
+	if err != nil || deposit.Category != CategoryDeposit {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Deposit not found", nil)
+		return
+	}
+
+	wallet, err := h.Repo.GetWalletByID(deposit.WalletID.String())
+	if err != nil || wallet.UserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Deposit not found", nil)
+		return
+	}
+
+	if !h.VerifyPin(w, wallet, req.Pin) {
+		return
+	}
+
+	description := "Refund of deposit " + depositReference
+	if req.Reason != "" {
+		description += ": " + req.Reason
+	}
+
+	reference := fmt.Sprintf("%s%d", pendingRefundPrefix, time.Now().UnixNano())
+	refund, err := h.Repo.InitiateRefund(depositReference, reference, req.Amount, description)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Deposit not found", nil)
+		case errors.Is(err, ErrNotRefundable):
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Only completed deposits can be refunded", nil)
+		case errors.Is(err, ErrRefundTooLarge):
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Refund exceeds what is left of the deposit", nil)
+		case err.Error() == "insufficient balance":
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
+		default:
+			logger.Error("RefundDeposit: Failed to initiate refund", logger.Fields{"error": err.Error(), "reference": depositReference})
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Refund failed", nil)
+		}
+		return
+	}
+
+	remote, err := h.Paystack.CreateRefund(r.Context(), paystack.RefundRequest{
+		Transaction:  depositReference,
+		Amount:       refund.Amount,
+		Currency:     wallet.Currency,
+		CustomerNote: req.Reason,
+		MerchantNote: "Requested from wallet " + wallet.WalletNumber,
+	})
+	// the refund is only given back when paystack turned it down. After a timeout or a 5xx paystack may have
+	// accepted it, so it stays pending for refund.processed or refund.failed to settle by deposit and amount.
+	if paystack.IsRejected(err) {
+		logger.Error("RefundDeposit: Paystack refused the refund, giving it back", logger.Fields{"error": err.Error(), "reference": depositReference})
+		if failErr := h.Repo.FailRefund(depositReference, reference, refund.Amount); failErr != nil {
+			logger.Error("RefundDeposit: Failed to give back refund", logger.Fields{"error": failErr.Error(), "reference": reference})
+		}
+		utils.BuildErrorResponse(w, http.StatusBadGateway, "Failed to initiate refund", map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Warn("RefundDeposit: Refund outcome unknown, leaving refund pending", logger.Fields{"error": err.Error(), "reference": reference})
+		utils.BuildSuccessResponse(w, http.StatusAccepted, "Refund initiated", map[string]interface{}{
+			"reference":         reference,
+			"deposit_reference": depositReference,
+			"amount":            refund.Amount,
+			"status":            TransactionPending,
+		})
+		return
+	}
+
+	// the webhook can claim the refund first, it finds it by deposit and amount then
+	refundReference := "rfd-" + strconv.FormatInt(remote.ID, 10)
+	if err := h.Repo.AttachRefund(reference, refundReference); err != nil {
+		logger.Warn("RefundDeposit: Failed to attach paystack refund id", logger.Fields{"error": err.Error(), "reference": reference, "refund_reference": refundReference})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusAccepted, "Refund initiated", map[string]interface{}{
+		"reference":         refundReference,
+		"deposit_reference": depositReference,
+		"amount":            refund.Amount,
+		"status":            TransactionPending,
+	})
+}
@@ -2,15 +2,70 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/ledger"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrLedgerMismatch = errors.New("wallet balance does not match ledger")
+
+var ErrPinLocked = errors.New("wallet is locked after too many failed PIN attempts")
+
+var (
+	ErrQuoteExpired  = errors.New("quote has expired")
+	ErrQuoteExecuted = errors.New("quote has already been executed")
+
+	ErrHoldNotActive   = errors.New("hold is no longer active")
+	ErrHoldExpired     = errors.New("hold has expired")
+	ErrCaptureTooLarge = errors.New("capture exceeds the held amount")
+
+	ErrNotReversible    = errors.New("transaction is not a reversible transfer")
+	ErrAlreadyReversed  = errors.New("transfer has already been fully reversed")
+	ErrReversalTooLarge = errors.New("reversal exceeds what is left of the transfer")
+
+	ErrNotInReview = errors.New("transaction is not a deposit held for review")
+
+	ErrNotRefundable  = errors.New("transaction is not a settled card deposit")
+	ErrRefundTooLarge = errors.New("refund exceeds what is left of the deposit")
+
+	ErrSelfTransfer     = errors.New("cannot transfer to the same wallet")
+	ErrCurrencyMismatch = errors.New("wallets hold different currencies")
+)
+
+const (
+	// pendingRefundPrefix marks a refund we asked paystack for whose refund id is not known yet, it is renamed
+	// to rfd-<refund id> once paystack answers or its webhook arrives
+	pendingRefundPrefix = "rfd-pending-"
+
+	// hasAvailable guards every debit, funds reserved by a hold cannot be spent twice
+	hasAvailable = "id = ? AND balance - held_balance - pocket_balance >= ?"
+
+	// sameUserWallets matches every wallet of the wallet's owner, a user has one PIN across all their wallets
+	// so PIN state changes apply to all of them
+	sameUserWallets = "user_id = (SELECT user_id FROM wallets WHERE id = ?)"
+)
+
+type BalanceCheck struct {
+	WalletBalance int64 `json:"wallet_balance"`
+	LedgerBalance int64 `json:"ledger_balance"`
+	PostedBalance int64 `json:"posted_balance"`
+	Balanced      bool  `json:"balanced"`
+}
+
 type Repository interface {
 	CreateWallet(wallet *Wallet) error
-	GetWalletByUserID(userID string) (*Wallet, error)
+	GetWalletByID(walletID string) (*Wallet, error)
+	GetWalletByUserID(userID, currency string) (*Wallet, error)
+	GetWalletsByUserID(userID string) ([]Wallet, error)
 	GetWalletByNumber(number string) (*Wallet, error)
+	GetWalletByCustomerCode(code string) (*Wallet, error)
+	SetPaystackCustomer(walletID, customerCode string) error
+	UpdateDedicatedAccount(walletID string, account DedicatedAccount) error
 	CreditWallet(walletID string, amount int64) error
 	DebitWallet(walletID string, amount int64) error
 
@@ -19,90 +74,360 @@ type Repository interface {
 	UpdateTransactionStatus(ref string, status TransactionStatus) error
 	GetTransactions(walletID string, limit, offset int) ([]Transaction, error)
 	CountTransactions(walletID string) (int64, error)
-	TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, description string) error
-	ProcessDeposit(reference string, amount int64) error
+	TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error
+	ReverseTransfer(reference string, amount int64, force bool, reason string) ([]Transaction, error)
+	ProcessDeposit(reference string) error
 	ProcessFailedTransaction(reference string) error
+	RecordBankTransferDeposit(tx *Transaction) error
+	FlagForReview(reference, reason string) error
+	ResolveReview(reference string, approve bool) (*Transaction, error)
+	GetStalePendingDeposits(createdBefore time.Time, limit int) ([]Transaction, error)
+	ExpireDeposit(reference string) error
+	InitiateWithdrawal(walletID, reference string, amount int64, charge fee.Charge, description string) error
+	GetStalePendingWithdrawals(createdBefore time.Time, limit int) ([]Transaction, error)
+	CompleteWithdrawal(reference string) error
+	ReverseWithdrawal(reference string) error
+	InitiateRefund(depositReference, reference string, amount int64, description string) (*Transaction, error)
+	AttachRefund(reference, refundReference string) error
+	ProcessRefund(depositReference, refundReference string, amount int64) error
+	FailRefund(depositReference, refundReference string, amount int64) error
+	RecordDispute(dispute *Dispute) error
+	VerifyWalletBalance(walletID string) (*BalanceCheck, error)
+
+	CreateQuote(quote *Quote) error
+	GetQuote(quoteID, userID string) (*Quote, error)
+	ExecuteConversion(quoteID string, now time.Time) (*Quote, error)
+
+	PlaceHold(hold *Hold) error
+	GetHold(holdID string) (*Hold, error)
+	ListHolds(walletID string, status HoldStatus, limit, offset int) ([]Hold, error)
+	CaptureHold(holdID string, amount int64, now time.Time) (*Hold, error)
+	ReleaseHold(holdID string, now time.Time) (*Hold, error)
+	ExpireHolds(now time.Time, limit int) (int, error)
+
+	ClaimPinAttempt(walletID string, maxAttempts int, now time.Time) (*Wallet, error)
+	RecordFailedPinAttempt(walletID string, maxAttempts int, lockFor time.Duration) (*Wallet, bool, error)
+	ResetPinAttempts(walletID string) error
+	UpdatePin(walletID, pinHash string) error
 }
 
 type repository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger ledger.Repository
 }
 
 func NewRepository(db *gorm.DB) Repository {
-	return &repository{db: db}
+	return &repository{db: db, ledger: ledger.NewRepository(db)}
+}
+
+// Funds moves money between wallets inside a database transaction the caller owns, so a package keeping its
+// own records commits them together with the money they account for
+type Funds interface {
+	Transfer(tx *gorm.DB, fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error
+	ToSystem(tx *gorm.DB, walletID, code, reference, description string, amount int64) error
+	FromSystem(tx *gorm.DB, walletID, code, reference, description string, amount int64) error
+	SetAside(tx *gorm.DB, walletID string, amount int64) error
+	PutBack(tx *gorm.DB, walletID string, amount int64) error
 }
 
-func (r *repository) TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, description string) error {
+func NewFunds(db *gorm.DB) Funds {
+	return &repository{db: db, ledger: ledger.NewRepository(db)}
+}
+
+func (r *repository) TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
+		return r.Transfer(tx, fromID, toID, senderNumber, recipientNumber, reference, amount, charge, description)
+	})
+}
 
-		//debit initiator
-		res := tx.Model(&Wallet{}).
-			Where("id = ? AND balance >= ?", fromID, amount).
-			UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+// Transfer moves amount between two wallets inside tx, TransferFunds and the packages behind Funds share it.
+// The sender pays the charge on top of amount and it goes to the FEES system account.
+func (r *repository) Transfer(tx *gorm.DB, fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error {
+	//debit initiator
+	res := tx.Model(&Wallet{}).
+		Where(hasAvailable, fromID, amount+charge.Amount).
+		UpdateColumn("balance", gorm.Expr("balance - ?", amount+charge.Amount))
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.New("insufficient balance")
+	}
+
+	// credit recipient
+	if err := tx.Model(&Wallet{}).Where("id = ?", toID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return err
+	}
+
+	senderAccount, err := r.walletAccount(tx, fromID)
+	if err != nil {
+		return err
+	}
+	recipientAccount, err := r.walletAccount(tx, toID)
+	if err != nil {
+		return err
+	}
+
+	lines := []ledger.Line{
+		ledger.DebitLine(senderAccount, amount+charge.Amount),
+		ledger.CreditLine(recipientAccount, amount),
+	}
+	if charge.Amount > 0 {
+		fees, err := r.ledger.WithTx(tx).SystemAccount(ledger.SystemFees, senderAccount.Currency)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, ledger.CreditLine(fees, charge.Amount))
+	}
+	if err := r.post(tx, reference, description, lines...); err != nil {
+		return err
+	}
+
+	// create sender debit transaction record
+	senderTx := Transaction{
+		WalletID:              uuid.MustParse(fromID),
+		Reference:             reference + "-debit",
+		Category:              CategoryTransfer,
+		Type:                  TransactionDebit,
+		Amount:                amount,
+		Status:                TransactionSuccess,
+		SenderWalletNumber:    &senderNumber,
+		RecipientWalletNumber: &recipientNumber,
+		Description:           description,
+		Fee:                   charge.Amount,
+		FeeScheduleID:         charge.ScheduleID,
+	}
+
+	if err := tx.Create(&senderTx).Error; err != nil {
+		return err
+	}
+
+	// create recipient credit transaction record
+	recipientTx := Transaction{
+		WalletID:              uuid.MustParse(toID),
+		Reference:             reference + "-credit",
+		Category:              CategoryTransfer,
+		Type:                  TransactionCredit,
+		Amount:                amount,
+		Status:                TransactionSuccess,
+		SenderWalletNumber:    &senderNumber,
+		RecipientWalletNumber: &recipientNumber,
+		Description:           description,
+	}
+
+	if err := tx.Create(&recipientTx).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ToSystem takes amount out of a wallet's available balance into the system account code in the wallet's
+// currency, e.g. ESCROW while an escrow holds it
+func (r *repository) ToSystem(tx *gorm.DB, walletID, code, reference, description string, amount int64) error {
+	res := tx.Model(&Wallet{}).
+		Where(hasAvailable, walletID, amount).
+		UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("insufficient balance")
+	}
+
+	account, err := r.walletAccount(tx, walletID)
+	if err != nil {
+		return err
+	}
+	system, err := r.ledger.WithTx(tx).SystemAccount(code, account.Currency)
+	if err != nil {
+		return err
+	}
+	return r.post(tx, reference, description, ledger.DebitLine(account, amount), ledger.CreditLine(system, amount))
+}
+
+// FromSystem pays amount out of the system account code in the wallet's currency into the wallet
+func (r *repository) FromSystem(tx *gorm.DB, walletID, code, reference, description string, amount int64) error {
+	if err := tx.Model(&Wallet{}).Where("id = ?", walletID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return err
+	}
 
+	account, err := r.walletAccount(tx, walletID)
+	if err != nil {
+		return err
+	}
+	system, err := r.ledger.WithTx(tx).SystemAccount(code, account.Currency)
+	if err != nil {
+		return err
+	}
+	return r.post(tx, reference, description, ledger.DebitLine(system, amount), ledger.CreditLine(account, amount))
+}
+
+// SetAside moves amount of a wallet's available balance into its pockets, the money stays in its Balance
+func (r *repository) SetAside(tx *gorm.DB, walletID string, amount int64) error {
+	res := tx.Model(&Wallet{}).
+		Where(hasAvailable, walletID, amount).
+		UpdateColumn("pocket_balance", gorm.Expr("pocket_balance + ?", amount))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("insufficient balance")
+	}
+	return nil
+}
+
+// PutBack returns amount set aside in a wallet's pockets to its main balance
+func (r *repository) PutBack(tx *gorm.DB, walletID string, amount int64) error {
+	return tx.Model(&Wallet{}).Where("id = ?", walletID).UpdateColumn("pocket_balance", gorm.Expr("pocket_balance - ?", amount)).Error
+}
+
+// ReverseTransfer sends amount of a completed transfer back from the recipient to the sender, zero reverses
+// whatever is left. The recipient needs the available balance unless force is set, which lets the balance go
+// negative. Both original legs are marked REVERSED and the compensating legs point back at them.
+func (r *repository) ReverseTransfer(reference string, amount int64, force bool, reason string) ([]Transaction, error) {
+	var legs []Transaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var originals []Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reference IN ?", []string{reference + "-debit", reference + "-credit"}).
+			Order("type DESC").
+			Find(&originals).Error; err != nil {
+			return err
+		}
+		// ordered DEBIT before CREDIT, so the sender's leg comes first
+		if len(originals) != 2 || originals[0].Type != TransactionDebit || originals[0].Category != CategoryTransfer {
+			return ErrNotReversible
+		}
+		sent, received := originals[0], originals[1]
+		if sent.Status != TransactionSuccess && sent.Status != TransactionReversed {
+			return ErrNotReversible
+		}
+
+		remaining := sent.Amount - sent.ReversedAmount
+		if remaining <= 0 {
+			return ErrAlreadyReversed
+		}
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return ErrReversalTooLarge
+		}
+
+		debit := tx.Model(&Wallet{})
+		if force {
+			debit = debit.Where("id = ?", received.WalletID)
+		} else {
+			debit = debit.Where(hasAvailable, received.WalletID, amount)
+		}
+		res := debit.UpdateColumn("balance", gorm.Expr("balance - ?", amount))
 		if res.Error != nil {
 			return res.Error
 		}
-
 		if res.RowsAffected == 0 {
 			return errors.New("insufficient balance")
 		}
 
-		// credit recipient
-		if err := tx.Model(&Wallet{}).Where("id = ?", toID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		if err := tx.Model(&Wallet{}).Where("id = ?", sent.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+			return err
+		}
+
+		recipientAccount, err := r.walletAccount(tx, received.WalletID.String())
+		if err != nil {
+			return err
+		}
+		senderAccount, err := r.walletAccount(tx, sent.WalletID.String())
+		if err != nil {
 			return err
 		}
 
-		// create sender debit transaction record
-		senderTx := Transaction{
-			WalletID:              uuid.MustParse(fromID),
-			Reference:             reference + "-debit",
-			Category:              CategoryTransfer,
-			Type:                  TransactionDebit,
-			Amount:                amount,
-			Status:                TransactionSuccess,
-			SenderWalletNumber:    &senderNumber,
-			RecipientWalletNumber: &recipientNumber,
-			Description:           description,
+		reversal := fmt.Sprintf("rev-%d", time.Now().UnixNano())
+		description := "Reversal of " + reference
+		if reason != "" {
+			description += ": " + reason
 		}
 
-		if err := tx.Create(&senderTx).Error; err != nil {
+		if err := r.post(tx, reversal, description,
+			ledger.DebitLine(recipientAccount, amount),
+			ledger.CreditLine(senderAccount, amount),
+		); err != nil {
 			return err
 		}
 
-		// create recipient credit transaction record
-		recipientTx := Transaction{
-			WalletID:              uuid.MustParse(toID),
-			Reference:             reference + "-credit",
-			Category:              CategoryTransfer,
-			Type:                  TransactionCredit,
-			Amount:                amount,
-			Status:                TransactionSuccess,
-			SenderWalletNumber:    &senderNumber,
-			RecipientWalletNumber: &recipientNumber,
-			Description:           description,
+		legs = []Transaction{
+			{
+				WalletID:              received.WalletID,
+				Reference:             reversal + "-debit",
+				Category:              CategoryTransfer,
+				Type:                  TransactionDebit,
+				Amount:                amount,
+				Status:                TransactionSuccess,
+				SenderWalletNumber:    received.RecipientWalletNumber,
+				RecipientWalletNumber: received.SenderWalletNumber,
+				Description:           description,
+				ReversalOf:            &received.Reference,
+			},
+			{
+				WalletID:              sent.WalletID,
+				Reference:             reversal + "-credit",
+				Category:              CategoryTransfer,
+				Type:                  TransactionCredit,
+				Amount:                amount,
+				Status:                TransactionSuccess,
+				SenderWalletNumber:    received.RecipientWalletNumber,
+				RecipientWalletNumber: received.SenderWalletNumber,
+				Description:           description,
+				ReversalOf:            &sent.Reference,
+			},
 		}
-
-		if err := tx.Create(&recipientTx).Error; err != nil {
+		if err := tx.Create(&legs).Error; err != nil {
 			return err
 		}
 
-		return nil
+		return tx.Model(&Transaction{}).Where("id IN ?", []uuid.UUID{sent.ID, received.ID}).UpdateColumns(map[string]interface{}{
+			"status":          TransactionReversed,
+			"reversed_amount": gorm.Expr("reversed_amount + ?", amount),
+		}).Error
 	})
+	if err != nil {
+		return nil, err
+	}
+	return legs, nil
 }
 
 func (r *repository) CreateWallet(wallet *Wallet) error {
-	return r.db.Create(wallet).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(wallet).Error; err != nil {
+			return err
+		}
+
+		_, err := r.ledger.WithTx(tx).WalletAccount(wallet.ID, wallet.Currency)
+		return err
+	})
+}
+
+func (r *repository) GetWalletByID(walletID string) (*Wallet, error) {
+	return r.getWalletByID(r.db, walletID)
 }
 
-func (r *repository) GetWalletByUserID(userID string) (*Wallet, error) {
+func (r *repository) GetWalletByUserID(userID, currency string) (*Wallet, error) {
 	var wallet Wallet
-	if err := r.db.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := r.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error; err != nil {
 		return nil, err
 	}
 	return &wallet, nil
 }
 
+// GetWalletsByUserID returns every wallet the user holds, oldest first
+func (r *repository) GetWalletsByUserID(userID string) ([]Wallet, error) {
+	var wallets []Wallet
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&wallets).Error
+	return wallets, err
+}
+
 func (r *repository) GetWalletByNumber(number string) (*Wallet, error) {
 	var wallet Wallet
 	if err := r.db.Where("wallet_number = ?", number).First(&wallet).Error; err != nil {
@@ -111,24 +436,82 @@ func (r *repository) GetWalletByNumber(number string) (*Wallet, error) {
 	return &wallet, nil
 }
 
+func (r *repository) GetWalletByCustomerCode(code string) (*Wallet, error) {
+	var wallet Wallet
+	if err := r.db.Where("paystack_customer_code = ?", code).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *repository) SetPaystackCustomer(walletID, customerCode string) error {
+	return r.db.Model(&Wallet{}).Where("id = ?", walletID).Update("paystack_customer_code", customerCode).Error
+}
+
+func (r *repository) UpdateDedicatedAccount(walletID string, account DedicatedAccount) error {
+	return r.db.Model(&Wallet{}).Where("id = ?", walletID).Updates(map[string]interface{}{
+		"dedicated_status":         account.Status,
+		"dedicated_account_number": account.AccountNumber,
+		"dedicated_account_name":   account.AccountName,
+		"dedicated_bank_name":      account.BankName,
+		"dedicated_bank_slug":      account.BankSlug,
+		"dedicated_paystack_id":    account.PaystackID,
+	}).Error
+}
+
+// CreditWallet is a manual adjustment, the other side of the posting is the suspense account
 func (r *repository) CreditWallet(walletID string, amount int64) error {
-	return r.db.Model(&Wallet{}).
-		Where("id = ?", walletID).
-		UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Wallet{}).
+			Where("id = ?", walletID).
+			UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+			return err
+		}
+
+		account, err := r.walletAccount(tx, walletID)
+		if err != nil {
+			return err
+		}
+		suspense, err := r.ledger.WithTx(tx).SystemAccount(ledger.SystemSuspense, account.Currency)
+		if err != nil {
+			return err
+		}
+
+		return r.post(tx, "adj-"+uuid.NewString(), "Manual credit adjustment",
+			ledger.DebitLine(suspense, amount),
+			ledger.CreditLine(account, amount),
+		)
+	})
 }
 
+// DebitWallet is a manual adjustment, the other side of the posting is the suspense account
 func (r *repository) DebitWallet(walletID string, amount int64) error {
-	result := r.db.Model(&Wallet{}).
-		Where("id = ? AND balance >= ?", walletID, amount).
-		UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Wallet{}).
+			Where(hasAvailable, walletID, amount).
+			UpdateColumn("balance", gorm.Expr("balance - ?", amount))
 
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return errors.New("insufficient balance")
-	}
-	return nil
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		account, err := r.walletAccount(tx, walletID)
+		if err != nil {
+			return err
+		}
+		suspense, err := r.ledger.WithTx(tx).SystemAccount(ledger.SystemSuspense, account.Currency)
+		if err != nil {
+			return err
+		}
+
+		return r.post(tx, "adj-"+uuid.NewString(), "Manual debit adjustment",
+			ledger.DebitLine(account, amount),
+			ledger.CreditLine(suspense, amount),
+		)
+	})
 }
 
 func (r *repository) CreateTransaction(tx *Transaction) error {
@@ -165,33 +548,55 @@ func (r *repository) CountTransactions(walletID string) (int64, error) {
 	return count, err
 }
 
-func (r *repository) ProcessDeposit(reference string, amount int64) error {
+// ProcessDeposit credits the amount recorded on a deposit once, callers check the payment against it
+// first with settleDeposit
+func (r *repository) ProcessDeposit(reference string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var transaction Transaction
-		if err := tx.Where("reference = ?", reference).First(&transaction).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", reference).First(&transaction).Error; err != nil {
 			return err
 		}
 
-		if transaction.Status == TransactionSuccess {
+		if transaction.Status == TransactionSuccess || transaction.Status == TransactionReview {
 			return nil
 		}
 
-		if err := tx.Model(&Wallet{}).Where("id = ?", transaction.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return r.creditDeposit(tx, &transaction)
+	})
+}
+
+// creditDeposit credits a deposit locked in tx and marks it SUCCESS
+func (r *repository) creditDeposit(tx *gorm.DB, transaction *Transaction) error {
+	// the fee was set when the deposit was made, the wallet gets what is left of the payment
+	amount := transaction.Amount - transaction.Fee
+	if err := tx.Model(&Wallet{}).Where("id = ?", transaction.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return err
+	}
+
+	if transaction.Fee > 0 {
+		account, clearing, fees, err := r.feeAccounts(tx, transaction.WalletID.String())
+		if err != nil {
 			return err
 		}
-
-		if err := tx.Model(&Transaction{}).Where("reference = ?", reference).Update("status", TransactionSuccess).Error; err != nil {
+		if err := r.post(tx, transaction.Reference, transaction.Description,
+			ledger.DebitLine(clearing, transaction.Amount),
+			ledger.CreditLine(account, amount),
+			ledger.CreditLine(fees, transaction.Fee),
+		); err != nil {
 			return err
 		}
+	} else if err := r.postClearing(tx, transaction.WalletID.String(), transaction.Reference, transaction.Description, amount, ledger.Credit); err != nil {
+		return err
+	}
 
-		return nil
-	})
+	transaction.Status = TransactionSuccess
+	return tx.Model(&Transaction{}).Where("reference = ?", transaction.Reference).Update("status", TransactionSuccess).Error
 }
 
 func (r *repository) ProcessFailedTransaction(reference string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var transaction Transaction
-		if err := tx.Where("reference = ?", reference).First(&transaction).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", reference).First(&transaction).Error; err != nil {
 			return err
 		}
 
@@ -207,3 +612,883 @@ func (r *repository) ProcessFailedTransaction(reference string) error {
 		return nil
 	})
 }
+
+// RecordBankTransferDeposit stores a transfer into a dedicated account and credits it when it is
+// SUCCESS. Paystack's reference is the dedupe key, a reference seen before is a no-op.
+func (r *repository) RecordBankTransferDeposit(deposit *Transaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(deposit)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 || deposit.Status != TransactionSuccess {
+			return nil
+		}
+
+		if err := tx.Model(&Wallet{}).Where("id = ?", deposit.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", deposit.Amount)).Error; err != nil {
+			return err
+		}
+
+		return r.postClearing(tx, deposit.WalletID.String(), deposit.Reference, deposit.Description, deposit.Amount, ledger.Credit)
+	})
+}
+
+// FlagForReview parks an unsettled deposit so nothing credits it until someone has looked at it
+func (r *repository) FlagForReview(reference, reason string) error {
+	return r.db.Model(&Transaction{}).
+		Where("reference = ? AND status IN ?", reference, []TransactionStatus{TransactionPending, TransactionExpired}).
+		Updates(map[string]interface{}{"status": TransactionReview, "review_reason": reason}).Error
+}
+
+// ResolveReview settles a deposit FlagForReview parked, approving credits it as recorded and rejecting fails it
+func (r *repository) ResolveReview(reference string, approve bool) (*Transaction, error) {
+	var transaction Transaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", reference).First(&transaction).Error; err != nil {
+			return err
+		}
+		if transaction.Category != CategoryDeposit || transaction.Status != TransactionReview {
+			return ErrNotInReview
+		}
+
+		if approve {
+			return r.creditDeposit(tx, &transaction)
+		}
+		transaction.Status = TransactionFailed
+		return tx.Model(&Transaction{}).Where("reference = ?", reference).Update("status", TransactionFailed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *repository) GetStalePendingDeposits(createdBefore time.Time, limit int) ([]Transaction, error) {
+	return r.claimStale(limit, "category = ? AND status = ? AND reference LIKE ? AND created_at < ?", CategoryDeposit, TransactionPending, "dep-%", createdBefore)
+}
+
+// claimStale picks up to limit pending transactions, the ones checked longest ago first, and marks them checked
+// so the next sweep moves on to others instead of asking about the same oldest ones again
+func (r *repository) claimStale(limit int, query string, args ...interface{}) ([]Transaction, error) {
+	var txs []Transaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where(query, args...).
+			Order("checked_at asc nulls first, created_at asc").
+			Limit(limit).
+			Find(&txs).Error; err != nil {
+			return err
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(txs))
+		for i, t := range txs {
+			ids[i] = t.ID
+		}
+		return tx.Model(&Transaction{}).Where("id IN ?", ids).UpdateColumn("checked_at", time.Now()).Error
+	})
+	return txs, err
+}
+
+func (r *repository) ExpireDeposit(reference string) error {
+	return r.db.Model(&Transaction{}).
+		Where("reference = ? AND status = ?", reference, TransactionPending).
+		Update("status", TransactionExpired).Error
+}
+
+// InitiateWithdrawal debits amount and the charge on it, paystack is only asked to pay out amount
+func (r *repository) InitiateWithdrawal(walletID, reference string, amount int64, charge fee.Charge, description string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		// hold the funds by debiting the wallet up front, the hold is released if the transfer fails
+		res := tx.Model(&Wallet{}).
+			Where(hasAvailable, walletID, amount+charge.Amount).
+			UpdateColumn("balance", gorm.Expr("balance - ?", amount+charge.Amount))
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		if charge.Amount > 0 {
+			account, clearing, fees, err := r.feeAccounts(tx, walletID)
+			if err != nil {
+				return err
+			}
+			if err := r.post(tx, reference, description,
+				ledger.DebitLine(account, amount+charge.Amount),
+				ledger.CreditLine(clearing, amount),
+				ledger.CreditLine(fees, charge.Amount),
+			); err != nil {
+				return err
+			}
+		} else if err := r.postClearing(tx, walletID, reference, description, amount, ledger.Debit); err != nil {
+			return err
+		}
+
+		withdrawalTx := Transaction{
+			WalletID:      uuid.MustParse(walletID),
+			Reference:     reference,
+			Category:      CategoryWithdrawal,
+			Type:          TransactionDebit,
+			Amount:        amount,
+			Status:        TransactionPending,
+			Description:   description,
+			Fee:           charge.Amount,
+			FeeScheduleID: charge.ScheduleID,
+		}
+
+		return tx.Create(&withdrawalTx).Error
+	})
+}
+
+func (r *repository) GetStalePendingWithdrawals(createdBefore time.Time, limit int) ([]Transaction, error) {
+	return r.claimStale(limit, "category = ? AND status = ? AND created_at < ?", CategoryWithdrawal, TransactionPending, createdBefore)
+}
+
+func (r *repository) CompleteWithdrawal(reference string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var transaction Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ? AND category = ?", reference, CategoryWithdrawal).First(&transaction).Error; err != nil {
+			return err
+		}
+
+		if transaction.Status != TransactionPending {
+			return nil
+		}
+
+		return tx.Model(&Transaction{}).Where("reference = ?", reference).Update("status", TransactionSuccess).Error
+	})
+}
+
+// ReverseWithdrawal gives a withdrawal that did not pay out back to its wallet, once. The row is locked first
+// because transfer.failed, transfer.reversed and the reconciler can all reverse the same withdrawal at once.
+func (r *repository) ReverseWithdrawal(reference string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var transaction Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ? AND category = ?", reference, CategoryWithdrawal).First(&transaction).Error; err != nil {
+			return err
+		}
+
+		// transfer.reversed can arrive after transfer.success, so both states release the hold
+		if transaction.Status == TransactionFailed {
+			return nil
+		}
+
+		// a withdrawal that never paid out gives its fee back too
+		if err := tx.Model(&Wallet{}).Where("id = ?", transaction.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", transaction.Amount+transaction.Fee)).Error; err != nil {
+			return err
+		}
+
+		if transaction.Fee > 0 {
+			account, clearing, fees, err := r.feeAccounts(tx, transaction.WalletID.String())
+			if err != nil {
+				return err
+			}
+			if err := r.post(tx, reference+"-reversal", "Withdrawal reversal",
+				ledger.DebitLine(clearing, transaction.Amount),
+				ledger.DebitLine(fees, transaction.Fee),
+				ledger.CreditLine(account, transaction.Amount+transaction.Fee),
+			); err != nil {
+				return err
+			}
+		} else if err := r.postClearing(tx, transaction.WalletID.String(), reference+"-reversal", "Withdrawal reversal", transaction.Amount, ledger.Credit); err != nil {
+			return err
+		}
+
+		return tx.Model(&Transaction{}).Where("reference = ?", reference).Update("status", TransactionFailed).Error
+	})
+}
+
+// InitiateRefund debits the wallet for a refund of a card deposit before paystack is asked for it, the
+// same way a withdrawal holds its funds. Only what the deposit credited can be refunded, the fee it paid stays
+// with FEES. An amount of zero refunds whatever is left of the deposit.
+func (r *repository) InitiateRefund(depositReference, reference string, amount int64, description string) (*Transaction, error) {
+	var refund Transaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var deposit Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ? AND category = ?", depositReference, CategoryDeposit).First(&deposit).Error; err != nil {
+			return err
+		}
+		if deposit.Status != TransactionSuccess {
+			return ErrNotRefundable
+		}
+
+		remaining, err := r.refundable(tx, &deposit)
+		if err != nil {
+			return err
+		}
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return ErrRefundTooLarge
+		}
+
+		res := tx.Model(&Wallet{}).
+			Where(hasAvailable, deposit.WalletID, amount).
+			UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		if err := r.postClearing(tx, deposit.WalletID.String(), reference, description, amount, ledger.Debit); err != nil {
+			return err
+		}
+
+		refund = Transaction{
+			WalletID:    deposit.WalletID,
+			Reference:   reference,
+			Category:    CategoryRefund,
+			Type:        TransactionDebit,
+			Amount:      amount,
+			Status:      TransactionPending,
+			Description: description,
+			RefundOf:    &deposit.Reference,
+		}
+		return tx.Create(&refund).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// AttachRefund gives a pending refund the rfd-<refund id> reference its webhooks will carry. It does nothing
+// if a webhook got there first and already claimed the refund.
+func (r *repository) AttachRefund(reference, refundReference string) error {
+	return r.db.Model(&Transaction{}).
+		Where("reference = ? AND status = ?", reference, TransactionPending).
+		Update("reference", refundReference).Error
+}
+
+// refundable is what is left to refund of a deposit locked in tx, what it credited less every refund that
+// has not failed
+func (r *repository) refundable(tx *gorm.DB, deposit *Transaction) (int64, error) {
+	var refunded int64
+	if err := tx.Model(&Transaction{}).
+		Where("refund_of = ? AND status <> ?", deposit.Reference, TransactionFailed).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refunded).Error; err != nil {
+		return 0, err
+	}
+	return deposit.Amount - deposit.Fee - refunded, nil
+}
+
+// ProcessRefund debits a deposit that paystack refunded to the payer. A refund asked for through
+// InitiateRefund was debited up front and is only marked settled. Any other refund, e.g. one made from the
+// paystack dashboard, is held to what is left of the deposit and to the wallet's available balance like
+// InitiateRefund; one that breaks either fails so the event is retried and then dead-lettered for an admin.
+func (r *repository) ProcessRefund(depositReference, refundReference string, amount int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var deposit Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ? AND category = ?", depositReference, CategoryDeposit).First(&deposit).Error; err != nil {
+			return err
+		}
+
+		// the refund can overtake charge.success in the queue, fail so it is retried once the deposit settles
+		if deposit.Status != TransactionSuccess {
+			return fmt.Errorf("deposit %s is %s, not settled", depositReference, deposit.Status)
+		}
+
+		pending, err := r.pendingRefund(tx, depositReference, refundReference, amount)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			if pending.Status != TransactionPending {
+				return nil
+			}
+			return tx.Model(pending).Updates(map[string]interface{}{"reference": refundReference, "status": TransactionSuccess}).Error
+		}
+
+		remaining, err := r.refundable(tx, &deposit)
+		if err != nil {
+			return err
+		}
+		if amount <= 0 || amount > remaining {
+			return ErrRefundTooLarge
+		}
+
+		res := tx.Model(&Wallet{}).
+			Where(hasAvailable, deposit.WalletID, amount).
+			UpdateColumn("balance", gorm.Expr("balance - ?", amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		description := "Refund of deposit " + depositReference
+		if err := r.postClearing(tx, deposit.WalletID.String(), refundReference, description, amount, ledger.Debit); err != nil {
+			return err
+		}
+
+		return tx.Create(&Transaction{
+			WalletID:    deposit.WalletID,
+			Reference:   refundReference,
+			Category:    CategoryRefund,
+			Type:        TransactionDebit,
+			Amount:      amount,
+			Status:      TransactionSuccess,
+			Description: description,
+			RefundOf:    &deposit.Reference,
+		}).Error
+	})
+}
+
+// FailRefund gives back a refund paystack could not make. A refund nobody debited the wallet for, e.g. one
+// started from the paystack dashboard, has nothing to give back.
+func (r *repository) FailRefund(depositReference, refundReference string, amount int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		pending, err := r.pendingRefund(tx, depositReference, refundReference, amount)
+		if err != nil {
+			return err
+		}
+		if pending == nil || pending.Status != TransactionPending {
+			return nil
+		}
+
+		if err := tx.Model(&Wallet{}).Where("id = ?", pending.WalletID).UpdateColumn("balance", gorm.Expr("balance + ?", pending.Amount)).Error; err != nil {
+			return err
+		}
+
+		if err := r.postClearing(tx, pending.WalletID.String(), pending.Reference+"-reversal", "Refund reversal", pending.Amount, ledger.Credit); err != nil {
+			return err
+		}
+
+		return tx.Model(pending).Updates(map[string]interface{}{"reference": refundReference, "status": TransactionFailed}).Error
+	})
+}
+
+// pendingRefund locks the refund a webhook is about, found by its reference or, when the webhook overtook
+// AttachRefund, as a refund of the same amount on the deposit that is still waiting for its refund id
+func (r *repository) pendingRefund(tx *gorm.DB, depositReference, refundReference string, amount int64) (*Transaction, error) {
+	var refunds []Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("reference = ? AND category = ?", refundReference, CategoryRefund).
+		Limit(1).
+		Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	if len(refunds) == 0 {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refund_of = ? AND status = ? AND amount = ? AND reference LIKE ?", depositReference, TransactionPending, amount, pendingRefundPrefix+"%").
+			Order("created_at asc").
+			Limit(1).
+			Find(&refunds).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(refunds) == 0 {
+		return nil, nil
+	}
+	return &refunds[0], nil
+}
+
+// RecordDispute inserts a dispute or updates it from a later event for the same paystack dispute
+func (r *repository) RecordDispute(dispute *Dispute) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "paystack_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "refund_amount", "resolution", "due_at", "resolved_at", "updated_at"}),
+	}).Create(dispute).Error
+}
+
+func (r *repository) VerifyWalletBalance(walletID string) (*BalanceCheck, error) {
+	wallet, err := r.getWalletByID(r.db, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := r.ledger.GetAccountByWalletID(walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	posted, err := r.ledger.DerivedBalance(account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BalanceCheck{
+		WalletBalance: wallet.Balance,
+		LedgerBalance: account.Balance,
+		PostedBalance: posted,
+		Balanced:      wallet.Balance == account.Balance && account.Balance == posted,
+	}, nil
+}
+
+// ClaimPinAttempt counts an attempt before the PIN is compared, in one conditional update so parallel requests
+// can't all get past the lockout. It returns ErrPinLocked while the wallet is locked or once maxAttempts
+// attempts are counted. A correct PIN clears the count with ResetPinAttempts and a wrong one is handed to
+// RecordFailedPinAttempt.
+func (r *repository) ClaimPinAttempt(walletID string, maxAttempts int, now time.Time) (*Wallet, error) {
+	res := r.db.Model(&Wallet{}).
+		Where(sameUserWallets, walletID).
+		Where("(pin_locked_until IS NULL OR pin_locked_until <= ?) AND failed_pin_attempts < ?", now, maxAttempts).
+		UpdateColumn("failed_pin_attempts", gorm.Expr("failed_pin_attempts + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	wallet, err := r.getWalletByID(r.db, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return wallet, ErrPinLocked
+	}
+	return wallet, nil
+}
+
+// RecordFailedPinAttempt locks the wallet for lockFor when a wrong PIN used up the last of maxAttempts, the
+// attempt itself was already counted by ClaimPinAttempt. It reports whether this attempt locked the wallet.
+func (r *repository) RecordFailedPinAttempt(walletID string, maxAttempts int, lockFor time.Duration) (*Wallet, bool, error) {
+	res := r.db.Model(&Wallet{}).
+		Where(sameUserWallets, walletID).
+		Where("failed_pin_attempts >= ?", maxAttempts).
+		UpdateColumns(map[string]interface{}{
+			"pin_locked_until":    time.Now().Add(lockFor),
+			"failed_pin_attempts": 0,
+		})
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	wallet, err := r.getWalletByID(r.db, walletID)
+	if err != nil {
+		return nil, false, err
+	}
+	return wallet, res.RowsAffected > 0, nil
+}
+
+func (r *repository) ResetPinAttempts(walletID string) error {
+	return r.db.Model(&Wallet{}).Where(sameUserWallets, walletID).UpdateColumns(map[string]interface{}{
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error
+}
+
+// UpdatePin replaces the PIN hash, clears any lockout and records when the PIN changed
+func (r *repository) UpdatePin(walletID, pinHash string) error {
+	return r.db.Model(&Wallet{}).Where(sameUserWallets, walletID).Updates(map[string]interface{}{
+		"pin_hash":            pinHash,
+		"failed_pin_attempts": 0,
+		"pin_locked_until":    nil,
+		"pin_changed_at":      time.Now(),
+	}).Error
+}
+
+// CreateQuote saves a conversion quote, no money moves until ExecuteConversion
+func (r *repository) CreateQuote(quote *Quote) error {
+	return r.db.Create(quote).Error
+}
+
+func (r *repository) GetQuote(quoteID, userID string) (*Quote, error) {
+	var quote Quote
+	if err := r.db.Where("id = ? AND user_id = ?", quoteID, userID).First(&quote).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// ExecuteConversion debits the source wallet and credits the target at the quoted rate, the quote is
+// locked for the whole transaction so it can only be executed once
+func (r *repository) ExecuteConversion(quoteID string, now time.Time) (*Quote, error) {
+	var quote Quote
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", quoteID).First(&quote).Error; err != nil {
+			return err
+		}
+		if quote.ExecutedAt != nil {
+			return ErrQuoteExecuted
+		}
+		if now.After(quote.ExpiresAt) {
+			return ErrQuoteExpired
+		}
+
+		res := tx.Model(&Wallet{}).
+			Where(hasAvailable, quote.FromWalletID, quote.Amount).
+			UpdateColumn("balance", gorm.Expr("balance - ?", quote.Amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		if err := tx.Model(&Wallet{}).Where("id = ?", quote.ToWalletID).UpdateColumn("balance", gorm.Expr("balance + ?", quote.ConvertedAmount)).Error; err != nil {
+			return err
+		}
+
+		fromAccount, err := r.walletAccount(tx, quote.FromWalletID.String())
+		if err != nil {
+			return err
+		}
+		toAccount, err := r.walletAccount(tx, quote.ToWalletID.String())
+		if err != nil {
+			return err
+		}
+		ledgerTx := r.ledger.WithTx(tx)
+		fromPosition, err := ledgerTx.SystemAccount(ledger.SystemFXPosition, quote.FromCurrency)
+		if err != nil {
+			return err
+		}
+		toPosition, err := ledgerTx.SystemAccount(ledger.SystemFXPosition, quote.ToCurrency)
+		if err != nil {
+			return err
+		}
+
+		reference := "fx-" + quote.ID.String()
+		description := fmt.Sprintf("Conversion %s to %s", quote.FromCurrency, quote.ToCurrency)
+
+		lines := []ledger.Line{
+			ledger.DebitLine(fromAccount, quote.Amount),
+			ledger.CreditLine(fromPosition, quote.Amount-quote.Fee),
+			ledger.DebitLine(toPosition, quote.ConvertedAmount),
+			ledger.CreditLine(toAccount, quote.ConvertedAmount),
+		}
+		if quote.Fee > 0 {
+			fees, err := ledgerTx.SystemAccount(ledger.SystemFees, quote.FromCurrency)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, ledger.CreditLine(fees, quote.Fee))
+		}
+		if err := r.post(tx, reference, description, lines...); err != nil {
+			return err
+		}
+
+		conversion := Conversion{
+			QuoteID:      &quote.ID,
+			FromCurrency: quote.FromCurrency,
+			ToCurrency:   quote.ToCurrency,
+			Rate:         quote.Rate,
+			MarketRate:   quote.MarketRate,
+			SpreadBps:    quote.SpreadBps,
+			Fee:          quote.Fee,
+		}
+		legs := []Transaction{
+			{
+				WalletID:    quote.FromWalletID,
+				Reference:   reference + "-debit",
+				Category:    CategoryConversion,
+				Type:        TransactionDebit,
+				Amount:      quote.Amount,
+				Status:      TransactionSuccess,
+				Description: description,
+				Conversion:  conversion,
+			},
+			{
+				WalletID:    quote.ToWalletID,
+				Reference:   reference + "-credit",
+				Category:    CategoryConversion,
+				Type:        TransactionCredit,
+				Amount:      quote.ConvertedAmount,
+				Status:      TransactionSuccess,
+				Description: description,
+				Conversion:  conversion,
+			},
+		}
+		if err := tx.Create(&legs).Error; err != nil {
+			return err
+		}
+
+		quote.ExecutedAt = &now
+		quote.Reference = reference
+		return tx.Model(&Quote{}).Where("id = ?", quote.ID).Updates(map[string]interface{}{
+			"executed_at": now,
+			"reference":   reference,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// PlaceHold reserves hold.Amount of the wallet's available balance
+func (r *repository) PlaceHold(hold *Hold) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Wallet{}).
+			Where(hasAvailable, hold.WalletID, hold.Amount).
+			UpdateColumn("held_balance", gorm.Expr("held_balance + ?", hold.Amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("insufficient balance")
+		}
+
+		hold.Status = HoldActive
+		return tx.Create(hold).Error
+	})
+}
+
+func (r *repository) GetHold(holdID string) (*Hold, error) {
+	var hold Hold
+	if err := r.db.Where("id = ?", holdID).First(&hold).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *repository) ListHolds(walletID string, status HoldStatus, limit, offset int) ([]Hold, error) {
+	query := r.db.Where("wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var holds []Hold
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&holds).Error
+	return holds, err
+}
+
+// CaptureHold pays amount of an active hold into its recipient wallet, anything left of the hold is released
+func (r *repository) CaptureHold(holdID string, amount int64, now time.Time) (*Hold, error) {
+	var hold Hold
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.lockActiveHold(tx, holdID, &hold); err != nil {
+			return err
+		}
+		if now.After(hold.ExpiresAt) {
+			return ErrHoldExpired
+		}
+		if amount > hold.Amount {
+			return ErrCaptureTooLarge
+		}
+		if hold.RecipientWalletID == nil {
+			return errors.New("hold has no recipient wallet")
+		}
+
+		if err := tx.Model(&Wallet{}).Where("id = ?", hold.WalletID).UpdateColumns(map[string]interface{}{
+			"balance":      gorm.Expr("balance - ?", amount),
+			"held_balance": gorm.Expr("held_balance - ?", hold.Amount),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Wallet{}).Where("id = ?", *hold.RecipientWalletID).UpdateColumn("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+			return err
+		}
+
+		payer, err := r.getWalletByID(tx, hold.WalletID.String())
+		if err != nil {
+			return err
+		}
+		recipient, err := r.getWalletByID(tx, hold.RecipientWalletID.String())
+		if err != nil {
+			return err
+		}
+		payerAccount, err := r.walletAccount(tx, payer.ID.String())
+		if err != nil {
+			return err
+		}
+		recipientAccount, err := r.walletAccount(tx, recipient.ID.String())
+		if err != nil {
+			return err
+		}
+
+		reference := hold.Reference + "-capture"
+		if err := r.post(tx, reference, hold.Description,
+			ledger.DebitLine(payerAccount, amount),
+			ledger.CreditLine(recipientAccount, amount),
+		); err != nil {
+			return err
+		}
+
+		legs := []Transaction{
+			{
+				WalletID:              payer.ID,
+				Reference:             reference + "-debit",
+				Category:              CategoryTransfer,
+				Type:                  TransactionDebit,
+				Amount:                amount,
+				Status:                TransactionSuccess,
+				SenderWalletNumber:    &payer.WalletNumber,
+				RecipientWalletNumber: &recipient.WalletNumber,
+				Description:           hold.Description,
+			},
+			{
+				WalletID:              recipient.ID,
+				Reference:             reference + "-credit",
+				Category:              CategoryTransfer,
+				Type:                  TransactionCredit,
+				Amount:                amount,
+				Status:                TransactionSuccess,
+				SenderWalletNumber:    &payer.WalletNumber,
+				RecipientWalletNumber: &recipient.WalletNumber,
+				Description:           hold.Description,
+			},
+		}
+		if err := tx.Create(&legs).Error; err != nil {
+			return err
+		}
+
+		hold.Status = HoldCaptured
+		hold.CapturedAmount = amount
+		hold.CapturedAt = &now
+		return tx.Model(&Hold{}).Where("id = ?", hold.ID).Updates(map[string]interface{}{
+			"status":          hold.Status,
+			"captured_amount": amount,
+			"captured_at":     now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *repository) ReleaseHold(holdID string, now time.Time) (*Hold, error) {
+	var hold Hold
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.lockActiveHold(tx, holdID, &hold); err != nil {
+			return err
+		}
+		return r.releaseHold(tx, &hold, HoldReleased, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ExpireHolds releases up to limit active holds whose expiry has passed and returns how many it released
+func (r *repository) ExpireHolds(now time.Time, limit int) (int, error) {
+	var ids []uuid.UUID
+	if err := r.db.Model(&Hold{}).Where("status = ? AND expires_at <= ?", HoldActive, now).Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var hold Hold
+			if err := r.lockActiveHold(tx, id.String(), &hold); err != nil {
+				return err
+			}
+			return r.releaseHold(tx, &hold, HoldExpired, now)
+		})
+		// a hold captured or released since the select is no longer active, which is fine
+		if errors.Is(err, ErrHoldNotActive) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (r *repository) lockActiveHold(tx *gorm.DB, holdID string, hold *Hold) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", holdID).First(hold).Error; err != nil {
+		return err
+	}
+	if hold.Status != HoldActive {
+		return ErrHoldNotActive
+	}
+	return nil
+}
+
+func (r *repository) releaseHold(tx *gorm.DB, hold *Hold, status HoldStatus, now time.Time) error {
+	if err := tx.Model(&Wallet{}).Where("id = ?", hold.WalletID).UpdateColumn("held_balance", gorm.Expr("held_balance - ?", hold.Amount)).Error; err != nil {
+		return err
+	}
+
+	hold.Status = status
+	hold.ReleasedAt = &now
+	return tx.Model(&Hold{}).Where("id = ?", hold.ID).Updates(map[string]interface{}{
+		"status":      status,
+		"released_at": now,
+	}).Error
+}
+
+func (r *repository) getWalletByID(tx *gorm.DB, walletID string) (*Wallet, error) {
+	var wallet Wallet
+	if err := tx.Where("id = ?", walletID).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *repository) walletAccount(tx *gorm.DB, walletID string) (*ledger.Account, error) {
+	wallet, err := r.getWalletByID(tx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	return r.ledger.WithTx(tx).WalletAccount(wallet.ID, wallet.Currency)
+}
+
+// postClearing moves money between a wallet and the Paystack clearing account, direction is the wallet side
+func (r *repository) postClearing(tx *gorm.DB, walletID, reference, description string, amount int64, direction ledger.Direction) error {
+	account, err := r.walletAccount(tx, walletID)
+	if err != nil {
+		return err
+	}
+
+	clearing, err := r.ledger.WithTx(tx).SystemAccount(ledger.SystemPaystackClearing, account.Currency)
+	if err != nil {
+		return err
+	}
+
+	if direction == ledger.Credit {
+		return r.post(tx, reference, description, ledger.DebitLine(clearing, amount), ledger.CreditLine(account, amount))
+	}
+	return r.post(tx, reference, description, ledger.DebitLine(account, amount), ledger.CreditLine(clearing, amount))
+}
+
+// feeAccounts loads the accounts a paystack movement that carries a fee posts to, the wallet's and the
+// clearing and FEES accounts in its currency
+func (r *repository) feeAccounts(tx *gorm.DB, walletID string) (account, clearing, fees *ledger.Account, err error) {
+	account, err = r.walletAccount(tx, walletID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	clearing, err = r.ledger.WithTx(tx).SystemAccount(ledger.SystemPaystackClearing, account.Currency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fees, err = r.ledger.WithTx(tx).SystemAccount(ledger.SystemFees, account.Currency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return account, clearing, fees, nil
+}
+
+// post writes a journal and refuses to commit if any wallet it touched drifted from its ledger account
+func (r *repository) post(tx *gorm.DB, reference, description string, lines ...ledger.Line) error {
+	if _, err := r.ledger.WithTx(tx).Post(reference, description, lines...); err != nil {
+		return err
+	}
+
+	accountIDs := make([]uuid.UUID, 0, len(lines))
+	for _, l := range lines {
+		accountIDs = append(accountIDs, l.AccountID)
+	}
+
+	var drifted []string
+	err := tx.Table("wallets").
+		Select("wallets.wallet_number").
+		Joins("JOIN ledger_accounts ON ledger_accounts.wallet_id = wallets.id").
+		Where("ledger_accounts.id IN ? AND wallets.balance <> ledger_accounts.balance", accountIDs).
+		Scan(&drifted).Error
+	if err != nil {
+		return err
+	}
+
+	if len(drifted) > 0 {
+		return fmt.Errorf("%w: %v", ErrLedgerMismatch, drifted)
+	}
+
+	return nil
+}
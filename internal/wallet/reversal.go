@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+// TransferReference strips the -debit or -credit suffix so either leg's reference names the transfer
+func TransferReference(reference string) string {
+	if base, ok := strings.CutSuffix(reference, "-debit"); ok {
+		return base
+	}
+	return strings.TrimSuffix(reference, "-credit")
+}
+
+type ReverseTransferRequest struct {
+	// Amount of zero reverses whatever is left of the transfer
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+	Pin    string `json:"pin"`
+}
+
+// ReverseTransfer lets the recipient of a transfer send it back in whole or in part. Only the recipient can,
+// so a sender cannot pull money back on their own, and it needs their available balance to cover it.
+func (h *Handler) ReverseTransfer(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+	reference := TransferReference(mux.Vars(r)["reference"])
+
+	var req ReverseTransferRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Amount < 0 {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Invalid amount", nil)
+		return
+	}
+
+	received, err := h.Repo.GetTransactionByReference(reference + "-credit")
+	if err != nil || received.Category != CategoryTransfer {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Transfer not found", nil)
+		return
+	}
+
+	wallet, err := h.Repo.GetWalletByID(received.WalletID.String())
+	if err != nil || wallet.UserID != usr.ID {
+		utils.BuildErrorResponse(w, http.StatusNotFound, "Transfer not found", nil)
+		return
+	}
+
+	if !h.VerifyPin(w, wallet, req.Pin) {
+		return
+	}
+
+	legs, err := h.Repo.ReverseTransfer(reference, req.Amount, false, req.Reason)
+	if err != nil {
+		WriteReversalError(w, err, reference)
+		return
+	}
+
+	h.recordAudit(wallet, audit.ActionTransferReversed, fmt.Sprintf("reversed %d of %s", legs[0].Amount, reference))
+	utils.BuildSuccessResponse(w, http.StatusOK, "Transfer reversed", legs)
+}
+
+// WriteReversalError maps ReverseTransfer's errors onto responses, the admin reversal shares it
+func WriteReversalError(w http.ResponseWriter, err error, reference string) {
+	switch {
+	case errors.Is(err, ErrNotReversible):
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Only completed transfers can be reversed", nil)
+	case errors.Is(err, ErrAlreadyReversed):
+		utils.BuildErrorResponse(w, http.StatusConflict, "Transfer has already been fully reversed", nil)
+	case errors.Is(err, ErrReversalTooLarge):
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Reversal exceeds what is left of the transfer", nil)
+	case err.Error() == "insufficient balance":
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "Recipient's available balance is insufficient", nil)
+	default:
+		logger.Error("Transfer reversal failed", logger.Fields{"error": err.Error(), "reference": reference})
+		utils.BuildErrorResponse(w, http.StatusInternalServerError, "Reversal failed", nil)
+	}
+}
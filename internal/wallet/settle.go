@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+)
+
+// PaymentReport is what a webhook or the verify API says was paid against a deposit
+type PaymentReport struct {
+	Status   string
+	Amount   int64
+	Currency string
+	// WalletID is the wallet_id metadata WalletDeposit sent with the checkout
+	WalletID string
+}
+
+func reportFromVerify(remote *paystack.Transaction) PaymentReport {
+	walletID, _ := remote.Metadata["wallet_id"].(string)
+	return PaymentReport{Status: remote.Status, Amount: remote.Amount, Currency: remote.Currency, WalletID: walletID}
+}
+
+// mismatch lists how a successful payment differs from the deposit, it is empty when they agree
+func (p PaymentReport) mismatch(tx *Transaction, wallet *Wallet) string {
+	var problems []string
+	if p.Amount != tx.Amount {
+		problems = append(problems, fmt.Sprintf("amount %d, expected %d", p.Amount, tx.Amount))
+	}
+	if !strings.EqualFold(p.Currency, wallet.Currency) {
+		problems = append(problems, fmt.Sprintf("currency %q, expected %s", p.Currency, wallet.Currency))
+	}
+	if p.WalletID != tx.WalletID.String() {
+		problems = append(problems, fmt.Sprintf("wallet_id %q, expected %s", p.WalletID, tx.WalletID))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// settleDeposit credits a deposit only when the reported payment matches it. A webhook report that
+// disagrees is checked again against the verify API when recheck is set, since the webhook body is
+// the less trusted source. A deposit that still does not match is moved to REVIEW.
+func settleDeposit(ctx context.Context, repo Repository, client paystack.Client, tx *Transaction, report PaymentReport, recheck bool) (TransactionStatus, error) {
+	wallet, err := repo.GetWalletByID(tx.WalletID.String())
+	if err != nil {
+		return tx.Status, err
+	}
+
+	reason := report.mismatch(tx, wallet)
+	if reason != "" && recheck {
+		remote, err := client.VerifyTransaction(ctx, tx.Reference)
+		if err != nil {
+			return tx.Status, fmt.Errorf("verify after mismatch (%s): %w", reason, err)
+		}
+
+		if remote.Status != "success" {
+			reason = fmt.Sprintf("webhook reported %s but verify returned status %s", reason, remote.Status)
+		} else if verified := reportFromVerify(remote).mismatch(tx, wallet); verified != "" {
+			reason = "verify reported " + verified
+		} else {
+			logger.Warn("Settle: Webhook disagreed with verify, using verify", logger.Fields{"reference": tx.Reference, "webhook": reason})
+			reason = ""
+		}
+	}
+
+	if reason != "" {
+		logger.Error("Settle: Payment does not match deposit, holding for review", logger.Fields{"reference": tx.Reference, "reason": reason})
+		if err := repo.FlagForReview(tx.Reference, reason); err != nil {
+			return tx.Status, err
+		}
+		return TransactionReview, nil
+	}
+
+	if err := repo.ProcessDeposit(tx.Reference); err != nil {
+		return tx.Status, err
+	}
+	return TransactionSuccess, nil
+}
@@ -0,0 +1,166 @@
+package wallettest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/audit"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/notification"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Pin is the PIN of the wallet NewEnv creates
+const Pin = "1234"
+
+// Env is a wallet.Handler over a Repository, with one user whose NGN wallet 0123456789 has the PIN Pin
+type Env struct {
+	Handler       *wallet.Handler
+	Repo          *Repository
+	Fees          *Fees
+	Notifications *Notifications
+	User          user.User
+	Wallet        *wallet.Wallet
+}
+
+func NewEnv(t *testing.T, balance int64) *Env {
+	t.Helper()
+
+	repo := NewRepository()
+	usr := user.User{ID: uuid.New(), Email: "user@example.com", Name: "Test User"}
+
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(Pin), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	w := &wallet.Wallet{UserID: usr.ID, WalletNumber: "0123456789", Balance: balance, Currency: "NGN", PinHash: string(pinHash)}
+	require.NoError(t, repo.CreateWallet(w))
+
+	cfg := config.Config{
+		MinTransactionAmounts: map[string]int64{"NGN": 10000, "GHS": 100},
+		PinMaxAttempts:        3,
+		PinLockDuration:       time.Minute,
+		BatchMaxItems:         3,
+		RequestDefaultExpiry:  time.Hour,
+		RequestMaxExpiry:      24 * time.Hour,
+		EscrowDefaultRelease:  24 * time.Hour,
+		EscrowMaxRelease:      7 * 24 * time.Hour,
+	}
+	fees := &Fees{}
+	notifications := &Notifications{}
+
+	return &Env{
+		Handler:       wallet.NewHandler(cfg, repo, nil, &memoryAudit{}, nil, notifications, fees, nil, nil, nil),
+		Repo:          repo,
+		Fees:          fees,
+		Notifications: notifications,
+		User:          usr,
+		Wallet:        w,
+	}
+}
+
+// AddWallet gives a new user an empty wallet numbered number in currency
+func (e *Env) AddWallet(t *testing.T, number, currency string) *wallet.Wallet {
+	t.Helper()
+	w := &wallet.Wallet{UserID: uuid.New(), WalletNumber: number, Currency: currency}
+	require.NoError(t, e.Repo.CreateWallet(w))
+	return w
+}
+
+// Do calls handler as the env's User with body sent as JSON
+func (e *Env) Do(handler http.HandlerFunc, method, path string, body interface{}, vars map[string]string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserKey, e.User))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// DecodeData returns the data of a success response
+func DecodeData(t *testing.T, rr *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// Fees prices with fee.Match over the schedules added to it, like the database repository does over active ones
+type Fees struct {
+	fee.Repository
+
+	mu        sync.Mutex
+	schedules []fee.Schedule
+}
+
+func (m *Fees) Add(s fee.Schedule) uuid.UUID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID, s.Active, s.CreatedAt = uuid.New(), true, time.Now()
+	m.schedules = append(m.schedules, s)
+	return s.ID
+}
+
+func (m *Fees) Charge(category fee.Category, currency string, amount int64, apiKeyID *uuid.UUID, userTier string) (fee.Charge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var candidates []fee.Schedule
+	for _, s := range m.schedules {
+		if s.Category == category && s.Currency == currency && s.Active {
+			candidates = append(candidates, s)
+		}
+	}
+	schedule := fee.Match(candidates, apiKeyID, userTier)
+	if schedule == nil {
+		return fee.Charge{}, nil
+	}
+	return fee.Charge{ScheduleID: &schedule.ID, Amount: schedule.Compute(amount)}, nil
+}
+
+// Notifications keeps what was sent in order
+type Notifications struct {
+	notification.Repository
+
+	mu   sync.Mutex
+	sent []notification.Notification
+}
+
+func (m *Notifications) Create(n *notification.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, *n)
+	return nil
+}
+
+func (m *Notifications) Sent() []notification.Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]notification.Notification(nil), m.sent...)
+}
+
+type memoryAudit struct{}
+
+func (memoryAudit) Record(entry *audit.Log) error { return nil }
@@ -0,0 +1,241 @@
+// Package wallettest provides in-memory wallets for testing the packages built on them.
+package wallettest
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/wallet"
+	"gorm.io/gorm"
+)
+
+// Repository is an in-memory wallet.Repository covering wallets, PINs and transfers, methods it does not have
+// panic through the nil embed. Wallets are stored as given, so a test can read balances off its own pointers.
+type Repository struct {
+	wallet.Repository
+
+	mu           sync.Mutex
+	wallets      map[uuid.UUID]*wallet.Wallet
+	transactions map[string]*wallet.Transaction
+}
+
+func NewRepository() *Repository {
+	return &Repository{
+		wallets:      make(map[uuid.UUID]*wallet.Wallet),
+		transactions: make(map[string]*wallet.Transaction),
+	}
+}
+
+// Transactions returns every transaction recorded so far keyed by reference
+func (m *Repository) Transactions() map[string]wallet.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txs := make(map[string]wallet.Transaction, len(m.transactions))
+	for reference, tx := range m.transactions {
+		txs[reference] = *tx
+	}
+	return txs
+}
+
+func (m *Repository) CreateWallet(w *wallet.Wallet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now()
+	}
+	m.wallets[w.ID] = w
+	return nil
+}
+
+func (m *Repository) GetWalletByID(walletID string) (*wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wallets[uuid.MustParse(walletID)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (m *Repository) GetWalletByUserID(userID, currency string) (*wallet.Wallet, error) {
+	return m.find(func(w *wallet.Wallet) bool { return w.UserID.String() == userID && w.Currency == currency })
+}
+
+func (m *Repository) GetWalletByNumber(number string) (*wallet.Wallet, error) {
+	return m.find(func(w *wallet.Wallet) bool { return w.WalletNumber == number })
+}
+
+func (m *Repository) GetWalletsByUserID(userID string) ([]wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var wallets []wallet.Wallet
+	for _, w := range m.wallets {
+		if w.UserID.String() == userID {
+			wallets = append(wallets, *w)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].CreatedAt.Before(wallets[j].CreatedAt) })
+	return wallets, nil
+}
+
+func (m *Repository) find(match func(w *wallet.Wallet) bool) (*wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.wallets {
+		if match(w) {
+			cp := *w
+			return &cp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *Repository) CreateTransaction(tx *wallet.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transactions[tx.Reference] = tx
+	return nil
+}
+
+func (m *Repository) GetTransactionByReference(ref string) (*wallet.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.transactions[ref]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	cp := *tx
+	return &cp, nil
+}
+
+func (m *Repository) TransferFunds(fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := m.wallets[uuid.MustParse(fromID)]
+	if from.AvailableBalance() < amount+charge.Amount {
+		return errors.New("insufficient balance")
+	}
+	to := m.wallets[uuid.MustParse(toID)]
+	from.Balance -= amount + charge.Amount
+	to.Balance += amount
+	m.transactions[reference+"-debit"] = &wallet.Transaction{WalletID: from.ID, Reference: reference + "-debit", Category: wallet.CategoryTransfer, Type: wallet.TransactionDebit, Amount: amount, Fee: charge.Amount, FeeScheduleID: charge.ScheduleID, Status: wallet.TransactionSuccess, SenderWalletNumber: &senderNumber, RecipientWalletNumber: &recipientNumber, Description: description}
+	m.transactions[reference+"-credit"] = &wallet.Transaction{WalletID: to.ID, Reference: reference + "-credit", Category: wallet.CategoryTransfer, Type: wallet.TransactionCredit, Amount: amount, Status: wallet.TransactionSuccess, SenderWalletNumber: &senderNumber, RecipientWalletNumber: &recipientNumber, Description: description}
+	return nil
+}
+
+// Transfer, ToSystem, FromSystem, SetAside and PutBack make Repository a wallet.Funds too, ignoring the
+// database transaction and keeping no system accounts
+
+func (m *Repository) Transfer(_ *gorm.DB, fromID, toID, senderNumber, recipientNumber, reference string, amount int64, charge fee.Charge, description string) error {
+	return m.TransferFunds(fromID, toID, senderNumber, recipientNumber, reference, amount, charge, description)
+}
+
+func (m *Repository) ToSystem(_ *gorm.DB, walletID, code, reference, description string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	if w.AvailableBalance() < amount {
+		return errors.New("insufficient balance")
+	}
+	w.Balance -= amount
+	return nil
+}
+
+func (m *Repository) FromSystem(_ *gorm.DB, walletID, code, reference, description string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[uuid.MustParse(walletID)].Balance += amount
+	return nil
+}
+
+func (m *Repository) SetAside(_ *gorm.DB, walletID string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	if w.AvailableBalance() < amount {
+		return errors.New("insufficient balance")
+	}
+	w.PocketBalance += amount
+	return nil
+}
+
+func (m *Repository) PutBack(_ *gorm.DB, walletID string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[uuid.MustParse(walletID)].PocketBalance -= amount
+	return nil
+}
+
+// userWallets mirrors the repository applying PIN state to every wallet of the same user
+func (m *Repository) userWallets(walletID string) []*wallet.Wallet {
+	owner := m.wallets[uuid.MustParse(walletID)].UserID
+	var wallets []*wallet.Wallet
+	for _, w := range m.wallets {
+		if w.UserID == owner {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets
+}
+
+func (m *Repository) ClaimPinAttempt(walletID string, maxAttempts int, now time.Time) (*wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	if w.PinLocked(now) || w.FailedPinAttempts >= maxAttempts {
+		cp := *w
+		return &cp, wallet.ErrPinLocked
+	}
+	for _, uw := range m.userWallets(walletID) {
+		uw.FailedPinAttempts++
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (m *Repository) RecordFailedPinAttempt(walletID string, maxAttempts int, lockFor time.Duration) (*wallet.Wallet, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.wallets[uuid.MustParse(walletID)]
+	locked := w.FailedPinAttempts >= maxAttempts
+	if locked {
+		lockedUntil := time.Now().Add(lockFor)
+		for _, uw := range m.userWallets(walletID) {
+			uw.PinLockedUntil = &lockedUntil
+			uw.FailedPinAttempts = 0
+		}
+	}
+	cp := *w
+	return &cp, locked, nil
+}
+
+func (m *Repository) ResetPinAttempts(walletID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range m.userWallets(walletID) {
+		w.FailedPinAttempts = 0
+		w.PinLockedUntil = nil
+	}
+	return nil
+}
+
+func (m *Repository) UpdatePin(walletID, pinHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, w := range m.userWallets(walletID) {
+		w.PinHash = pinHash
+		w.FailedPinAttempts = 0
+		w.PinLockedUntil = nil
+		w.PinChangedAt = &now
+	}
+	return nil
+}
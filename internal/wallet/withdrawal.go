@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/internal/beneficiary"
+	"github.com/zjoart/go-paystack-wallet/internal/fee"
+	"github.com/zjoart/go-paystack-wallet/internal/user"
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/utils"
+)
+
+type WithdrawRequest struct {
+	Amount        int64  `json:"amount"` // in the currency's minor unit, e.g. Kobo
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+	BankCode      string `json:"bank_code"`
+	BeneficiaryID string `json:"beneficiary_id"`
+	Pin           string `json:"pin"`
+	Description   string `json:"description"`
+	Currency      string `json:"currency"`
+}
+
+func (h *Handler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	usr, _ := r.Context().Value(utils.UserKey).(user.User)
+
+	var req WithdrawRequest
+	if status, err := utils.DecodeJSONBody(w, r, &req); err != nil {
+		utils.BuildErrorResponse(w, status, "Invalid request", map[string]string{"error": err.Error()})
+		return
+	}
+
+	currency, ok := ResolveCurrency(w, req.Currency)
+	if !ok || !h.CheckAmount(w, req.Amount, currency) {
+		return
+	}
+
+	var saved *beneficiary.Beneficiary
+	if req.BeneficiaryID != "" {
+		b, err := h.Beneficiaries.GetByID(req.BeneficiaryID, usr.ID.String())
+		if err != nil {
+			utils.BuildErrorResponse(w, http.StatusNotFound, "Beneficiary not found", nil)
+			return
+		}
+		if b.Type != beneficiary.TypeBank {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Beneficiary is not a bank beneficiary", nil)
+			return
+		}
+		if b.Currency != "" && b.Currency != currency {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Beneficiary is a %s bank account, withdraw from a %s wallet", b.Currency, b.Currency), nil)
+			return
+		}
+		saved = b
+		req.AccountNumber = b.AccountNumber
+		req.AccountName = b.AccountName
+		req.BankCode = b.BankCode
+	}
+
+	if req.AccountNumber == "" || req.BankCode == "" || req.AccountName == "" {
+		utils.BuildErrorResponse(w, http.StatusBadRequest, "beneficiary_id or account_number, account_name and bank_code are required", nil)
+		return
+	}
+
+	wallet, ok := h.WalletFor(w, usr, currency)
+	if !ok {
+		return
+	}
+
+	if !h.VerifyPin(w, wallet, req.Pin) {
+		return
+	}
+
+	charge, ok := h.Charge(w, r, fee.CategoryWithdrawal, wallet.Currency, req.Amount)
+	if !ok {
+		return
+	}
+
+	var recipientCode string
+	if saved != nil {
+		recipientCode = saved.RecipientCode
+	}
+
+	if recipientCode == "" {
+		var err error
+		recipientCode, err = h.createTransferRecipient(r.Context(), req.AccountName, req.AccountNumber, req.BankCode, wallet.Currency)
+		if err != nil {
+			logger.Error("Withdraw: Failed to create transfer recipient", logger.Fields{"error": err.Error(), "wallet_id": wallet.ID.String()})
+			utils.BuildErrorResponse(w, http.StatusBadGateway, "Failed to create transfer recipient", map[string]string{"error": err.Error()})
+			return
+		}
+
+		// cache the recipient on the beneficiary so later withdrawals skip this call
+		if saved != nil {
+			if err := h.Beneficiaries.UpdateRecipientCode(saved.ID.String(), recipientCode); err != nil {
+				logger.Warn("Withdraw: Failed to save recipient code", logger.Fields{"error": err.Error(), "beneficiary_id": saved.ID.String()})
+			}
+		}
+	}
+
+	reference := fmt.Sprintf("wdr-%d", time.Now().UnixNano())
+	description := req.Description
+	if description == "" {
+		description = fmt.Sprintf("Withdrawal to %s (%s)", req.AccountName, req.AccountNumber)
+	}
+
+	if err := h.Repo.InitiateWithdrawal(wallet.ID.String(), reference, req.Amount, charge, description); err != nil {
+		if err.Error() == "insufficient balance" {
+			utils.BuildErrorResponse(w, http.StatusBadRequest, "Insufficient balance", nil)
+		} else {
+			utils.BuildErrorResponse(w, http.StatusInternalServerError, "Withdrawal failed", map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	// the hold is only released when paystack turned the transfer down. After a timeout or a 5xx the transfer
+	// may have gone through, so the withdrawal stays pending for the webhook or the reconciler to settle.
+	transferStatus, err := h.initiateTransfer(r.Context(), reference, recipientCode, req.Amount, description)
+	if transferStatus == "failed" || paystack.IsRejected(err) {
+		if err == nil {
+			err = fmt.Errorf("transfer was rejected by paystack")
+		}
+		logger.Error("Withdraw: Transfer rejected, releasing hold", logger.Fields{"error": err.Error(), "reference": reference})
+		if revErr := h.Repo.ReverseWithdrawal(reference); revErr != nil {
+			logger.Error("Withdraw: Failed to release hold", logger.Fields{"error": revErr.Error(), "reference": reference})
+		}
+		utils.BuildErrorResponse(w, http.StatusBadGateway, "Failed to initiate transfer", map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Warn("Withdraw: Transfer outcome unknown, leaving withdrawal pending", logger.Fields{"error": err.Error(), "reference": reference})
+	}
+
+	utils.BuildSuccessResponse(w, http.StatusAccepted, "Withdrawal initiated", map[string]interface{}{
+		"reference": reference,
+		"amount":    req.Amount,
+		"fee":       charge.Amount,
+		"status":    TransactionPending,
+	})
+}
+
+func (h *Handler) createTransferRecipient(ctx context.Context, name, accountNumber, bankCode, currency string) (string, error) {
+	recipient, err := h.Paystack.CreateTransferRecipient(ctx, paystack.TransferRecipientRequest{
+		Type:          "nuban",
+		Name:          name,
+		AccountNumber: accountNumber,
+		BankCode:      bankCode,
+		Currency:      currency,
+	})
+	if err != nil {
+		return "", err
+	}
+	return recipient.RecipientCode, nil
+}
+
+func (h *Handler) initiateTransfer(ctx context.Context, reference, recipientCode string, amount int64, reason string) (string, error) {
+	transfer, err := h.Paystack.InitiateTransfer(ctx, paystack.TransferRequest{
+		Source:    "balance",
+		Amount:    amount,
+		Recipient: recipientCode,
+		Reference: reference,
+		Reason:    reason,
+	})
+	if err != nil {
+		return "", err
+	}
+	return transfer.Status, nil
+}
@@ -3,67 +3,234 @@ package wallet
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
 	"github.com/zjoart/go-paystack-wallet/pkg/config"
 	"github.com/zjoart/go-paystack-wallet/pkg/events"
 	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
 )
 
+const (
+	workerReadCount = 10
+	workerReadBlock = 2 * time.Second
+	// maxDeliveries caps how often a reclaimed entry is retried before it is treated as poison
+	maxDeliveries = 5
+	// maxAttempts is how often an event is handled in a row before it is moved to the DLQ
+	maxAttempts = 3
+)
+
+// EventStream is the part of the Redis stream client the worker consumes webhook events through,
+// *events.RedisClient in production
+type EventStream interface {
+	EnsureGroup(ctx context.Context) error
+	MigrateLegacyQueue(ctx context.Context) (int, error)
+	ReadEvents(ctx context.Context, consumer string, count int64, block time.Duration) ([]events.StreamMessage, error)
+	ClaimStale(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]events.StreamMessage, error)
+	Ack(ctx context.Context, id string) error
+	PushToDLQ(ctx context.Context, data []byte, reason string, attempts int) error
+}
+
 type WebhookWorker struct {
-	Config      config.Config
-	Repo        Repository
-	RedisClient *events.RedisClient
+	Config     config.Config
+	Repo       Repository
+	Deliveries webhook.Repository
+	Stream     EventStream
+	Paystack   paystack.Client
+
+	name string
+	// retryDelay is the wait before the second attempt at an event, each later attempt waits one more
+	retryDelay time.Duration
+	stop       chan struct{}
+	wg         sync.WaitGroup
 }
 
-func NewWebhookWorker(cfg config.Config, repo Repository, redisClient *events.RedisClient) *WebhookWorker {
-	return &WebhookWorker{Config: cfg, Repo: repo, RedisClient: redisClient}
+func NewWebhookWorker(cfg config.Config, repo Repository, deliveries webhook.Repository, stream EventStream, paystackClient paystack.Client) *WebhookWorker {
+	host, _ := os.Hostname()
+	return &WebhookWorker{
+		Config:     cfg,
+		Repo:       repo,
+		Deliveries: deliveries,
+		Stream:     stream,
+		Paystack:   paystackClient,
+		name:       fmt.Sprintf("%s-%d", host, os.Getpid()),
+		retryDelay: time.Second,
+		stop:       make(chan struct{}),
+	}
 }
 
 func (w *WebhookWorker) Start() {
-	logger.Info("Starting webhook worker...")
-	go w.processEvents()
+	logger.Info("Starting webhook worker...", logger.Fields{"consumers": w.Config.WebhookConsumers})
+
+	ctx := context.Background()
+	if err := w.Stream.EnsureGroup(ctx); err != nil {
+		logger.Error("WebhookWorker: Failed to create consumer group", logger.Fields{"error": err.Error()})
+	}
+
+	if moved, err := w.Stream.MigrateLegacyQueue(ctx); err != nil {
+		logger.Error("WebhookWorker: Failed to migrate legacy queue", logger.Fields{"error": err.Error(), "moved": moved})
+	} else if moved > 0 {
+		logger.Info("WebhookWorker: Migrated legacy queue to stream", logger.Fields{"moved": moved})
+	}
+
+	for i := 0; i < w.Config.WebhookConsumers; i++ {
+		w.wg.Add(1)
+		go w.consume(fmt.Sprintf("%s-%d", w.name, i))
+	}
+
+	w.wg.Add(1)
+	go w.reclaim(w.name + "-reclaimer")
 }
 
-func (w *WebhookWorker) processEvents() {
-	for {
+// Stop waits for consumers to finish the entries they already read, anything left unacknowledged
+// when ctx expires is reclaimed by the next worker to start
+func (w *WebhookWorker) Stop(ctx context.Context) error {
+	close(w.stop)
 
-		result, err := w.RedisClient.Client.BLPop(context.Background(), 5*time.Second, events.WebhookQueue).Result()
-		if err != nil {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Info("Webhook worker stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *WebhookWorker) stopping() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *WebhookWorker) consume(consumer string) {
+	defer w.wg.Done()
 
+	for !w.stopping() {
+		messages, err := w.Stream.ReadEvents(context.Background(), consumer, workerReadCount, workerReadBlock)
+		if err != nil {
+			logger.Error("WebhookWorker: Failed to read events", logger.Fields{"error": err.Error(), "consumer": consumer})
+			// the group disappears if redis was flushed or restarted without persistence
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				w.Stream.EnsureGroup(context.Background())
+			}
+			w.sleep(workerReadBlock)
 			continue
 		}
 
-		eventData := []byte(result[1])
-		var event events.WebhookEvent
-		if err := json.Unmarshal(eventData, &event); err != nil {
-			logger.Error("WebhookWorker: Failed to unmarshal event", logger.Fields{"error": err.Error(), "data": string(eventData)})
-			w.moveToDLQ(eventData)
-			continue
+		for _, msg := range messages {
+			w.process(msg)
 		}
+	}
+}
+
+func (w *WebhookWorker) reclaim(consumer string) {
+	defer w.wg.Done()
+
+	for !w.stopping() {
+		messages, err := w.Stream.ClaimStale(context.Background(), consumer, w.Config.WebhookClaimIdle, workerReadCount)
+		if err != nil {
+			logger.Error("WebhookWorker: Failed to reclaim stale events", logger.Fields{"error": err.Error()})
+		}
+
+		for _, msg := range messages {
+			w.processReclaimed(msg)
+		}
+
+		if len(messages) == 0 {
+			w.sleep(w.Config.WebhookClaimIdle / 2)
+		}
+	}
+}
 
-		w.handleEvent(event, eventData)
+// processReclaimed retries an entry left unacknowledged, or parks it in the DLQ once it has been delivered
+// too often
+func (w *WebhookWorker) processReclaimed(msg events.StreamMessage) {
+	logger.Warn("WebhookWorker: Reclaimed stale event", logger.Fields{"id": msg.ID, "deliveries": msg.Deliveries})
+	if msg.Deliveries > maxDeliveries {
+		if w.moveToDLQ(msg.Data, fmt.Sprintf("gave up after %d deliveries without an acknowledgement", msg.Deliveries), int(msg.Deliveries)) {
+			w.ack(msg.ID)
+		}
+		return
 	}
+	w.process(msg)
 }
 
-func (w *WebhookWorker) handleEvent(event events.WebhookEvent, rawData []byte) {
-	maxRetries := 3
-	for i := 0; i < maxRetries; i++ {
-		var err error
+// sleep waits for d or until Stop is called
+func (w *WebhookWorker) sleep(d time.Duration) {
+	select {
+	case <-w.stop:
+	case <-time.After(d):
+	}
+}
+
+func (w *WebhookWorker) process(msg events.StreamMessage) {
+	var event events.WebhookEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		logger.Error("WebhookWorker: Failed to unmarshal event", logger.Fields{"error": err.Error(), "data": string(msg.Data)})
+		if w.moveToDLQ(msg.Data, "invalid payload: "+err.Error(), 0) {
+			w.ack(msg.ID)
+		}
+		return
+	}
+
+	// an entry that could not be handled or parked in the DLQ stays pending and is reclaimed later
+	if w.handleEvent(event, msg.Data) {
+		w.ack(msg.ID)
+	}
+}
+
+func (w *WebhookWorker) ack(id string) {
+	if err := w.Stream.Ack(context.Background(), id); err != nil {
+		logger.Error("WebhookWorker: Failed to acknowledge event", logger.Fields{"error": err.Error(), "id": id})
+	}
+}
+
+// handleEvent reports whether the event is done with, either processed or moved to the DLQ
+func (w *WebhookWorker) handleEvent(event events.WebhookEvent, rawData []byte) bool {
+	var err error
+	for i := 0; i < maxAttempts; i++ {
 		switch event.Event {
 		case "charge.success":
-			err = w.Repo.ProcessDeposit(event.Reference, event.Amount)
+			err = w.handleChargeSuccess(event)
 		case "charge.failed":
 			err = w.Repo.ProcessFailedTransaction(event.Reference)
+		case "transfer.success":
+			err = w.Repo.CompleteWithdrawal(event.Reference)
+		case "transfer.failed", "transfer.reversed":
+			err = w.Repo.ReverseWithdrawal(event.Reference)
+		case "refund.processed":
+			err = w.handleRefundProcessed(event)
+		case "refund.failed":
+			err = w.handleRefundFailed(event)
+		case "charge.dispute.create", "charge.dispute.resolve":
+			err = w.handleDispute(event)
+		case "dedicatedaccount.assign.success", "dedicatedaccount.assign.failed":
+			err = w.handleDedicatedAccountAssigned(event)
 		default:
 
 			logger.Warn("WebhookWorker: Unknown event type", logger.Fields{"event": event.Event, "reference": event.Reference})
-			return
+			w.recordOutcome(event, webhook.DeliveryIgnored, "unknown event type")
+			return true
 		}
 
 		if err == nil {
 			logger.Info("WebhookWorker: Successfully processed event", logger.Fields{"event": event.Event, "reference": event.Reference})
-			return
+			w.recordOutcome(event, webhook.DeliveryProcessed, "")
+			return true
 		}
 
 		logger.Warn("WebhookWorker: Failed to process event, retrying", logger.Fields{
@@ -72,15 +239,155 @@ func (w *WebhookWorker) handleEvent(event events.WebhookEvent, rawData []byte) {
 			"attempt":   i + 1,
 			"error":     err.Error(),
 		})
-		time.Sleep(time.Duration(i+1) * time.Second)
+		time.Sleep(time.Duration(i+1) * w.retryDelay)
 	}
 
 	logger.Error("WebhookWorker: Max retries exhausted, moving to DLQ", logger.Fields{"reference": event.Reference})
-	w.moveToDLQ(rawData)
+	w.recordOutcome(event, webhook.DeliveryFailed, err.Error())
+	return w.moveToDLQ(rawData, err.Error(), maxAttempts)
+}
+
+func (w *WebhookWorker) handleChargeSuccess(event events.WebhookEvent) error {
+	if event.Channel == dedicatedAccountChannel {
+		return w.handleBankTransfer(event)
+	}
+
+	tx, err := w.Repo.GetTransactionByReference(event.Reference)
+	if err != nil {
+		return err
+	}
+	if tx.Status == TransactionSuccess || tx.Status == TransactionReview {
+		return nil
+	}
+
+	report := PaymentReport{Status: event.Status, Amount: event.Amount, Currency: event.Currency, WalletID: event.WalletID}
+	_, err = settleDeposit(context.Background(), w.Repo, w.Paystack, tx, report, true)
+	return err
+}
+
+func (w *WebhookWorker) handleRefundProcessed(event events.WebhookEvent) error {
+	if event.Refund == nil {
+		return fmt.Errorf("refund event for %s has no refund details", event.Reference)
+	}
+	return w.Repo.ProcessRefund(event.Reference, "rfd-"+event.Refund.ID, event.Amount)
+}
+
+// handleRefundFailed gives back a refund the wallet asked for, one started elsewhere was never debited
+func (w *WebhookWorker) handleRefundFailed(event events.WebhookEvent) error {
+	if event.Refund == nil {
+		return fmt.Errorf("refund event for %s has no refund details", event.Reference)
+	}
+	logger.Warn("WebhookWorker: Refund failed at Paystack", logger.Fields{"reference": event.Reference, "amount": event.Amount})
+	return w.Repo.FailRefund(event.Reference, "rfd-"+event.Refund.ID, event.Amount)
+}
+
+func (w *WebhookWorker) handleDispute(event events.WebhookEvent) error {
+	if event.Dispute == nil {
+		return fmt.Errorf("dispute event for %s has no dispute details", event.Reference)
+	}
+
+	dispute := Dispute{
+		PaystackID:           event.Dispute.ID,
+		TransactionReference: event.Reference,
+		Amount:               event.Amount,
+		RefundAmount:         event.Dispute.RefundAmount,
+		Currency:             event.Currency,
+		Status:               event.Status,
+		Category:             event.Dispute.Category,
+		Resolution:           event.Dispute.Resolution,
+		DueAt:                event.Dispute.DueAt,
+		ResolvedAt:           event.Dispute.ResolvedAt,
+	}
+	if tx, err := w.Repo.GetTransactionByReference(event.Reference); err == nil {
+		dispute.WalletID = &tx.WalletID
+	}
+
+	if event.Event == "charge.dispute.create" {
+		logger.Warn("WebhookWorker: Dispute opened", logger.Fields{"reference": event.Reference, "dispute_id": event.Dispute.ID, "due_at": event.Dispute.DueAt})
+	}
+	return w.Repo.RecordDispute(&dispute)
+}
+
+func (w *WebhookWorker) handleDedicatedAccountAssigned(event events.WebhookEvent) error {
+	wallet, err := w.Repo.GetWalletByCustomerCode(event.CustomerCode)
+	if err != nil {
+		return fmt.Errorf("no wallet for customer %s: %w", event.CustomerCode, err)
+	}
+
+	account := DedicatedAccount{Status: DedicatedAccountFailed}
+	if event.Event == "dedicatedaccount.assign.success" && event.DedicatedAccount != nil {
+		account = DedicatedAccount{
+			Status:        DedicatedAccountActive,
+			AccountNumber: event.DedicatedAccount.AccountNumber,
+			AccountName:   event.DedicatedAccount.AccountName,
+			BankName:      event.DedicatedAccount.BankName,
+			BankSlug:      event.DedicatedAccount.BankSlug,
+			PaystackID:    event.DedicatedAccount.AccountID,
+		}
+	} else {
+		logger.Warn("WebhookWorker: Dedicated account assignment failed", logger.Fields{"wallet_id": wallet.ID.String(), "customer_code": event.CustomerCode})
+	}
+
+	return w.Repo.UpdateDedicatedAccount(wallet.ID.String(), account)
+}
+
+// handleBankTransfer credits a transfer into a dedicated account. There is no pending deposit to
+// compare against, so the amount is only trusted once the verify API agrees with the webhook.
+func (w *WebhookWorker) handleBankTransfer(event events.WebhookEvent) error {
+	wallet, err := w.Repo.GetWalletByCustomerCode(event.CustomerCode)
+	if err != nil {
+		return fmt.Errorf("no wallet for customer %s: %w", event.CustomerCode, err)
+	}
+
+	remote, err := w.Paystack.VerifyTransaction(context.Background(), event.Reference)
+	if err != nil {
+		return err
+	}
+
+	deposit := Transaction{
+		WalletID:    wallet.ID,
+		Reference:   event.Reference,
+		Category:    CategoryDeposit,
+		Type:        TransactionCredit,
+		Amount:      remote.Amount,
+		Status:      TransactionSuccess,
+		Description: "Bank transfer to " + wallet.DedicatedAccount.AccountNumber,
+	}
+
+	var problems []string
+	if remote.Status != "success" {
+		problems = append(problems, "verify returned status "+remote.Status)
+	}
+	if remote.Amount != event.Amount {
+		problems = append(problems, fmt.Sprintf("webhook amount %d, verified %d", event.Amount, remote.Amount))
+	}
+	if !strings.EqualFold(remote.Currency, wallet.Currency) {
+		problems = append(problems, fmt.Sprintf("currency %q, wallet is %s", remote.Currency, wallet.Currency))
+	}
+	if len(problems) > 0 {
+		deposit.Status = TransactionReview
+		deposit.ReviewReason = strings.Join(problems, "; ")
+		logger.Error("WebhookWorker: Bank transfer does not check out, holding for review", logger.Fields{"reference": event.Reference, "reason": deposit.ReviewReason})
+	}
+
+	return w.Repo.RecordBankTransferDeposit(&deposit)
+}
+
+// recordOutcome writes the result back onto the stored delivery, events queued before deliveries were
+// stored carry no ID
+func (w *WebhookWorker) recordOutcome(event events.WebhookEvent, status webhook.DeliveryStatus, outcome string) {
+	if event.DeliveryID == "" {
+		return
+	}
+	if err := w.Deliveries.UpdateStatus(event.DeliveryID, status, outcome); err != nil {
+		logger.Error("WebhookWorker: Failed to update delivery", logger.Fields{"error": err.Error(), "delivery_id": event.DeliveryID})
+	}
 }
 
-func (w *WebhookWorker) moveToDLQ(data []byte) {
-	if err := w.RedisClient.PushToDLQ(context.Background(), data); err != nil {
+func (w *WebhookWorker) moveToDLQ(data []byte, reason string, attempts int) bool {
+	if err := w.Stream.PushToDLQ(context.Background(), data, reason, attempts); err != nil {
 		logger.Error("Worker: Failed to push to DLQ", logger.Fields{"error": err.Error()})
+		return false
 	}
+	return true
 }
@@ -0,0 +1,339 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/internal/webhook"
+	"github.com/zjoart/go-paystack-wallet/pkg/config"
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+)
+
+func TestWorkerRefundAndDisputeEvents(t *testing.T) {
+	repo := newMemoryRepo()
+	w := &Wallet{UserID: uuid.New(), WalletNumber: "0123456789", Balance: 50000, Currency: "NGN"}
+	require.NoError(t, repo.CreateWallet(w))
+	require.NoError(t, repo.CreateTransaction(&Transaction{
+		WalletID:  w.ID,
+		Reference: "dep-1",
+		Category:  CategoryDeposit,
+		Type:      TransactionCredit,
+		Amount:    50000,
+		Status:    TransactionSuccess,
+	}))
+
+	worker := &WebhookWorker{Repo: repo}
+	handle := func(body string) {
+		t.Helper()
+		payload, err := webhook.ParsePayload([]byte(body))
+		require.NoError(t, err)
+		require.True(t, worker.handleEvent(payload.ToEvent(""), []byte(body)))
+	}
+
+	refund := `{"event":"refund.processed","data":{"id":77,"transaction_reference":"dep-1","amount":"20000","currency":"NGN","status":"processed"}}`
+	handle(refund)
+	// paystack can deliver the same refund twice
+	handle(refund)
+
+	assert.EqualValues(t, 30000, repo.wallets[w.ID].Balance)
+	require.Contains(t, repo.transactions, "rfd-77")
+	assert.Equal(t, CategoryRefund, repo.transactions["rfd-77"].Category)
+
+	handle(`{"event":"refund.failed","data":{"id":78,"transaction_reference":"dep-1","amount":"10000","status":"failed"}}`)
+	assert.EqualValues(t, 30000, repo.wallets[w.ID].Balance)
+
+	handle(`{"event":"charge.dispute.create","data":{"id":9,"refund_amount":0,"currency":"NGN","status":"awaiting-merchant-feedback","category":"chargeback","transaction":{"id":1,"reference":"dep-1","amount":50000}}}`)
+	require.Contains(t, repo.disputes, int64(9))
+	assert.Equal(t, "awaiting-merchant-feedback", repo.disputes[9].Status)
+	assert.Equal(t, w.ID, *repo.disputes[9].WalletID)
+	assert.EqualValues(t, 50000, repo.disputes[9].Amount)
+
+	handle(`{"event":"charge.dispute.resolve","data":{"id":9,"refund_amount":0,"currency":"NGN","status":"resolved","resolution":"declined","resolvedAt":"2024-06-01T10:00:00.000Z","transaction":{"id":1,"reference":"dep-1","amount":50000}}}`)
+	assert.Equal(t, "resolved", repo.disputes[9].Status)
+	assert.Equal(t, "declined", repo.disputes[9].Resolution)
+	assert.NotNil(t, repo.disputes[9].ResolvedAt)
+
+}
+
+func TestWorkerRefundHeldToDeposit(t *testing.T) {
+	repo := newMemoryRepo()
+	w := &Wallet{UserID: uuid.New(), WalletNumber: "0123456789", Balance: 60000, HeldBalance: 20000, Currency: "NGN"}
+	require.NoError(t, repo.CreateWallet(w))
+	require.NoError(t, repo.CreateTransaction(&Transaction{
+		WalletID:  w.ID,
+		Reference: "dep-1",
+		Category:  CategoryDeposit,
+		Type:      TransactionCredit,
+		Amount:    50000,
+		Status:    TransactionSuccess,
+	}))
+
+	worker := &WebhookWorker{Repo: repo}
+	refund := func(body string) error {
+		t.Helper()
+		payload, err := webhook.ParsePayload([]byte(body))
+		require.NoError(t, err)
+		return worker.handleRefundProcessed(payload.ToEvent(""))
+	}
+
+	require.NoError(t, refund(`{"event":"refund.processed","data":{"id":77,"transaction_reference":"dep-1","amount":"30000","status":"processed"}}`))
+	assert.EqualValues(t, 30000, repo.wallets[w.ID].Balance)
+
+	// more than is left of the deposit
+	err := refund(`{"event":"refund.processed","data":{"id":78,"transaction_reference":"dep-1","amount":"30000","status":"processed"}}`)
+	assert.ErrorIs(t, err, ErrRefundTooLarge)
+
+	// within the deposit but the rest of the balance is held
+	err = refund(`{"event":"refund.processed","data":{"id":79,"transaction_reference":"dep-1","amount":"20000","status":"processed"}}`)
+	require.Error(t, err)
+	assert.Equal(t, "insufficient balance", err.Error())
+	assert.EqualValues(t, 30000, repo.wallets[w.ID].Balance)
+	assert.NotContains(t, repo.transactions, "rfd-79")
+}
+
+func TestWorkerChargeSuccessChecksPayment(t *testing.T) {
+	env := newTestEnv(t, 0)
+	worker := &WebhookWorker{Repo: env.repo, Paystack: env.handler.Paystack}
+	walletID := env.wallet.ID.String()
+
+	deposit := func(reference string, remoteAmount int64, remoteWallet string) {
+		require.NoError(t, env.repo.CreateTransaction(&Transaction{
+			WalletID:  env.wallet.ID,
+			Reference: reference,
+			Category:  CategoryDeposit,
+			Type:      TransactionCredit,
+			Amount:    50000,
+			Status:    TransactionPending,
+		}))
+		env.paystack.AddTransaction(paystack.Transaction{
+			Reference: reference,
+			Status:    "success",
+			Amount:    remoteAmount,
+			Currency:  "NGN",
+			Metadata:  map[string]interface{}{"wallet_id": remoteWallet},
+		})
+	}
+	charge := func(reference string, amount int64, currency, metadataWallet string) {
+		t.Helper()
+		require.True(t, worker.handleEvent(events.WebhookEvent{
+			Event:     "charge.success",
+			Reference: reference,
+			Status:    "success",
+			Amount:    amount,
+			Currency:  currency,
+			WalletID:  metadataWallet,
+		}, nil))
+	}
+
+	deposit("dep-ok", 50000, walletID)
+	charge("dep-ok", 50000, "NGN", walletID)
+	assert.Equal(t, 0, env.paystack.Hits("/transaction/verify/dep-ok"))
+
+	// the webhook body disagrees but paystack's own record matches, so it is credited
+	deposit("dep-stale-body", 50000, walletID)
+	charge("dep-stale-body", 50000, "", "")
+	assert.Equal(t, 1, env.paystack.Hits("/transaction/verify/dep-stale-body"))
+
+	deposit("dep-short", 100, walletID)
+	charge("dep-short", 100, "NGN", walletID)
+
+	other := uuid.NewString()
+	deposit("dep-wrong-wallet", 50000, other)
+	charge("dep-wrong-wallet", 50000, "NGN", other)
+
+	for ref, want := range map[string]TransactionStatus{
+		"dep-ok":           TransactionSuccess,
+		"dep-stale-body":   TransactionSuccess,
+		"dep-short":        TransactionReview,
+		"dep-wrong-wallet": TransactionReview,
+	} {
+		tx, err := env.repo.GetTransactionByReference(ref)
+		require.NoError(t, err)
+		assert.Equal(t, want, tx.Status, ref)
+	}
+
+	short, _ := env.repo.GetTransactionByReference("dep-short")
+	assert.Contains(t, short.ReviewReason, "amount 100, expected 50000")
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.EqualValues(t, 100000, w.Balance)
+}
+
+func TestWorkerBankTransferDeposit(t *testing.T) {
+	env := newTestEnv(t, 0)
+	worker := &WebhookWorker{Repo: env.repo, Paystack: env.handler.Paystack}
+	require.NoError(t, env.repo.SetPaystackCustomer(env.wallet.ID.String(), "CUS_1"))
+
+	body := `{"event":"dedicatedaccount.assign.success","data":{"customer":{"customer_code":"CUS_1"},"dedicated_account":{"id":5,"account_name":"PAYSTACK/user","account_number":"9930000000","currency":"NGN","bank":{"name":"Wema Bank","slug":"wema-bank"}}}}`
+	payload, err := webhook.ParsePayload([]byte(body))
+	require.NoError(t, err)
+	require.True(t, worker.handleEvent(payload.ToEvent(""), []byte(body)))
+
+	w, _ := env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.Equal(t, DedicatedAccountActive, w.DedicatedAccount.Status)
+	assert.Equal(t, "9930000000", w.DedicatedAccount.AccountNumber)
+
+	env.paystack.AddTransaction(paystack.Transaction{Reference: "T123", Status: "success", Amount: 70000, Currency: "NGN", Channel: "dedicated_nuban"})
+	env.paystack.AddTransaction(paystack.Transaction{Reference: "T124", Status: "success", Amount: 100, Currency: "NGN", Channel: "dedicated_nuban"})
+
+	transfer := func(reference string, amount int64) {
+		t.Helper()
+		require.True(t, worker.handleEvent(events.WebhookEvent{
+			Event:        "charge.success",
+			Reference:    reference,
+			Status:       "success",
+			Amount:       amount,
+			Currency:     "NGN",
+			Channel:      "dedicated_nuban",
+			CustomerCode: "CUS_1",
+		}, nil))
+	}
+	transfer("T123", 70000)
+	transfer("T123", 70000)
+	// the webhook claims more than paystack recorded
+	transfer("T124", 900000)
+
+	w, _ = env.repo.GetWalletByUserID(env.user.ID.String(), "NGN")
+	assert.EqualValues(t, 70000, w.Balance)
+
+	held, err := env.repo.GetTransactionByReference("T124")
+	require.NoError(t, err)
+	assert.Equal(t, TransactionReview, held.Status)
+}
+
+// memoryStream is a consumer group of one stream, read entries stay pending until acknowledged
+type memoryStream struct {
+	mu      sync.Mutex
+	unread  []events.StreamMessage
+	pending map[string]events.StreamMessage
+	stale   []events.StreamMessage
+	dlq     []events.DeadLetter
+	dlqErr  error
+}
+
+func newMemoryStream(messages ...events.StreamMessage) *memoryStream {
+	return &memoryStream{unread: messages, pending: map[string]events.StreamMessage{}}
+}
+
+func (m *memoryStream) EnsureGroup(ctx context.Context) error { return nil }
+
+func (m *memoryStream) MigrateLegacyQueue(ctx context.Context) (int, error) { return 0, nil }
+
+func (m *memoryStream) ReadEvents(ctx context.Context, consumer string, count int64, block time.Duration) ([]events.StreamMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.unread) == 0 {
+		// a real read blocks on an empty stream, returning at once is enough to keep the consumer looping
+		return nil, nil
+	}
+	messages := m.unread[:min(int(count), len(m.unread))]
+	m.unread = m.unread[len(messages):]
+	for _, msg := range messages {
+		m.pending[msg.ID] = msg
+	}
+	return messages, nil
+}
+
+func (m *memoryStream) ClaimStale(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]events.StreamMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := m.stale
+	m.stale = nil
+	for _, msg := range messages {
+		m.pending[msg.ID] = msg
+	}
+	return messages, nil
+}
+
+func (m *memoryStream) Ack(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, id)
+	return nil
+}
+
+func (m *memoryStream) PushToDLQ(ctx context.Context, data []byte, reason string, attempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dlqErr != nil {
+		return m.dlqErr
+	}
+	m.dlq = append(m.dlq, events.DeadLetter{Payload: string(data), Reason: reason, Attempts: attempts})
+	return nil
+}
+
+func (m *memoryStream) settled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.unread) == 0 && len(m.pending) == 0
+}
+
+func TestWorkerAcksRetriesAndDeadLetters(t *testing.T) {
+	repo := newMemoryRepo()
+	w := &Wallet{UserID: uuid.New(), WalletNumber: "0123456789", Currency: "NGN"}
+	require.NoError(t, repo.CreateWallet(w))
+	require.NoError(t, repo.CreateTransaction(&Transaction{WalletID: w.ID, Reference: "dep-1", Category: CategoryDeposit, Type: TransactionCredit, Amount: 50000, Status: TransactionPending}))
+
+	failed := events.StreamMessage{ID: "1-0", Data: []byte(`{"event":"charge.failed","reference":"dep-1"}`), Deliveries: 1}
+	unknownDeposit := events.StreamMessage{ID: "2-0", Data: []byte(`{"event":"charge.success","reference":"dep-missing","status":"success"}`), Deliveries: 1}
+	garbled := events.StreamMessage{ID: "3-0", Data: []byte(`{"event":`), Deliveries: 1}
+	stream := newMemoryStream(failed, unknownDeposit, garbled)
+
+	worker := NewWebhookWorker(config.Config{WebhookConsumers: 2, WebhookClaimIdle: 10 * time.Millisecond}, repo, nil, stream, nil)
+	worker.retryDelay = 0
+	worker.Start()
+	require.Eventually(t, stream.settled, 5*time.Second, time.Millisecond)
+	require.NoError(t, worker.Stop(context.Background()))
+
+	// a handled event is acknowledged
+	assert.Equal(t, TransactionFailed, repo.transactions["dep-1"].Status)
+
+	// one that keeps failing is tried maxAttempts times and parked, and so is one that can't be read
+	require.Len(t, stream.dlq, 2)
+	reasons := map[string]events.DeadLetter{}
+	for _, entry := range stream.dlq {
+		reasons[entry.Payload] = entry
+	}
+	assert.Equal(t, maxAttempts, reasons[string(unknownDeposit.Data)].Attempts)
+	assert.Contains(t, reasons[string(unknownDeposit.Data)].Reason, "record not found")
+	assert.Contains(t, reasons[string(garbled.Data)].Reason, "invalid payload")
+}
+
+func TestWorkerReclaimedEvents(t *testing.T) {
+	repo := newMemoryRepo()
+	w := &Wallet{UserID: uuid.New(), WalletNumber: "0123456789", Currency: "NGN"}
+	require.NoError(t, repo.CreateWallet(w))
+	require.NoError(t, repo.CreateTransaction(&Transaction{WalletID: w.ID, Reference: "dep-1", Category: CategoryDeposit, Type: TransactionCredit, Amount: 50000, Status: TransactionPending}))
+
+	stream := newMemoryStream()
+	worker := &WebhookWorker{Repo: repo, Stream: stream}
+
+	// an entry another consumer left unacknowledged is handled again
+	retried := events.StreamMessage{ID: "1-0", Data: []byte(`{"event":"charge.failed","reference":"dep-1"}`), Deliveries: 2}
+	stream.pending[retried.ID] = retried
+	worker.processReclaimed(retried)
+	assert.NotContains(t, stream.pending, retried.ID)
+	assert.Equal(t, TransactionFailed, repo.transactions["dep-1"].Status)
+
+	// one delivered too often is parked without being handled
+	poison := events.StreamMessage{ID: "2-0", Data: []byte(`{"event":"charge.failed","reference":"dep-missing"}`), Deliveries: maxDeliveries + 1}
+	stream.pending[poison.ID] = poison
+	worker.processReclaimed(poison)
+	assert.NotContains(t, stream.pending, poison.ID)
+	require.Len(t, stream.dlq, 1)
+	assert.Equal(t, maxDeliveries+1, stream.dlq[0].Attempts)
+
+	// and while the DLQ can't be written to, it stays pending to be reclaimed again
+	stream.dlqErr = errors.New("connection refused")
+	stream.pending[poison.ID] = poison
+	worker.processReclaimed(poison)
+	assert.Contains(t, stream.pending, poison.ID)
+	assert.Len(t, stream.dlq, 1)
+}
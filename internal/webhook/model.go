@@ -0,0 +1,41 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryReceived  DeliveryStatus = "RECEIVED"
+	DeliveryRejected  DeliveryStatus = "REJECTED"
+	DeliveryDuplicate DeliveryStatus = "DUPLICATE"
+	DeliveryIgnored   DeliveryStatus = "IGNORED"
+	DeliveryQueued    DeliveryStatus = "QUEUED"
+	DeliveryProcessed DeliveryStatus = "PROCESSED"
+	DeliveryFailed    DeliveryStatus = "FAILED"
+)
+
+// Delivery is one inbound webhook request exactly as Paystack sent it
+type Delivery struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	EventID        string         `gorm:"index" json:"event_id"`
+	Event          string         `json:"event"`
+	Reference      string         `gorm:"index" json:"reference"`
+	Headers        string         `json:"headers"`
+	Body           string         `gorm:"not null" json:"body"`
+	SignatureValid bool           `gorm:"not null" json:"signature_valid"`
+	Status         DeliveryStatus `gorm:"not null" json:"status"`
+	Outcome        string         `json:"outcome"`
+	ReplayCount    int            `gorm:"not null;default:0" json:"replay_count"`
+	RemoteAddr     string         `json:"remote_addr"`
+	ProcessedAt    *time.Time     `json:"processed_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
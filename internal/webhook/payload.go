@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+)
+
+// Payload is the part of a Paystack webhook body the worker acts on, Data is the union of the fields
+// the handled event types send
+type Payload struct {
+	Event string `json:"event"`
+	Data  Data   `json:"data"`
+}
+
+type Data struct {
+	ID        int64  `json:"id"`
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Amount    Amount `json:"amount"`
+	Currency  string `json:"currency"`
+	Channel   string `json:"channel"`
+	// Metadata is an object for checkouts we started, paystack sends an empty string otherwise
+	Metadata json.RawMessage `json:"metadata"`
+
+	// refund.*
+	TransactionReference string `json:"transaction_reference"`
+	RefundReference      string `json:"refund_reference"`
+
+	// charge.dispute.*
+	RefundAmount Amount       `json:"refund_amount"`
+	Category     string       `json:"category"`
+	Resolution   string       `json:"resolution"`
+	DueAt        *time.Time   `json:"dueAt"`
+	ResolvedAt   *time.Time   `json:"resolvedAt"`
+	Transaction  *Transaction `json:"transaction"`
+
+	// dedicatedaccount.*
+	Customer         *Customer         `json:"customer"`
+	DedicatedAccount *DedicatedAccount `json:"dedicated_account"`
+}
+
+type Transaction struct {
+	ID        int64  `json:"id"`
+	Reference string `json:"reference"`
+	Amount    Amount `json:"amount"`
+	Currency  string `json:"currency"`
+	Channel   string `json:"channel"`
+}
+
+type Customer struct {
+	ID           int64  `json:"id"`
+	CustomerCode string `json:"customer_code"`
+	Email        string `json:"email"`
+}
+
+type DedicatedAccount struct {
+	ID            int64  `json:"id"`
+	AccountName   string `json:"account_name"`
+	AccountNumber string `json:"account_number"`
+	Currency      string `json:"currency"`
+	Bank          struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"bank"`
+}
+
+// Amount is in kobo, refund events send it as a quoted string
+type Amount int64
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 || string(b) == "null" {
+		*a = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", b)
+	}
+	*a = Amount(n)
+	return nil
+}
+
+func ParsePayload(body []byte) (*Payload, error) {
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// TransactionRef is the reference of the wallet transaction the event is about, empty for events
+// that are not tied to one
+func (p *Payload) TransactionRef() string {
+	switch {
+	case p.IsRefund():
+		return p.Data.TransactionReference
+	case p.IsDispute():
+		if p.Data.Transaction != nil {
+			return p.Data.Transaction.Reference
+		}
+		return ""
+	case p.IsDedicatedAccount():
+		return ""
+	default:
+		return p.Data.Reference
+	}
+}
+
+func (p *Payload) IsRefund() bool { return strings.HasPrefix(p.Event, "refund.") }
+
+func (p *Payload) IsDispute() bool { return strings.HasPrefix(p.Event, "charge.dispute.") }
+
+func (p *Payload) IsDedicatedAccount() bool { return strings.HasPrefix(p.Event, "dedicatedaccount.") }
+
+// EventID identifies the event across Paystack's retries, the raw body hash stands in when data has no id
+func EventID(p *Payload, body []byte) string {
+	if p != nil && p.Data.ID != 0 {
+		return fmt.Sprintf("%s:%d", p.Event, p.Data.ID)
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func ValidSignature(secret string, body []byte, signature string) bool {
+	hash := hmac.New(sha512.New, []byte(secret))
+	hash.Write(body)
+	expected := hex.EncodeToString(hash.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// ToEvent builds the queue message, deliveryID lets the worker report the outcome back on the delivery
+func (p *Payload) ToEvent(deliveryID string) events.WebhookEvent {
+	event := events.WebhookEvent{
+		DeliveryID: deliveryID,
+		Event:      p.Event,
+		Reference:  p.TransactionRef(),
+		Status:     p.Data.Status,
+		Amount:     int64(p.Data.Amount),
+		Currency:   p.Data.Currency,
+		WalletID:   p.metadataString("wallet_id"),
+		Channel:    p.Data.Channel,
+		Timestamp:  time.Now(),
+	}
+	if p.Data.Customer != nil {
+		event.CustomerCode = p.Data.Customer.CustomerCode
+	}
+
+	switch {
+	case p.IsRefund():
+		event.Refund = &events.RefundEvent{ID: p.refundID()}
+
+	case p.IsDispute():
+		if p.Data.Transaction != nil {
+			event.Amount = int64(p.Data.Transaction.Amount)
+		}
+		event.Dispute = &events.DisputeEvent{
+			ID:           p.Data.ID,
+			RefundAmount: int64(p.Data.RefundAmount),
+			Category:     p.Data.Category,
+			Resolution:   p.Data.Resolution,
+			DueAt:        p.Data.DueAt,
+			ResolvedAt:   p.Data.ResolvedAt,
+		}
+
+	case p.IsDedicatedAccount():
+		event.DedicatedAccount = &events.DedicatedAccountEvent{}
+		if account := p.Data.DedicatedAccount; account != nil {
+			event.Currency = account.Currency
+			event.DedicatedAccount.AccountID = account.ID
+			event.DedicatedAccount.AccountName = account.AccountName
+			event.DedicatedAccount.AccountNumber = account.AccountNumber
+			event.DedicatedAccount.BankName = account.Bank.Name
+			event.DedicatedAccount.BankSlug = account.Bank.Slug
+			event.DedicatedAccount.Currency = account.Currency
+		}
+	}
+
+	return event
+}
+
+func (p *Payload) metadataString(key string) string {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(p.Data.Metadata, &metadata); err != nil {
+		return ""
+	}
+	value, _ := metadata[key].(string)
+	return value
+}
+
+// refundID falls back to the transaction reference when paystack sends neither an id nor a refund
+// reference, which only happens for full refunds
+func (p *Payload) refundID() string {
+	switch {
+	case p.Data.ID != 0:
+		return strconv.FormatInt(p.Data.ID, 10)
+	case p.Data.RefundReference != "":
+		return p.Data.RefundReference
+	default:
+		return p.Data.TransactionReference
+	}
+}
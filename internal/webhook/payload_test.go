@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventID(t *testing.T) {
+	body := []byte(`{"event":"charge.success","data":{"id":3213210,"reference":"dep-1","status":"success","amount":500000}}`)
+	p, err := ParsePayload(body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "charge.success:3213210", EventID(p, body))
+	assert.Equal(t, "dep-1", p.ToEvent("d1").Reference)
+
+	// without a data id the same body must always map to the same event
+	noID := []byte(`{"event":"transfer.success","data":{"reference":"wd-1"}}`)
+	p, err = ParsePayload(noID)
+	require.NoError(t, err)
+	assert.Equal(t, EventID(p, noID), EventID(p, noID))
+	assert.Contains(t, EventID(p, noID), "sha256:")
+	assert.NotEqual(t, EventID(p, noID), EventID(p, append(noID, ' ')))
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"event":"charge.success"}`)
+	mac := hmac.New(sha512.New, []byte("secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(t, ValidSignature("secret", body, signature))
+	assert.False(t, ValidSignature("other", body, signature))
+	assert.False(t, ValidSignature("secret", body, ""))
+}
+
+func TestPayloadEventDetails(t *testing.T) {
+	refund, err := ParsePayload([]byte(`{"event":"refund.processed","data":{"transaction_reference":"dep-1","refund_reference":"RF_1","amount":"15000","currency":"NGN"}}`))
+	require.NoError(t, err)
+	event := refund.ToEvent("")
+	assert.Equal(t, "dep-1", event.Reference)
+	assert.EqualValues(t, 15000, event.Amount)
+	require.NotNil(t, event.Refund)
+	assert.Equal(t, "RF_1", event.Refund.ID)
+
+	dispute, err := ParsePayload([]byte(`{"event":"charge.dispute.create","data":{"id":9,"refund_amount":5000,"status":"awaiting-merchant-feedback","transaction":{"reference":"dep-2","amount":20000}}}`))
+	require.NoError(t, err)
+	event = dispute.ToEvent("")
+	assert.Equal(t, "dep-2", event.Reference)
+	assert.EqualValues(t, 20000, event.Amount)
+	require.NotNil(t, event.Dispute)
+	assert.EqualValues(t, 5000, event.Dispute.RefundAmount)
+
+	assigned, err := ParsePayload([]byte(`{"event":"dedicatedaccount.assign.success","data":{"customer":{"customer_code":"CUS_1"},"dedicated_account":{"account_number":"9930000000","bank":{"slug":"wema-bank"}}}}`))
+	require.NoError(t, err)
+	event = assigned.ToEvent("")
+	assert.Empty(t, event.Reference)
+	require.NotNil(t, event.DedicatedAccount)
+	assert.Equal(t, "CUS_1", event.CustomerCode)
+	assert.Equal(t, "wema-bank", event.DedicatedAccount.BankSlug)
+}
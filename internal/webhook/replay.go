@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/events"
+)
+
+var ErrUnsignedDelivery = errors.New("delivery failed signature verification and cannot be replayed")
+
+// Replay parses a stored delivery again and queues it for the worker. It skips the duplicate and
+// status checks the live endpoint applies, the worker's handlers are idempotent.
+func Replay(ctx context.Context, repo Repository, redisClient *events.RedisClient, id string) (*Delivery, error) {
+	delivery, err := repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !delivery.SignatureValid {
+		return delivery, ErrUnsignedDelivery
+	}
+
+	payload, err := ParsePayload([]byte(delivery.Body))
+	if err != nil {
+		return delivery, fmt.Errorf("stored body is not a valid payload: %v", err)
+	}
+
+	if err := redisClient.PublishEvent(ctx, payload.ToEvent(delivery.ID.String())); err != nil {
+		return delivery, err
+	}
+
+	if err := repo.MarkReplayed(delivery.ID.String()); err != nil {
+		return delivery, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(d *Delivery) error
+	GetByID(id string) (*Delivery, error)
+	List(reference string, limit, offset int) ([]Delivery, error)
+	Count(reference string) (int64, error)
+	// FindAccepted returns an earlier delivery of the same event that was queued or processed
+	FindAccepted(eventID string, excludeID string) (*Delivery, error)
+	UpdateStatus(id string, status DeliveryStatus, outcome string) error
+	MarkReplayed(id string) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(d *Delivery) error {
+	return r.db.Create(d).Error
+}
+
+func (r *repository) GetByID(id string) (*Delivery, error) {
+	var d Delivery
+	if err := r.db.Where("id = ?", id).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *repository) List(reference string, limit, offset int) ([]Delivery, error) {
+	var list []Delivery
+	query := r.db.Order("created_at desc").Limit(limit).Offset(offset)
+	if reference != "" {
+		query = query.Where("reference = ?", reference)
+	}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+func (r *repository) Count(reference string) (int64, error) {
+	var count int64
+	query := r.db.Model(&Delivery{})
+	if reference != "" {
+		query = query.Where("reference = ?", reference)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r *repository) FindAccepted(eventID string, excludeID string) (*Delivery, error) {
+	var d Delivery
+	err := r.db.Where("event_id = ? AND id <> ? AND status IN ?", eventID, excludeID, []DeliveryStatus{DeliveryQueued, DeliveryProcessed, DeliveryFailed}).
+		Order("created_at asc").
+		First(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *repository) UpdateStatus(id string, status DeliveryStatus, outcome string) error {
+	updates := map[string]interface{}{"status": status, "outcome": outcome}
+	if status == DeliveryProcessed || status == DeliveryFailed {
+		updates["processed_at"] = time.Now()
+	}
+	return r.db.Model(&Delivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *repository) MarkReplayed(id string) error {
+	return r.db.Model(&Delivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       DeliveryQueued,
+		"outcome":      "replayed",
+		"replay_count": gorm.Expr("replay_count + 1"),
+		"processed_at": nil,
+	}).Error
+}
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS beneficiaries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    nickname VARCHAR(255) NOT NULL,
+    account_number VARCHAR(20),
+    account_name VARCHAR(255),
+    bank_code VARCHAR(20),
+    bank_name VARCHAR(255),
+    recipient_code VARCHAR(255),
+    wallet_number VARCHAR(20),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_beneficiaries_user_id ON beneficiaries(user_id);
+CREATE UNIQUE INDEX idx_beneficiaries_user_bank_account ON beneficiaries(user_id, account_number, bank_code) WHERE type = 'BANK';
+CREATE UNIQUE INDEX idx_beneficiaries_user_wallet ON beneficiaries(user_id, wallet_number) WHERE type = 'WALLET';
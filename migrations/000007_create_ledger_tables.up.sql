@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(255) UNIQUE NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    wallet_id UUID REFERENCES wallets(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    balance BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_journals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reference VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    journal_id UUID NOT NULL REFERENCES ledger_journals(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    direction VARCHAR(10) NOT NULL CHECK (direction IN ('DEBIT', 'CREDIT')),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_ledger_accounts_wallet_id ON ledger_accounts(wallet_id);
+CREATE INDEX idx_ledger_entries_journal_id ON ledger_entries(journal_id);
+CREATE INDEX idx_ledger_entries_account_id ON ledger_entries(account_id);
+
+-- open a ledger account for every existing wallet, balanced against suspense
+INSERT INTO ledger_accounts (code, type, wallet_id, currency, balance)
+SELECT 'WALLET:' || id, 'WALLET', id, currency, balance FROM wallets;
+
+INSERT INTO ledger_accounts (code, type, currency, balance)
+SELECT 'SUSPENSE:' || currency, 'SYSTEM', currency, -SUM(balance) FROM wallets GROUP BY currency;
+
+INSERT INTO ledger_journals (reference, description)
+SELECT 'opening-' || id, 'Opening balance' FROM wallets WHERE balance > 0;
+
+INSERT INTO ledger_entries (journal_id, account_id, direction, amount)
+SELECT j.id, a.id, 'CREDIT', w.balance
+FROM wallets w
+JOIN ledger_journals j ON j.reference = 'opening-' || w.id
+JOIN ledger_accounts a ON a.wallet_id = w.id
+WHERE w.balance > 0;
+
+INSERT INTO ledger_entries (journal_id, account_id, direction, amount)
+SELECT j.id, s.id, 'DEBIT', w.balance
+FROM wallets w
+JOIN ledger_journals j ON j.reference = 'opening-' || w.id
+JOIN ledger_accounts s ON s.code = 'SUSPENSE:' || w.currency
+WHERE w.balance > 0;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_logs;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS pin_locked_until;
+ALTER TABLE wallets DROP COLUMN IF EXISTS failed_pin_attempts;
@@ -0,0 +1,14 @@
+ALTER TABLE wallets ADD COLUMN failed_pin_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE wallets ADD COLUMN pin_locked_until TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    wallet_id UUID REFERENCES wallets(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_logs_wallet_id ON audit_logs(wallet_id, created_at);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
@@ -0,0 +1,3 @@
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id VARCHAR(255),
+    event VARCHAR(100),
+    reference VARCHAR(255),
+    headers TEXT,
+    body TEXT NOT NULL,
+    signature_valid BOOLEAN NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    outcome TEXT,
+    replay_count INTEGER NOT NULL DEFAULT 0,
+    remote_addr VARCHAR(255),
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries(event_id);
+CREATE INDEX idx_webhook_deliveries_reference ON webhook_deliveries(reference);
+CREATE INDEX idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS disputes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    paystack_id BIGINT UNIQUE NOT NULL,
+    wallet_id UUID REFERENCES wallets(id) ON DELETE SET NULL,
+    transaction_reference VARCHAR(255),
+    amount BIGINT NOT NULL,
+    refund_amount BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3),
+    status VARCHAR(50) NOT NULL,
+    category VARCHAR(50),
+    resolution VARCHAR(50),
+    due_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_disputes_transaction_reference ON disputes(transaction_reference);
@@ -0,0 +1,3 @@
+ALTER TABLE transactions ADD COLUMN review_reason TEXT;
+
+CREATE INDEX idx_transactions_review ON transactions(status) WHERE status = 'REVIEW';
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_wallets_paystack_customer_code;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_paystack_id;
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_bank_slug;
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_bank_name;
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_account_name;
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_account_number;
+ALTER TABLE wallets DROP COLUMN IF EXISTS dedicated_status;
+ALTER TABLE wallets DROP COLUMN IF EXISTS paystack_customer_code;
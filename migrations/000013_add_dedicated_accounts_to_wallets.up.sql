@@ -0,0 +1,9 @@
+ALTER TABLE wallets ADD COLUMN paystack_customer_code VARCHAR(50);
+ALTER TABLE wallets ADD COLUMN dedicated_status VARCHAR(20);
+ALTER TABLE wallets ADD COLUMN dedicated_account_number VARCHAR(20);
+ALTER TABLE wallets ADD COLUMN dedicated_account_name VARCHAR(255);
+ALTER TABLE wallets ADD COLUMN dedicated_bank_name VARCHAR(100);
+ALTER TABLE wallets ADD COLUMN dedicated_bank_slug VARCHAR(100);
+ALTER TABLE wallets ADD COLUMN dedicated_paystack_id BIGINT;
+
+CREATE INDEX idx_wallets_paystack_customer_code ON wallets(paystack_customer_code);
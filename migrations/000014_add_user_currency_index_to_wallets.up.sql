@@ -0,0 +1 @@
+CREATE UNIQUE INDEX idx_wallets_user_currency ON wallets(user_id, currency);
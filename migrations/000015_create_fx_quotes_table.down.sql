@@ -0,0 +1,9 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_fee;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_spread_bps;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_market_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_to_currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_from_currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_quote_id;
+
+DROP TABLE IF EXISTS fx_quotes;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    to_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    amount BIGINT NOT NULL,
+    fee BIGINT NOT NULL DEFAULT 0,
+    converted_amount BIGINT NOT NULL,
+    market_rate NUMERIC(24, 12) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    spread_bps BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    executed_at TIMESTAMP WITH TIME ZONE,
+    reference VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_fx_quotes_user_id ON fx_quotes(user_id);
+
+ALTER TABLE transactions ADD COLUMN fx_quote_id UUID REFERENCES fx_quotes(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN fx_from_currency VARCHAR(3);
+ALTER TABLE transactions ADD COLUMN fx_to_currency VARCHAR(3);
+ALTER TABLE transactions ADD COLUMN fx_rate NUMERIC(24, 12);
+ALTER TABLE transactions ADD COLUMN fx_market_rate NUMERIC(24, 12);
+ALTER TABLE transactions ADD COLUMN fx_spread_bps BIGINT;
+ALTER TABLE transactions ADD COLUMN fx_fee BIGINT;
@@ -0,0 +1,20 @@
+ALTER TABLE wallets ADD COLUMN held_balance BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    recipient_wallet_id UUID REFERENCES wallets(id) ON DELETE SET NULL,
+    reference VARCHAR(255) UNIQUE NOT NULL,
+    amount BIGINT NOT NULL,
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    description TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    captured_at TIMESTAMP WITH TIME ZONE,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_holds_wallet_id ON holds(wallet_id);
+CREATE INDEX idx_holds_active_expiry ON holds(expires_at) WHERE status = 'ACTIVE';
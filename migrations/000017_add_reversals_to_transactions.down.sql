@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_reversal_of;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversed_amount;
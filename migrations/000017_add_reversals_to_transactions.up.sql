@@ -0,0 +1,4 @@
+ALTER TABLE transactions ADD COLUMN reversed_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN reversal_of VARCHAR(255);
+
+CREATE INDEX idx_transactions_reversal_of ON transactions(reversal_of);
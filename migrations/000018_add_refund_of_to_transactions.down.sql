@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_refund_of;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS refund_of;
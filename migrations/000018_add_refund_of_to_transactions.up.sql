@@ -0,0 +1,8 @@
+ALTER TABLE transactions ADD COLUMN refund_of VARCHAR(255);
+
+-- refunds recorded before this column existed name their deposit in the description
+UPDATE transactions
+SET refund_of = substring(description FROM 'Refund of deposit (.*)$')
+WHERE category = 'REFUND' AND description LIKE 'Refund of deposit %';
+
+CREATE INDEX idx_transactions_refund_of ON transactions(refund_of);
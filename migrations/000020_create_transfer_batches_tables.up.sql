@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS transfer_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    reference VARCHAR(255) NOT NULL,
+    mode VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    total_amount BIGINT NOT NULL,
+    item_count INTEGER NOT NULL,
+    succeeded_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_transfer_batches_reference ON transfer_batches(user_id, reference);
+
+CREATE TABLE IF NOT EXISTS transfer_batch_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_id UUID NOT NULL REFERENCES transfer_batches(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    wallet_number VARCHAR(20) NOT NULL,
+    recipient_wallet_id UUID NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL,
+    reference VARCHAR(255) NOT NULL,
+    error TEXT
+);
+
+CREATE INDEX idx_transfer_batch_items_batch_id ON transfer_batch_items(batch_id, line);
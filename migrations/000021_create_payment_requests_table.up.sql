@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    requester_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requester_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    requester_wallet_number VARCHAR(20) NOT NULL,
+    payer_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payer_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    payer_wallet_number VARCHAR(20) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    note TEXT,
+    status VARCHAR(20) NOT NULL,
+    decline_reason TEXT,
+    transfer_reference VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_payment_requests_requester ON payment_requests(requester_user_id, created_at DESC);
+CREATE INDEX idx_payment_requests_payer ON payment_requests(payer_user_id, created_at DESC);
+CREATE INDEX idx_payment_requests_pending ON payment_requests(expires_at) WHERE status = 'PENDING';
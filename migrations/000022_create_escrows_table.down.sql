@@ -0,0 +1,5 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS escrow_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS escrow_status;
+ALTER TABLE transactions DROP COLUMN IF EXISTS escrow_id;
+
+DROP TABLE IF EXISTS escrows;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS escrows (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sender_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sender_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    sender_wallet_number VARCHAR(20) NOT NULL,
+    recipient_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    recipient_wallet_number VARCHAR(20) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL,
+    release_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    disputed_by VARCHAR(20),
+    dispute_reason TEXT,
+    closed_by VARCHAR(20),
+    resolution TEXT,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_escrows_sender ON escrows(sender_user_id, created_at DESC);
+CREATE INDEX idx_escrows_recipient ON escrows(recipient_user_id, created_at DESC);
+CREATE INDEX idx_escrows_funded ON escrows(release_at) WHERE status = 'FUNDED';
+
+ALTER TABLE transactions ADD COLUMN escrow_id UUID REFERENCES escrows(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN escrow_status VARCHAR(20);
+ALTER TABLE transactions ADD COLUMN escrow_amount BIGINT;
@@ -0,0 +1,4 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS pocket_id;
+ALTER TABLE wallets DROP COLUMN IF EXISTS pocket_balance;
+
+DROP TABLE IF EXISTS pockets;
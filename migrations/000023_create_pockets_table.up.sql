@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS pockets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    balance BIGINT NOT NULL DEFAULT 0,
+    target_amount BIGINT,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_pockets_wallet_name ON pockets(wallet_id, LOWER(name));
+
+ALTER TABLE wallets ADD COLUMN pocket_balance BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN pocket_id UUID;
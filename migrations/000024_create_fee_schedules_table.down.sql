@@ -0,0 +1,6 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee_schedule_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee;
+ALTER TABLE users DROP COLUMN IF EXISTS tier;
+
+DROP TABLE IF EXISTS fee_tiers;
+DROP TABLE IF EXISTS fee_schedules;
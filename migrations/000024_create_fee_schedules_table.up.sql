@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    category VARCHAR(50) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    flat BIGINT NOT NULL DEFAULT 0,
+    bps BIGINT NOT NULL DEFAULT 0,
+    min_fee BIGINT NOT NULL DEFAULT 0,
+    max_fee BIGINT NOT NULL DEFAULT 0,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE CASCADE,
+    user_tier VARCHAR(50),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_fee_schedules_lookup ON fee_schedules(category, currency) WHERE active;
+
+CREATE TABLE IF NOT EXISTS fee_tiers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    schedule_id UUID NOT NULL REFERENCES fee_schedules(id) ON DELETE CASCADE,
+    up_to BIGINT NOT NULL DEFAULT 0,
+    flat BIGINT NOT NULL DEFAULT 0,
+    bps BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_fee_tiers_schedule_id ON fee_tiers(schedule_id);
+
+ALTER TABLE users ADD COLUMN tier VARCHAR(50) NOT NULL DEFAULT 'STANDARD';
+ALTER TABLE transactions ADD COLUMN fee BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN fee_schedule_id UUID REFERENCES fee_schedules(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+ALTER TABLE beneficiaries DROP COLUMN IF EXISTS currency;
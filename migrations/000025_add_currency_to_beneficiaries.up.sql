@@ -0,0 +1,3 @@
+ALTER TABLE beneficiaries ADD COLUMN currency VARCHAR(3);
+
+UPDATE beneficiaries SET currency = 'NGN' WHERE type = 'BANK';
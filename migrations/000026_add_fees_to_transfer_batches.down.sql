@@ -0,0 +1,3 @@
+ALTER TABLE transfer_batch_items DROP COLUMN IF EXISTS fee_schedule_id;
+ALTER TABLE transfer_batch_items DROP COLUMN IF EXISTS fee;
+ALTER TABLE transfer_batches DROP COLUMN IF EXISTS total_fee;
@@ -0,0 +1,3 @@
+ALTER TABLE transfer_batches ADD COLUMN total_fee BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transfer_batch_items ADD COLUMN fee BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transfer_batch_items ADD COLUMN fee_schedule_id UUID REFERENCES fee_schedules(id) ON DELETE SET NULL;
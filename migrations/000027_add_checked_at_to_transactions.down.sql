@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_pending_checked_at;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS checked_at;
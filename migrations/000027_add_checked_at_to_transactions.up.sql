@@ -0,0 +1,3 @@
+ALTER TABLE transactions ADD COLUMN checked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_transactions_pending_checked_at ON transactions(category, checked_at, created_at) WHERE status = 'PENDING';
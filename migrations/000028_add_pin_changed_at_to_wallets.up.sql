@@ -0,0 +1 @@
+ALTER TABLE wallets ADD COLUMN pin_changed_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transfer_batches_processing;
+
+ALTER TABLE transfer_batches DROP COLUMN IF EXISTS claimed_until;
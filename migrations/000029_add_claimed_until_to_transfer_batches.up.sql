@@ -0,0 +1,3 @@
+ALTER TABLE transfer_batches ADD COLUMN claimed_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_transfer_batches_processing ON transfer_batches(created_at) WHERE status = 'PROCESSING';
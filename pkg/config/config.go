@@ -2,30 +2,74 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// SupportedCurrencies are the currencies a user can hold a wallet in, DefaultCurrency is assumed when
+// a request does not name one
+var SupportedCurrencies = []string{"NGN", "GHS", "ZAR", "KES", "USD"}
+
+const DefaultCurrency = "NGN"
+
+// defaultMinTransactionAmounts are in each currency's minor unit
+var defaultMinTransactionAmounts = map[string]int64{
+	"NGN": 10000,
+	"GHS": 100,
+	"ZAR": 1000,
+	"KES": 1000,
+	"USD": 100,
+}
+
 type Config struct {
-	DBUrl                string
-	GoogleClientID       string
-	GoogleClientSecret   string
-	JWTSecret            string
-	PaystackSecret       string
-	PaystackChannels     []string
-	MinTransactionAmount int64
-	Port                 string
-	Host                 string
-	Env                  string
-	AllowedOrigins       []string
-	MaxActiveKeys        int
-	RedisURL             string
-	RedisPassword        string
-	RateLimit            int
-	RateBurst            int
+	DBUrl                 string
+	GoogleClientID        string
+	GoogleClientSecret    string
+	JWTSecret             string
+	PaystackSecret        string
+	PaystackBaseURL       string
+	PaystackChannels      []string
+	MinTransactionAmounts map[string]int64
+	Port                  string
+	Host                  string
+	Env                   string
+	AllowedOrigins        []string
+	MaxActiveKeys         int
+	RedisURL              string
+	RedisPassword         string
+	RateLimit             int
+	RateBurst             int
+	DepositSuccessURL     string
+	DepositFailureURL     string
+	WebhookConsumers      int
+	WebhookClaimIdle      time.Duration
+	ReconcileInterval     time.Duration
+	ReconcileStaleAfter   time.Duration
+	DepositExpiry         time.Duration
+	PinMaxAttempts        int
+	PinLockDuration       time.Duration
+	PinResetMaxAuthAge    time.Duration
+	DedicatedAccountBank  string
+	FXRatesFile           string
+	FXQuoteTTL            time.Duration
+	FXSpreadBps           int64
+	FXFeeBps              int64
+	HoldDefaultExpiry     time.Duration
+	HoldMaxExpiry         time.Duration
+	ScheduleInterval      time.Duration
+	ScheduleRetryInterval time.Duration
+	ScheduleMaxRetries    int
+	BatchMaxItems         int
+	BatchInterval         time.Duration
+	RequestDefaultExpiry  time.Duration
+	RequestMaxExpiry      time.Duration
+	EscrowDefaultRelease  time.Duration
+	EscrowMaxRelease      time.Duration
 }
 
 func LoadConfig() Config {
@@ -34,25 +78,77 @@ func LoadConfig() Config {
 	paystackChannels := strings.Split(getEnv("PAYSTACK_CHANNELS"), ",")
 
 	return Config{
-		DBUrl:                getEnv("DATABASE_URL"),
-		GoogleClientID:       getEnv("GOOGLE_CLIENT_ID"),
-		GoogleClientSecret:   getEnv("GOOGLE_CLIENT_SECRET"),
-		JWTSecret:            getEnv("JWT_SECRET"),
-		PaystackSecret:       getEnv("PAYSTACK_SECRET"),
-		PaystackChannels:     paystackChannels,
-		MinTransactionAmount: getEnvAsInt64("MIN_TRANSACTION_AMOUNT"),
-		Port:                 getEnv("PORT"),
-		Host:                 getEnv("HOST"),
-		Env:                  getEnv("ENV"),
-		AllowedOrigins:       strings.Split(getEnv("ALLOWED_ORIGINS"), ","),
-		MaxActiveKeys:        getEnvAsInt("MAX_ACTIVE_KEYS"),
-		RedisURL:             getEnv("REDIS_URL"),
-		RedisPassword:        getEnv("REDIS_PASSWORD"),
-		RateLimit:            getEnvAsInt("RATE_LIMIT"),
-		RateBurst:            getEnvAsInt("RATE_BURST"),
+		DBUrl:                 getEnv("DATABASE_URL"),
+		GoogleClientID:        getEnv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:    getEnv("GOOGLE_CLIENT_SECRET"),
+		JWTSecret:             getEnv("JWT_SECRET"),
+		PaystackSecret:        getEnv("PAYSTACK_SECRET"),
+		PaystackBaseURL:       getEnvOrDefault("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+		PaystackChannels:      paystackChannels,
+		MinTransactionAmounts: getEnvAsAmountsOrDefault("MIN_TRANSACTION_AMOUNTS", minTransactionDefaults()),
+		Port:                  getEnv("PORT"),
+		Host:                  getEnv("HOST"),
+		Env:                   getEnv("ENV"),
+		AllowedOrigins:        strings.Split(getEnv("ALLOWED_ORIGINS"), ","),
+		MaxActiveKeys:         getEnvAsInt("MAX_ACTIVE_KEYS"),
+		RedisURL:              getEnv("REDIS_URL"),
+		RedisPassword:         getEnv("REDIS_PASSWORD"),
+		RateLimit:             getEnvAsInt("RATE_LIMIT"),
+		RateBurst:             getEnvAsInt("RATE_BURST"),
+		DepositSuccessURL:     getEnvOrDefault("DEPOSIT_SUCCESS_URL", ""),
+		DepositFailureURL:     getEnvOrDefault("DEPOSIT_FAILURE_URL", ""),
+		WebhookConsumers:      getEnvAsIntOrDefault("WEBHOOK_CONSUMERS", 4),
+		WebhookClaimIdle:      getEnvAsDurationOrDefault("WEBHOOK_CLAIM_IDLE", time.Minute),
+		ReconcileInterval:     getEnvAsDurationOrDefault("RECONCILE_INTERVAL", 5*time.Minute),
+		ReconcileStaleAfter:   getEnvAsDurationOrDefault("RECONCILE_STALE_AFTER", 10*time.Minute),
+		DepositExpiry:         getEnvAsDurationOrDefault("DEPOSIT_EXPIRY", 24*time.Hour),
+		PinMaxAttempts:        getEnvAsIntOrDefault("PIN_MAX_ATTEMPTS", 5),
+		PinLockDuration:       getEnvAsDurationOrDefault("PIN_LOCK_DURATION", 30*time.Minute),
+		PinResetMaxAuthAge:    getEnvAsDurationOrDefault("PIN_RESET_MAX_AUTH_AGE", 10*time.Minute),
+		DedicatedAccountBank:  getEnvOrDefault("DEDICATED_ACCOUNT_BANK", "wema-bank"),
+		FXRatesFile:           getEnvOrDefault("FX_RATES_FILE", "fx_rates.json"),
+		FXQuoteTTL:            getEnvAsDurationOrDefault("FX_QUOTE_TTL", 30*time.Second),
+		FXSpreadBps:           int64(getEnvAsIntOrDefault("FX_SPREAD_BPS", 50)),
+		FXFeeBps:              int64(getEnvAsIntOrDefault("FX_FEE_BPS", 0)),
+		HoldDefaultExpiry:     getEnvAsDurationOrDefault("HOLD_DEFAULT_EXPIRY", 24*time.Hour),
+		HoldMaxExpiry:         getEnvAsDurationOrDefault("HOLD_MAX_EXPIRY", 30*24*time.Hour),
+		ScheduleInterval:      getEnvAsDurationOrDefault("SCHEDULE_INTERVAL", time.Minute),
+		ScheduleRetryInterval: getEnvAsDurationOrDefault("SCHEDULE_RETRY_INTERVAL", time.Hour),
+		ScheduleMaxRetries:    getEnvAsIntOrDefault("SCHEDULE_MAX_RETRIES", 3),
+		BatchMaxItems:         getEnvAsIntOrDefault("BATCH_MAX_ITEMS", 1000),
+		BatchInterval:         getEnvAsDurationOrDefault("BATCH_INTERVAL", 5*time.Second),
+		RequestDefaultExpiry:  getEnvAsDurationOrDefault("PAYMENT_REQUEST_DEFAULT_EXPIRY", 7*24*time.Hour),
+		RequestMaxExpiry:      getEnvAsDurationOrDefault("PAYMENT_REQUEST_MAX_EXPIRY", 30*24*time.Hour),
+		EscrowDefaultRelease:  getEnvAsDurationOrDefault("ESCROW_DEFAULT_RELEASE", 14*24*time.Hour),
+		EscrowMaxRelease:      getEnvAsDurationOrDefault("ESCROW_MAX_RELEASE", 90*24*time.Hour),
 	}
 }
 
+// minTransactionDefaults honours MIN_TRANSACTION_AMOUNT from before wallets had currencies, it was in Kobo so it
+// still sets the NGN minimum unless MIN_TRANSACTION_AMOUNTS names NGN itself
+func minTransactionDefaults() map[string]int64 {
+	defaults := maps.Clone(defaultMinTransactionAmounts)
+	if os.Getenv("MIN_TRANSACTION_AMOUNT") != "" {
+		defaults["NGN"] = int64(getEnvAsInt("MIN_TRANSACTION_AMOUNT"))
+	}
+	return defaults
+}
+
+// MinTransactionAmount is the smallest deposit, withdrawal or transfer allowed in currency
+func (c Config) MinTransactionAmount(currency string) int64 {
+	return c.MinTransactionAmounts[currency]
+}
+
+// IsSupportedCurrency reports whether currency is one a wallet can be held in
+func IsSupportedCurrency(currency string) bool {
+	for _, c := range SupportedCurrencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
 func getEnv(key string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -60,6 +156,13 @@ func getEnv(key string) string {
 	panic(fmt.Sprintf("%s is required", key))
 }
 
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsInt(key string) int {
 	valueStr := getEnv(key)
 
@@ -70,12 +173,45 @@ func getEnvAsInt(key string) int {
 	return value
 }
 
-func getEnvAsInt64(key string) int64 {
-	valueStr := getEnv(key)
+// getEnvAsAmountsOrDefault reads CUR:amount pairs such as "NGN:10000,GHS:100", currencies left out keep their default
+func getEnvAsAmountsOrDefault(key string, defaults map[string]int64) map[string]int64 {
+	amounts := make(map[string]int64, len(defaults))
+	for currency, amount := range defaults {
+		amounts[currency] = amount
+	}
 
-	value, err := strconv.ParseInt(valueStr, 10, 64)
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return amounts
+	}
+
+	for _, pair := range strings.Split(valueStr, ",") {
+		currency, amountStr, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		amount, err := strconv.ParseInt(amountStr, 10, 64)
+		if !ok || err != nil {
+			panic(fmt.Sprintf("%s must be a list of CUR:amount pairs (e.g. NGN:10000,GHS:100)", key))
+		}
+		amounts[strings.ToUpper(currency)] = amount
+	}
+	return amounts
+}
+
+func getEnvAsIntOrDefault(key string, defaultValue int) int {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+	return getEnvAsInt(key)
+}
+
+func getEnvAsDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(valueStr)
 	if err != nil {
-		panic(fmt.Sprintf("%s must be a valid integer", key))
+		panic(fmt.Sprintf("%s must be a valid duration (e.g. 30m)", key))
 	}
 	return value
 }
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const dlqBatchSize = 100
+
+// DeadLetter is an event the worker gave up on, with why and after how many attempts
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Payload  string    `json:"payload"`
+	Reason   string    `json:"reason"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func (r *RedisClient) PushToDLQ(ctx context.Context, data []byte, reason string, attempts int) error {
+	err := r.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: FailedQueue,
+		Values: map[string]interface{}{
+			"event":     data,
+			"reason":    reason,
+			"attempts":  attempts,
+			"failed_at": time.Now().UTC().Format(time.RFC3339),
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to push event to DLQ: %v", err)
+	}
+	return nil
+}
+
+// ListDLQ returns up to count entries oldest first, starting after the cursor ID. The returned cursor is
+// empty once the end of the queue is reached.
+func (r *RedisClient) ListDLQ(ctx context.Context, cursor string, count int64) ([]DeadLetter, string, error) {
+	start := "-"
+	if cursor != "" {
+		start = "(" + cursor
+	}
+
+	msgs, err := r.Client.XRangeN(ctx, FailedQueue, start, "+", count).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]DeadLetter, 0, len(msgs))
+	for _, msg := range msgs {
+		entries = append(entries, toDeadLetter(msg))
+	}
+
+	next := ""
+	if int64(len(msgs)) == count {
+		next = msgs[len(msgs)-1].ID
+	}
+	return entries, next, nil
+}
+
+func (r *RedisClient) CountDLQ(ctx context.Context) (int64, error) {
+	return r.Client.XLen(ctx, FailedQueue).Result()
+}
+
+// ReplayDLQ puts the given entries, or every entry when ids is empty, back on WebhookQueue and removes
+// them from the DLQ. It returns the IDs that were replayed, unknown IDs are skipped.
+func (r *RedisClient) ReplayDLQ(ctx context.Context, ids ...string) ([]string, error) {
+	var replayed []string
+	err := r.eachDLQ(ctx, ids, func(msg redis.XMessage) error {
+		if err := r.Enqueue(ctx, []byte(stringValue(msg.Values, "event"))); err != nil {
+			return err
+		}
+		if err := r.Client.XDel(ctx, FailedQueue, msg.ID).Err(); err != nil {
+			return err
+		}
+		replayed = append(replayed, msg.ID)
+		return nil
+	})
+	return replayed, err
+}
+
+// PurgeDLQ deletes the given entries, or every entry when ids is empty, and returns the IDs removed
+func (r *RedisClient) PurgeDLQ(ctx context.Context, ids ...string) ([]string, error) {
+	var purged []string
+	err := r.eachDLQ(ctx, ids, func(msg redis.XMessage) error {
+		if err := r.Client.XDel(ctx, FailedQueue, msg.ID).Err(); err != nil {
+			return err
+		}
+		purged = append(purged, msg.ID)
+		return nil
+	})
+	return purged, err
+}
+
+func (r *RedisClient) eachDLQ(ctx context.Context, ids []string, fn func(redis.XMessage) error) error {
+	if len(ids) > 0 {
+		for _, id := range ids {
+			msgs, err := r.Client.XRange(ctx, FailedQueue, id, id).Result()
+			if err != nil {
+				return err
+			}
+			for _, msg := range msgs {
+				if err := fn(msg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// stop at the current tail so entries that fail again while replaying are not picked up in a loop
+	last, err := r.Client.XRevRangeN(ctx, FailedQueue, "+", "-", 1).Result()
+	if err != nil || len(last) == 0 {
+		return err
+	}
+	end := last[0].ID
+
+	// fn deletes what it visits, so each pass starts again from the head of the stream
+	for {
+		msgs, err := r.Client.XRangeN(ctx, FailedQueue, "-", end, dlqBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		for _, msg := range msgs {
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toDeadLetter(msg redis.XMessage) DeadLetter {
+	attempts, _ := strconv.Atoi(stringValue(msg.Values, "attempts"))
+	failedAt, _ := time.Parse(time.RFC3339, stringValue(msg.Values, "failed_at"))
+	return DeadLetter{
+		ID:       msg.ID,
+		Payload:  stringValue(msg.Values, "event"),
+		Reason:   stringValue(msg.Values, "reason"),
+		Attempts: attempts,
+		FailedAt: failedAt,
+	}
+}
@@ -12,20 +12,62 @@ import (
 )
 
 const (
-	WebhookQueue = "webhook_events"
-	FailedQueue  = "failed_webhook_events"
+	// WebhookQueue is a stream consumed by WebhookGroup, entries stay pending until acknowledged
+	WebhookQueue = "webhook_events_stream"
+	WebhookGroup = "webhook_workers"
+	// FailedQueue is a stream of DeadLetter entries
+	FailedQueue = "failed_webhook_events_stream"
+
+	// legacy lists the queues lived in before they moved to streams
+	legacyWebhookQueue = "webhook_events"
+	legacyFailedQueue  = "failed_webhook_events"
+
+	webhookQueueMaxLen = 100000
 )
 
 type RedisClient struct {
 	Client *redis.Client
 }
 
+// WebhookEvent is the queued form of a Paystack event, Reference is always the wallet transaction it
+// concerns and only the block matching the event type is set
 type WebhookEvent struct {
-	Event     string    `json:"event"`
-	Reference string    `json:"reference"`
-	Status    string    `json:"status"`
-	Amount    int64     `json:"amount"`
-	Timestamp time.Time `json:"timestamp"`
+	DeliveryID       string                 `json:"delivery_id,omitempty"`
+	Event            string                 `json:"event"`
+	Reference        string                 `json:"reference"`
+	Status           string                 `json:"status"`
+	Amount           int64                  `json:"amount"`
+	Currency         string                 `json:"currency,omitempty"`
+	WalletID         string                 `json:"wallet_id,omitempty"`
+	Channel          string                 `json:"channel,omitempty"`
+	CustomerCode     string                 `json:"customer_code,omitempty"`
+	Refund           *RefundEvent           `json:"refund,omitempty"`
+	Dispute          *DisputeEvent          `json:"dispute,omitempty"`
+	DedicatedAccount *DedicatedAccountEvent `json:"dedicated_account,omitempty"`
+	Timestamp        time.Time              `json:"timestamp"`
+}
+
+type RefundEvent struct {
+	// ID is unique per refund, a deposit can be partially refunded more than once
+	ID string `json:"id"`
+}
+
+type DisputeEvent struct {
+	ID           int64      `json:"id"`
+	RefundAmount int64      `json:"refund_amount"`
+	Category     string     `json:"category,omitempty"`
+	Resolution   string     `json:"resolution,omitempty"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+}
+
+type DedicatedAccountEvent struct {
+	AccountID     int64  `json:"account_id"`
+	AccountName   string `json:"account_name"`
+	AccountNumber string `json:"account_number"`
+	BankName      string `json:"bank_name"`
+	BankSlug      string `json:"bank_slug"`
+	Currency      string `json:"currency"`
 }
 
 func NewRedisClient(cfg config.Config) *RedisClient {
@@ -60,16 +102,20 @@ func (r *RedisClient) PublishEvent(ctx context.Context, event WebhookEvent) erro
 		return fmt.Errorf("failed to marshal event: %v", err)
 	}
 
-	if err := r.Client.RPush(ctx, WebhookQueue, data).Err(); err != nil {
-		return fmt.Errorf("failed to push event to redis: %v", err)
-	}
-
-	return nil
+	return r.Enqueue(ctx, data)
 }
 
-func (r *RedisClient) PushToDLQ(ctx context.Context, data []byte) error {
-	if err := r.Client.RPush(ctx, FailedQueue, data).Err(); err != nil {
-		return fmt.Errorf("failed to push event to DLQ: %v", err)
+// Enqueue adds an already encoded event to the webhook stream
+func (r *RedisClient) Enqueue(ctx context.Context, data []byte) error {
+	err := r.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: WebhookQueue,
+		MaxLen: webhookQueueMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to push event to redis: %v", err)
 	}
+
 	return nil
 }
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type StreamMessage struct {
+	ID   string
+	Data []byte
+	// Deliveries counts how many times the entry has been handed to a consumer, only set on reclaimed entries
+	Deliveries int64
+}
+
+// EnsureGroup creates the consumer group, and the stream with it, if it does not exist yet
+func (r *RedisClient) EnsureGroup(ctx context.Context) error {
+	err := r.Client.XGroupCreateMkStream(ctx, WebhookQueue, WebhookGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %v", err)
+	}
+	return nil
+}
+
+// MigrateLegacyQueue moves events left in the old lists onto the streams. Each event is copied before it
+// is removed, so a crash midway can duplicate one event but never drop it.
+func (r *RedisClient) MigrateLegacyQueue(ctx context.Context) (int, error) {
+	moved, err := r.migrateList(ctx, legacyWebhookQueue, r.Enqueue)
+	if err != nil {
+		return moved, err
+	}
+
+	movedFailed, err := r.migrateList(ctx, legacyFailedQueue, func(ctx context.Context, data []byte) error {
+		return r.PushToDLQ(ctx, data, "moved from the legacy DLQ list, original reason unknown", 0)
+	})
+	return moved + movedFailed, err
+}
+
+func (r *RedisClient) migrateList(ctx context.Context, list string, push func(context.Context, []byte) error) (int, error) {
+	moved := 0
+	for {
+		data, err := r.Client.LIndex(ctx, list, 0).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, err
+		}
+
+		if err := push(ctx, data); err != nil {
+			return moved, err
+		}
+		if err := r.Client.LPop(ctx, list).Err(); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+}
+
+// ReadEvents blocks for up to block waiting for entries no consumer in the group has seen
+func (r *RedisClient) ReadEvents(ctx context.Context, consumer string, count int64, block time.Duration) ([]StreamMessage, error) {
+	streams, err := r.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    WebhookGroup,
+		Consumer: consumer,
+		Streams:  []string{WebhookQueue, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []StreamMessage
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			messages = append(messages, toStreamMessage(msg, 1))
+		}
+	}
+	return messages, nil
+}
+
+// ClaimStale takes over entries another consumer read but did not acknowledge within minIdle,
+// which is what a crash or a killed deploy leaves behind
+func (r *RedisClient) ClaimStale(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error) {
+	pending, err := r.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: WebhookQueue,
+		Group:  WebhookGroup,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(pending))
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+		deliveries[p.ID] = p.RetryCount
+	}
+
+	claimed, err := r.Client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   WebhookQueue,
+		Group:    WebhookGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]StreamMessage, 0, len(claimed))
+	for _, msg := range claimed {
+		// the claim itself counts as another delivery
+		messages = append(messages, toStreamMessage(msg, deliveries[msg.ID]+1))
+	}
+	return messages, nil
+}
+
+// Ack marks the entry as handled so it is never redelivered
+func (r *RedisClient) Ack(ctx context.Context, id string) error {
+	return r.Client.XAck(ctx, WebhookQueue, WebhookGroup, id).Err()
+}
+
+func toStreamMessage(msg redis.XMessage, deliveries int64) StreamMessage {
+	return StreamMessage{ID: msg.ID, Data: []byte(stringValue(msg.Values, "event")), Deliveries: deliveries}
+}
+
+func stringValue(values map[string]interface{}, key string) string {
+	switch v := values[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
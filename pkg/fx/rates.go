@@ -0,0 +1,73 @@
+// Package fx supplies exchange rates for converting between a user's wallets.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// RateProvider quotes the mid-market rate for one unit of from in to, the wallet adds its spread on top.
+// Every supported currency has a two decimal minor unit, so the rate applies to minor units as is.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// StaticProvider serves fixed rates against a base currency and derives cross rates through it
+type StaticProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewStaticProvider takes the units of each currency one unit of base buys
+func NewStaticProvider(base string, rates map[string]float64) *StaticProvider {
+	p := &StaticProvider{base: strings.ToUpper(base), rates: make(map[string]float64, len(rates)+1)}
+	for currency, rate := range rates {
+		p.rates[strings.ToUpper(currency)] = rate
+	}
+	p.rates[p.base] = 1
+	return p
+}
+
+// LoadFile reads a StaticProvider from JSON such as {"base": "USD", "rates": {"NGN": 1550, "GHS": 15.4}}
+func LoadFile(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Base  string             `json:"base"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("parse %s: base currency is required", path)
+	}
+	for currency, rate := range file.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("parse %s: rate for %s must be positive", path, currency)
+		}
+	}
+
+	return NewStaticProvider(file.Base, file.Rates), nil
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, from)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, to)
+	}
+	return toRate / fromRate, nil
+}
@@ -0,0 +1,42 @@
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/pkg/fx"
+)
+
+func TestStaticProviderFromFile(t *testing.T) {
+	provider, err := fx.LoadFile("testdata/rates.json")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		from, to string
+		want     float64
+	}{
+		{"USD", "NGN", 1500},
+		{"NGN", "USD", 1.0 / 1500},
+		{"GHS", "NGN", 100},
+		{"NGN", "NGN", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"-"+tt.to, func(t *testing.T) {
+			rate, err := provider.Rate(ctx, tt.from, tt.to)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, rate, 1e-12)
+		})
+	}
+
+	_, err = provider.Rate(ctx, "NGN", "EUR")
+	assert.True(t, errors.Is(err, fx.ErrRateUnavailable))
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	_, err := fx.LoadFile("testdata/missing.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,283 @@
+package paystack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/logger"
+)
+
+const DefaultBaseURL = "https://api.paystack.co"
+
+type Client interface {
+	InitializeTransaction(ctx context.Context, req InitializeRequest) (*InitializeResponse, error)
+	VerifyTransaction(ctx context.Context, reference string) (*Transaction, error)
+	CreateRefund(ctx context.Context, req RefundRequest) (*Refund, error)
+	CreateTransferRecipient(ctx context.Context, req TransferRecipientRequest) (*TransferRecipient, error)
+	InitiateTransfer(ctx context.Context, req TransferRequest) (*Transfer, error)
+	VerifyTransfer(ctx context.Context, reference string) (*Transfer, error)
+	ListBanks(ctx context.Context, currency string) ([]Bank, error)
+	ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*ResolvedAccount, error)
+	CreateCustomer(ctx context.Context, req CustomerRequest) (*Customer, error)
+	CreateDedicatedAccount(ctx context.Context, req DedicatedAccountRequest) (*DedicatedAccount, error)
+}
+
+type client struct {
+	secretKey   string
+	baseURL     string
+	httpClient  *http.Client
+	maxRetries  int
+	baseBackoff time.Duration
+}
+
+type Option func(*client)
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *client) { cl.httpClient = c }
+}
+
+// WithRetries sets how many times a failed request is retried and the initial backoff between attempts
+func WithRetries(maxRetries int, baseBackoff time.Duration) Option {
+	return func(cl *client) {
+		cl.maxRetries = maxRetries
+		cl.baseBackoff = baseBackoff
+	}
+}
+
+func NewClient(secretKey, baseURL string, opts ...Option) Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	c := &client{
+		secretKey:   secretKey,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		maxRetries:  3,
+		baseBackoff: 200 * time.Millisecond,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *client) InitializeTransaction(ctx context.Context, req InitializeRequest) (*InitializeResponse, error) {
+	var out InitializeResponse
+	if _, err := c.do(ctx, http.MethodPost, "/transaction/initialize", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) VerifyTransaction(ctx context.Context, reference string) (*Transaction, error) {
+	var out Transaction
+	if _, err := c.do(ctx, http.MethodGet, "/transaction/verify/"+url.PathEscape(reference), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) CreateRefund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	var out Refund
+	// refunds carry no idempotency reference, so a retry could refund twice
+	if _, err := c.doWithRetries(ctx, http.MethodPost, "/refund", req, &out, 0); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) CreateTransferRecipient(ctx context.Context, req TransferRecipientRequest) (*TransferRecipient, error) {
+	var out TransferRecipient
+	if _, err := c.do(ctx, http.MethodPost, "/transferrecipient", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InitiateTransfer is retried like other requests since the reference makes it idempotent at Paystack. An
+// attempt can be accepted and its answer lost, the retry then comes back as a duplicate reference and the
+// transfer already submitted is fetched by reference instead.
+func (c *client) InitiateTransfer(ctx context.Context, req TransferRequest) (*Transfer, error) {
+	var out Transfer
+	if _, err := c.do(ctx, http.MethodPost, "/transfer", req, &out); err != nil {
+		if !IsDuplicateReference(err) {
+			return nil, err
+		}
+		logger.Warn("Paystack transfer reference already used, verifying it", logger.Fields{"reference": req.Reference})
+		return c.VerifyTransfer(ctx, req.Reference)
+	}
+	return &out, nil
+}
+
+func (c *client) VerifyTransfer(ctx context.Context, reference string) (*Transfer, error) {
+	var out Transfer
+	if _, err := c.do(ctx, http.MethodGet, "/transfer/verify/"+url.PathEscape(reference), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) ListBanks(ctx context.Context, currency string) ([]Bank, error) {
+	var banks []Bank
+	next := ""
+
+	for {
+		query := url.Values{}
+		query.Set("currency", currency)
+		query.Set("perPage", "100")
+		query.Set("use_cursor", "true")
+		if next != "" {
+			query.Set("next", next)
+		}
+
+		var page []Bank
+		env, err := c.do(ctx, http.MethodGet, "/bank?"+query.Encode(), nil, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		banks = append(banks, page...)
+
+		if env.Meta.Next == "" {
+			break
+		}
+		next = env.Meta.Next
+	}
+
+	return banks, nil
+}
+
+func (c *client) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (*ResolvedAccount, error) {
+	query := url.Values{}
+	query.Set("account_number", accountNumber)
+	query.Set("bank_code", bankCode)
+
+	var out ResolvedAccount
+	if _, err := c.do(ctx, http.MethodGet, "/bank/resolve?"+query.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) CreateCustomer(ctx context.Context, req CustomerRequest) (*Customer, error) {
+	var out Customer
+	if _, err := c.do(ctx, http.MethodPost, "/customer", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) CreateDedicatedAccount(ctx context.Context, req DedicatedAccountRequest) (*DedicatedAccount, error) {
+	var out DedicatedAccount
+	if _, err := c.do(ctx, http.MethodPost, "/dedicated_account", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// do sends the request, retrying transport errors, 429s and 5xx responses with exponential backoff
+func (c *client) do(ctx context.Context, method, path string, payload interface{}, out interface{}) (*envelope, error) {
+	return c.doWithRetries(ctx, method, path, payload, out, c.maxRetries)
+}
+
+func (c *client) doWithRetries(ctx context.Context, method, path string, payload interface{}, out interface{}, maxRetries int) (*envelope, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal paystack request: %v", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := c.baseBackoff * time.Duration(1<<(attempt-1))
+			backoff += time.Duration(rand.Int63n(int64(c.baseBackoff) + 1))
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		env, err := c.send(ctx, method, path, body)
+		if err == nil {
+			if out != nil && len(env.Data) > 0 && string(env.Data) != "null" {
+				if err := json.Unmarshal(env.Data, out); err != nil {
+					return nil, fmt.Errorf("failed to parse paystack response: %v", err)
+				}
+			}
+			return env, nil
+		}
+
+		lastErr = err
+		if !isTemporary(err) || ctx.Err() != nil {
+			break
+		}
+
+		logger.Warn("Paystack request failed, retrying", logger.Fields{
+			"method":  method,
+			"path":    path,
+			"attempt": attempt + 1,
+			"error":   err.Error(),
+		})
+	}
+
+	return nil, lastErr
+}
+
+func (c *client) send(ctx context.Context, method, path string, body []byte) (*envelope, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.secretKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(respBody, &env); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+		}
+		return nil, fmt.Errorf("failed to parse paystack response: %v", err)
+	}
+
+	if resp.StatusCode >= 300 || !env.Status {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: env.Message}
+	}
+
+	return &env, nil
+}
@@ -0,0 +1,162 @@
+package paystack_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack/paystacktest"
+)
+
+func newTestClient(srv *paystacktest.Server) paystack.Client {
+	return paystack.NewClient(srv.Secret, srv.URL, paystack.WithRetries(3, time.Millisecond))
+}
+
+func TestInitializeAndVerify(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	client := newTestClient(srv)
+	ctx := context.Background()
+
+	init, err := client.InitializeTransaction(ctx, paystack.InitializeRequest{
+		Email:     "user@example.com",
+		Amount:    50000,
+		Reference: "dep-test-1",
+		Currency:  "NGN",
+		Metadata:  map[string]interface{}{"wallet_id": "w-1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dep-test-1", init.Reference)
+	assert.NotEmpty(t, init.AuthorizationURL)
+
+	srv.SetTransactionStatus("dep-test-1", "success")
+
+	tx, err := client.VerifyTransaction(ctx, "dep-test-1")
+	require.NoError(t, err)
+	assert.Equal(t, "success", tx.Status)
+	assert.Equal(t, int64(50000), tx.Amount)
+	assert.Equal(t, "w-1", tx.Metadata["wallet_id"])
+}
+
+func TestVerifyUnknownReference(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+
+	_, err := newTestClient(srv).VerifyTransaction(context.Background(), "missing")
+	require.Error(t, err)
+
+	var apiErr *paystack.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.True(t, paystack.IsNotFound(err))
+	assert.Equal(t, 1, srv.Hits("/transaction/verify/missing"), "client errors must not be retried")
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	srv.FailNext(2)
+
+	banks, err := newTestClient(srv).ListBanks(context.Background(), "NGN")
+	require.NoError(t, err)
+	assert.NotEmpty(t, banks)
+	assert.Equal(t, 3, srv.Hits("/bank"))
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	srv.FailNext(10)
+
+	_, err := newTestClient(srv).ResolveAccount(context.Background(), "0001234567", "058")
+	require.Error(t, err)
+
+	var apiErr *paystack.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.True(t, apiErr.Temporary())
+	assert.Equal(t, 4, srv.Hits("/bank/resolve"))
+}
+
+func TestRefundIsNotRetried(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	srv.FailNext(1)
+
+	_, err := newTestClient(srv).CreateRefund(context.Background(), paystack.RefundRequest{Transaction: "dep-test-1"})
+	require.Error(t, err)
+	assert.Equal(t, 1, srv.Hits("/refund"))
+}
+
+func TestTransferFlow(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	srv.AddAccount("0001234567", "058", "JOHN DOE")
+	client := newTestClient(srv)
+	ctx := context.Background()
+
+	account, err := client.ResolveAccount(ctx, "0001234567", "058")
+	require.NoError(t, err)
+	assert.Equal(t, "JOHN DOE", account.AccountName)
+
+	recipient, err := client.CreateTransferRecipient(ctx, paystack.TransferRecipientRequest{
+		Type:          "nuban",
+		Name:          "John",
+		AccountNumber: "0001234567",
+		BankCode:      "058",
+		Currency:      "NGN",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, recipient.RecipientCode)
+
+	transfer, err := client.InitiateTransfer(ctx, paystack.TransferRequest{
+		Source:    "balance",
+		Amount:    20000,
+		Recipient: recipient.RecipientCode,
+		Reference: "wdr-test-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "pending", transfer.Status)
+
+	stored, ok := srv.Transfer("wdr-test-1")
+	require.True(t, ok)
+	assert.Equal(t, int64(20000), stored.Amount)
+}
+
+func TestTransferRetryAfterLostAnswer(t *testing.T) {
+	srv := paystacktest.NewServer()
+	defer srv.Close()
+	srv.AddAccount("0001234567", "058", "JOHN DOE")
+	ctx := context.Background()
+
+	client := paystack.NewClient(srv.Secret, srv.URL,
+		paystack.WithRetries(3, time.Millisecond),
+		paystack.WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+
+	recipient, err := client.CreateTransferRecipient(ctx, paystack.TransferRecipientRequest{
+		Type:          "nuban",
+		Name:          "John",
+		AccountNumber: "0001234567",
+		BankCode:      "058",
+		Currency:      "NGN",
+	})
+	require.NoError(t, err)
+
+	// the first attempt is accepted but its answer never arrives, the retry is a duplicate
+	srv.StallNext("/transfer", 1, time.Second)
+	transfer, err := client.InitiateTransfer(ctx, paystack.TransferRequest{
+		Source:    "balance",
+		Amount:    20000,
+		Recipient: recipient.RecipientCode,
+		Reference: "wdr-test-2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "wdr-test-2", transfer.Reference)
+	assert.Equal(t, "pending", transfer.Status)
+	assert.Equal(t, 2, srv.Hits("/transfer"))
+	assert.Equal(t, 1, srv.Hits("/transfer/verify/wdr-test-2"))
+}
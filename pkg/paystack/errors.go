@@ -0,0 +1,59 @@
+package paystack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned when Paystack answers with a non 2xx status or status=false
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("paystack returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request is worth retrying
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsNotFound reports whether Paystack did not recognise the requested resource.
+// Paystack answers unknown references with a 400 and a "not found" message, so both are checked.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || strings.Contains(strings.ToLower(apiErr.Message), "not found")
+}
+
+// IsDuplicateReference reports whether Paystack turned a request down because its reference was already used,
+// for a transfer that means an earlier attempt with the reference was accepted
+func IsDuplicateReference(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "duplicate")
+}
+
+// IsRejected reports whether Paystack answered and turned the request down. Timeouts, transport errors and
+// 5xx answers leave it unknown whether the request took effect.
+func IsRejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && !apiErr.Temporary() && apiErr.StatusCode >= http.StatusBadRequest
+}
+
+func isTemporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	// transport errors (timeouts, resets) are retried
+	return true
+}
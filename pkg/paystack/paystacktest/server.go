@@ -0,0 +1,502 @@
+// Package paystacktest provides an in-memory Paystack API for tests.
+package paystacktest
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/zjoart/go-paystack-wallet/pkg/paystack"
+)
+
+const DefaultSecret = "sk_test_fake"
+
+type Server struct {
+	*httptest.Server
+	Secret string
+
+	mu           sync.Mutex
+	transactions map[string]*paystack.Transaction
+	transfers    map[string]*paystack.Transfer
+	recipients   map[string]*paystack.TransferRecipient
+	refunds      []paystack.Refund
+	customers    map[string]*paystack.Customer
+	dedicated    map[string]*paystack.DedicatedAccount
+	accounts     map[string]string
+	banks        []paystack.Bank
+	failures     int
+	stalls       map[string]int
+	stallFor     time.Duration
+	hits         map[string]int
+	sequence     int64
+}
+
+// NewServer starts a fake Paystack API, callers must Close it when done
+func NewServer() *Server {
+	s := &Server{
+		Secret:       DefaultSecret,
+		transactions: make(map[string]*paystack.Transaction),
+		transfers:    make(map[string]*paystack.Transfer),
+		recipients:   make(map[string]*paystack.TransferRecipient),
+		customers:    make(map[string]*paystack.Customer),
+		dedicated:    make(map[string]*paystack.DedicatedAccount),
+		accounts:     make(map[string]string),
+		hits:         make(map[string]int),
+		stalls:       make(map[string]int),
+		banks: []paystack.Bank{
+			{Name: "Access Bank", Code: "044", Slug: "access-bank", Currency: "NGN", Type: "nuban"},
+			{Name: "Guaranty Trust Bank", Code: "058", Slug: "guaranty-trust-bank", Currency: "NGN", Type: "nuban"},
+			{Name: "Zenith Bank", Code: "057", Slug: "zenith-bank", Currency: "NGN", Type: "nuban"},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transaction/initialize", s.initialize)
+	mux.HandleFunc("GET /transaction/verify/{reference}", s.verify)
+	mux.HandleFunc("POST /refund", s.refund)
+	mux.HandleFunc("POST /transferrecipient", s.createRecipient)
+	mux.HandleFunc("POST /transfer", s.transfer)
+	mux.HandleFunc("GET /transfer/verify/{reference}", s.verifyTransfer)
+	mux.HandleFunc("GET /bank", s.listBanks)
+	mux.HandleFunc("GET /bank/resolve", s.resolve)
+	mux.HandleFunc("POST /customer", s.createCustomer)
+	mux.HandleFunc("POST /dedicated_account", s.createDedicatedAccount)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// Sign computes the x-paystack-signature header for a webhook body
+func Sign(secret string, body []byte) string {
+	hash := hmac.New(sha512.New, []byte(secret))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// FailNext makes the next n requests answer with a 500
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// StallNext makes the next n requests to path take effect but hold their answer for d, a client with a
+// shorter timeout sees a request that went through as one that failed
+func (s *Server) StallNext(path string, n int, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stalls[path], s.stallFor = n, d
+}
+
+// Hits returns how many requests reached the given path, including failed ones
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// AddAccount registers a bank account that /bank/resolve and /transferrecipient accept
+func (s *Server) AddAccount(accountNumber, bankCode, accountName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[accountNumber+"|"+bankCode] = accountName
+}
+
+// AddTransaction registers a transaction that was not initialized through the fake, e.g. a bank transfer
+func (s *Server) AddTransaction(tx paystack.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transactions[tx.Reference] = &tx
+}
+
+// SetTransactionStatus simulates the customer completing or abandoning checkout
+func (s *Server) SetTransactionStatus(reference, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx, ok := s.transactions[reference]; ok {
+		tx.Status = status
+	}
+}
+
+func (s *Server) Transaction(reference string) (paystack.Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[reference]
+	if !ok {
+		return paystack.Transaction{}, false
+	}
+	return *tx, true
+}
+
+func (s *Server) Transfer(reference string) (paystack.Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[reference]
+	if !ok {
+		return paystack.Transfer{}, false
+	}
+	return *t, true
+}
+
+// SetTransferStatus simulates Paystack settling or failing a transfer
+func (s *Server) SetTransferStatus(reference, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.transfers[reference]; ok {
+		t.Status = status
+	}
+}
+
+func (s *Server) Refunds() []paystack.Refund {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]paystack.Refund(nil), s.refunds...)
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[r.URL.Path]++
+		fail := s.failures > 0
+		if fail {
+			s.failures--
+		}
+		var stall time.Duration
+		if !fail && s.stalls[r.URL.Path] > 0 {
+			s.stalls[r.URL.Path]--
+			stall = s.stallFor
+		}
+		s.mu.Unlock()
+
+		if fail {
+			writeError(w, http.StatusInternalServerError, "Simulated failure")
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+s.Secret {
+			writeError(w, http.StatusUnauthorized, "Invalid key")
+			return
+		}
+
+		if stall == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		select {
+		case <-time.After(stall):
+		case <-r.Context().Done():
+			return
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+func (s *Server) nextID() int64 {
+	s.sequence++
+	return s.sequence
+}
+
+func (s *Server) initialize(w http.ResponseWriter, r *http.Request) {
+	var req paystack.InitializeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.transactions[req.Reference]; exists {
+		writeError(w, http.StatusBadRequest, "Duplicate Transaction Reference")
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	s.transactions[req.Reference] = &paystack.Transaction{
+		ID:        s.nextID(),
+		Status:    "abandoned",
+		Reference: req.Reference,
+		Amount:    req.Amount,
+		Currency:  currency,
+		Channel:   "card",
+		Metadata:  req.Metadata,
+		Customer:  paystack.Customer{Email: req.Email},
+	}
+
+	writeData(w, http.StatusOK, paystack.InitializeResponse{
+		AuthorizationURL: s.URL + "/checkout/" + req.Reference,
+		AccessCode:       fmt.Sprintf("access_%s", req.Reference),
+		Reference:        req.Reference,
+	})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[r.PathValue("reference")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Transaction reference not found")
+		return
+	}
+
+	writeData(w, http.StatusOK, tx)
+}
+
+func (s *Server) refund(w http.ResponseWriter, r *http.Request) {
+	var req paystack.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[req.Transaction]
+	if !ok || tx.Status != "success" {
+		writeError(w, http.StatusBadRequest, "Transaction not found or not refundable")
+		return
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = tx.Amount
+	}
+
+	var refunded int64
+	for _, rf := range s.refunds {
+		if rf.Transaction.Reference == tx.Reference {
+			refunded += rf.Amount
+		}
+	}
+	if refunded+amount > tx.Amount {
+		writeError(w, http.StatusBadRequest, "Refund amount cannot be greater than transaction amount")
+		return
+	}
+
+	refund := paystack.Refund{
+		ID:       s.nextID(),
+		Status:   "pending",
+		Amount:   amount,
+		Currency: tx.Currency,
+	}
+	refund.Transaction.ID = tx.ID
+	refund.Transaction.Reference = tx.Reference
+	s.refunds = append(s.refunds, refund)
+
+	writeData(w, http.StatusOK, refund)
+}
+
+func (s *Server) createRecipient(w http.ResponseWriter, r *http.Request) {
+	var req paystack.TransferRecipientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.accounts[req.AccountNumber+"|"+req.BankCode]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cannot resolve account")
+		return
+	}
+
+	recipient := &paystack.TransferRecipient{
+		RecipientCode: fmt.Sprintf("RCP_%d", s.nextID()),
+		Name:          req.Name,
+	}
+	recipient.Details.AccountNumber = req.AccountNumber
+	recipient.Details.AccountName = name
+	recipient.Details.BankCode = req.BankCode
+	s.recipients[recipient.RecipientCode] = recipient
+
+	writeData(w, http.StatusCreated, recipient)
+}
+
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
+	var req paystack.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recipients[req.Recipient]; !ok {
+		writeError(w, http.StatusBadRequest, "Recipient specified is invalid")
+		return
+	}
+
+	if _, ok := s.transfers[req.Reference]; ok {
+		writeError(w, http.StatusBadRequest, "Duplicate Transfer Reference")
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	t := &paystack.Transfer{
+		Reference:    req.Reference,
+		Amount:       req.Amount,
+		Currency:     currency,
+		Status:       "pending",
+		TransferCode: fmt.Sprintf("TRF_%d", s.nextID()),
+	}
+	s.transfers[req.Reference] = t
+
+	writeData(w, http.StatusOK, t)
+}
+
+func (s *Server) verifyTransfer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transfers[r.PathValue("reference")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+
+	writeData(w, http.StatusOK, t)
+}
+
+func (s *Server) listBanks(w http.ResponseWriter, r *http.Request) {
+	currency := r.URL.Query().Get("currency")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	banks := []paystack.Bank{}
+	for _, b := range s.banks {
+		if currency == "" || b.Currency == currency {
+			banks = append(banks, b)
+		}
+	}
+
+	writeData(w, http.StatusOK, banks)
+}
+
+func (s *Server) resolve(w http.ResponseWriter, r *http.Request) {
+	accountNumber := r.URL.Query().Get("account_number")
+	bankCode := r.URL.Query().Get("bank_code")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.accounts[accountNumber+"|"+bankCode]
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Could not resolve account name. Check parameters or try again.")
+		return
+	}
+
+	writeData(w, http.StatusOK, paystack.ResolvedAccount{AccountNumber: accountNumber, AccountName: name})
+}
+
+func (s *Server) createCustomer(w http.ResponseWriter, r *http.Request) {
+	var req paystack.CustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	customer, ok := s.customers[req.Email]
+	if !ok {
+		id := s.nextID()
+		customer = &paystack.Customer{
+			ID:           id,
+			CustomerCode: fmt.Sprintf("CUS_%d", id),
+			Email:        req.Email,
+			FirstName:    req.FirstName,
+			LastName:     req.LastName,
+		}
+		s.customers[req.Email] = customer
+	}
+
+	writeData(w, http.StatusOK, customer)
+}
+
+func (s *Server) createDedicatedAccount(w http.ResponseWriter, r *http.Request) {
+	var req paystack.DedicatedAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var customer *paystack.Customer
+	for _, c := range s.customers {
+		if c.CustomerCode == req.Customer {
+			customer = c
+		}
+	}
+	if customer == nil {
+		writeError(w, http.StatusBadRequest, "Customer not found")
+		return
+	}
+
+	account, ok := s.dedicated[req.Customer]
+	if !ok {
+		id := s.nextID()
+		account = &paystack.DedicatedAccount{
+			ID:            id,
+			AccountName:   "PAYSTACK/" + customer.Email,
+			AccountNumber: fmt.Sprintf("9%09d", id),
+			Currency:      "NGN",
+			Active:        true,
+			Assigned:      true,
+			Customer:      *customer,
+		}
+		account.Bank.Name = "Test Bank"
+		account.Bank.Slug = "test-bank"
+		s.dedicated[req.Customer] = account
+	}
+
+	writeData(w, http.StatusOK, account)
+}
+
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  true,
+		"message": "Success",
+		"data":    data,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  false,
+		"message": message,
+	})
+}
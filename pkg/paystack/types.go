@@ -0,0 +1,143 @@
+package paystack
+
+import "encoding/json"
+
+type envelope struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    struct {
+		Next string `json:"next"`
+	} `json:"meta"`
+}
+
+type InitializeRequest struct {
+	Email       string                 `json:"email"`
+	Amount      int64                  `json:"amount"`
+	Reference   string                 `json:"reference"`
+	Currency    string                 `json:"currency"`
+	Channels    []string               `json:"channels,omitempty"`
+	CallbackURL string                 `json:"callback_url,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type InitializeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	AccessCode       string `json:"access_code"`
+	Reference        string `json:"reference"`
+}
+
+type Customer struct {
+	ID           int64  `json:"id"`
+	CustomerCode string `json:"customer_code"`
+	Email        string `json:"email"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+}
+
+type Transaction struct {
+	ID              int64                  `json:"id"`
+	Status          string                 `json:"status"`
+	Reference       string                 `json:"reference"`
+	Amount          int64                  `json:"amount"`
+	Currency        string                 `json:"currency"`
+	Channel         string                 `json:"channel"`
+	GatewayResponse string                 `json:"gateway_response"`
+	PaidAt          string                 `json:"paid_at"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	Customer        Customer               `json:"customer"`
+}
+
+type RefundRequest struct {
+	Transaction  string `json:"transaction"`
+	Amount       int64  `json:"amount,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	CustomerNote string `json:"customer_note,omitempty"`
+	MerchantNote string `json:"merchant_note,omitempty"`
+}
+
+type Refund struct {
+	ID          int64  `json:"id"`
+	Status      string `json:"status"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Transaction struct {
+		ID        int64  `json:"id"`
+		Reference string `json:"reference"`
+	} `json:"transaction"`
+}
+
+type TransferRecipientRequest struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	Currency      string `json:"currency"`
+}
+
+type TransferRecipient struct {
+	RecipientCode string `json:"recipient_code"`
+	Name          string `json:"name"`
+	Details       struct {
+		AccountNumber string `json:"account_number"`
+		AccountName   string `json:"account_name"`
+		BankCode      string `json:"bank_code"`
+		BankName      string `json:"bank_name"`
+	} `json:"details"`
+}
+
+type TransferRequest struct {
+	Source    string `json:"source"`
+	Amount    int64  `json:"amount"`
+	Recipient string `json:"recipient"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason,omitempty"`
+	Currency  string `json:"currency,omitempty"`
+}
+
+type Transfer struct {
+	Reference    string `json:"reference"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+	TransferCode string `json:"transfer_code"`
+}
+
+type Bank struct {
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	Slug     string `json:"slug"`
+	Currency string `json:"currency"`
+	Type     string `json:"type"`
+}
+
+type ResolvedAccount struct {
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+}
+
+type CustomerRequest struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+type DedicatedAccountRequest struct {
+	Customer      string `json:"customer"`
+	PreferredBank string `json:"preferred_bank,omitempty"`
+}
+
+type DedicatedAccount struct {
+	ID            int64  `json:"id"`
+	AccountName   string `json:"account_name"`
+	AccountNumber string `json:"account_number"`
+	Currency      string `json:"currency"`
+	Active        bool   `json:"active"`
+	Assigned      bool   `json:"assigned"`
+	Bank          struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"bank"`
+	Customer Customer `json:"customer"`
+}
@@ -5,6 +5,9 @@ type ContextKey string
 const (
 	UserKey        ContextKey = "user"
 	PermissionsKey ContextKey = "permissions"
+	AuthTimeKey    ContextKey = "auth_time"
+	APIKeyIDKey    ContextKey = "api_key_id"
 	UserIDKey      string     = "user_id"
 	ExpKey         string     = "exp"
+	IatKey         string     = "iat"
 )